@@ -25,11 +25,20 @@ const usage = `Usage:
 Options:
     -a, --algorithm    Algorithm: sha1, sha256, sha512 (default), sha3-256, sha3-512, blake2b
     -o, --object-type  Object type: commit (default), hash, blob, tag
+    --object-index     Read and store commit and tag hashes in the object index, see gitverify --object-index
     --debug            Enable debug logging
     -h, --help         Show help message`
 
+type options struct {
+	algorithm   string
+	newHash     func() hash.Hash
+	objectType  githash.ObjectType
+	targetHash  *plumbing.Hash
+	objectIndex bool
+}
+
 func main() {
-	algorithm, objectType, targetHash, err := processOptionsAndArgs()
+	opts, err := processOptionsAndArgs()
 	if err != nil {
 		print("Failed to process command line options and arguments: ", err.Error(), "\n")
 		os.Exit(1)
@@ -41,6 +50,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	var index *gitkit.ObjectIndex
+	if opts.objectIndex {
+		index, err = gitkit.OpenObjectIndex(repo)
+		if err != nil {
+			print("Failed to open object index: ", err.Error(), "\n")
+			os.Exit(1)
+		}
+	}
+
+	targetHash := opts.targetHash
 	if targetHash == nil {
 		head, err := repo.Head()
 		if err != nil {
@@ -55,29 +74,40 @@ func main() {
 	slog.Debug("running githash",
 		"targetHash", hex.EncodeToString((*targetHash)[:]))
 
-	err = verifyTargetHashOrExit(repo, targetHash, objectType)
+	err = verifyTargetHashOrExit(repo, index, targetHash, opts.objectType)
 	if err != nil {
 		print("Failed to run integrity check: ", err.Error(), "\n")
 		os.Exit(1)
 	}
 
-	gitHash := githash.NewGitHash(repo, algorithm)
-	result, err := hashSum(gitHash, targetHash, objectType)
+	gitHash, err := newGitHash(repo, index, opts.algorithm, opts.newHash)
+	if err != nil {
+		print("Failed to load repository: ", err.Error(), "\n")
+		os.Exit(1)
+	}
+
+	result, err := hashSum(gitHash, targetHash, opts.objectType)
 	if err != nil {
 		print("Failed to get hash sum: ", err.Error(), "\n")
 		os.Exit(1)
 	}
 
+	err = index.Save()
+	if err != nil {
+		print("Failed to save object index: ", err.Error(), "\n")
+		os.Exit(1)
+	}
+
 	fmt.Println(hex.EncodeToString(result))
 }
 
-func processOptionsAndArgs() (algorithm hash.Hash, objectType githash.ObjectType, targetHash *plumbing.Hash, err error) {
+func processOptionsAndArgs() (*options, error) {
 	flag.Usage = func() {
 		fmt.Println(usage)
 	}
 
 	flags := flag.NewFlagSet("all", flag.ExitOnError)
-	var help, h, debugMode bool
+	var help, h, debugMode, objectIndex bool
 	var algorithmString, algorithmStringShort, typeString, typeStringShort string
 
 	const (
@@ -92,10 +122,11 @@ func processOptionsAndArgs() (algorithm hash.Hash, objectType githash.ObjectType
 	flags.BoolVar(&help, "help", false, "")
 	flags.BoolVar(&h, "h", false, "")
 	flags.BoolVar(&debugMode, "debug", false, "")
+	flags.BoolVar(&objectIndex, "object-index", false, "")
 
-	err = flags.Parse(os.Args[1:])
+	err := flags.Parse(os.Args[1:])
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("failed to parse flags: %w", err)
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
 	}
 
 	if h || help {
@@ -104,7 +135,7 @@ func processOptionsAndArgs() (algorithm hash.Hash, objectType githash.ObjectType
 	}
 
 	if algorithmString != defaultAlgorithm && algorithmStringShort != defaultAlgorithm {
-		return nil, "", nil, fmt.Errorf("both --algorithm and -a set, pick one")
+		return nil, fmt.Errorf("both --algorithm and -a set, pick one")
 	}
 
 	if algorithmStringShort != defaultAlgorithm {
@@ -112,7 +143,7 @@ func processOptionsAndArgs() (algorithm hash.Hash, objectType githash.ObjectType
 	}
 
 	if typeString != defaultType && typeStringShort != defaultType {
-		return nil, "", nil, fmt.Errorf("both --object-type and -o set, pick one")
+		return nil, fmt.Errorf("both --object-type and -o set, pick one")
 	}
 
 	if typeStringShort != defaultType {
@@ -130,26 +161,29 @@ func processOptionsAndArgs() (algorithm hash.Hash, objectType githash.ObjectType
 	logger := slog.New(slog.NewTextHandler(os.Stdout, opts))
 	slog.SetDefault(logger)
 
+	var newHash func() hash.Hash
 	switch algorithmString {
 	case "sha1":
-		algorithm = sha1.New()
+		newHash = sha1.New
 	case "sha256":
-		algorithm = sha256.New()
+		newHash = sha256.New
 	case "sha512":
-		algorithm = sha512.New()
+		newHash = sha512.New
 	case "sha3-256":
-		algorithm = sha3.New256()
+		newHash = sha3.New256
 	case "sha3-512":
-		algorithm = sha3.New512()
+		newHash = sha3.New512
 	case "blake2b":
-		algorithm, err = blake2b.New(64, nil)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to initialize blake2b: %w", err)
+		newHash = func() hash.Hash {
+			// only fails for keys longer than 64 bytes
+			h, _ := blake2b.New512(nil)
+			return h
 		}
 	default:
-		return nil, "", nil, fmt.Errorf("unsupported hash algorithm: %s", algorithmString)
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algorithmString)
 	}
 
+	var objectType githash.ObjectType
 	switch typeString {
 	case "commit":
 		objectType = githash.CommitObject
@@ -160,24 +194,45 @@ func processOptionsAndArgs() (algorithm hash.Hash, objectType githash.ObjectType
 	case "tag":
 		objectType = githash.TagObject
 	default:
-		return nil, "", nil, fmt.Errorf("unsupported object type: %s", typeString)
+		return nil, fmt.Errorf("unsupported object type: %s", typeString)
 	}
 
 	if len(flags.Args()) > 1 {
-		return nil, "", nil, fmt.Errorf("only one argument expected, got %d", len(flags.Args()))
+		return nil, fmt.Errorf("only one argument expected, got %d", len(flags.Args()))
 	}
 
+	var targetHash *plumbing.Hash
 	if len(flags.Args()) == 1 {
 		hashCandidate := flags.Args()[0]
 		if len(hashCandidate) != 40 {
-			return nil, "", nil, fmt.Errorf("hash must be 40 characters: got %d\n", len(hashCandidate))
+			return nil, fmt.Errorf("hash must be 40 characters: got %d\n", len(hashCandidate))
 		}
 
 		h := plumbing.NewHash(hashCandidate)
 		targetHash = &h
 	}
 
-	return algorithm, objectType, targetHash, nil
+	return &options{
+		algorithm:   algorithmString,
+		newHash:     newHash,
+		objectType:  objectType,
+		targetHash:  targetHash,
+		objectIndex: objectIndex,
+	}, nil
+}
+
+// newGitHash reads and adds the sums of commits and tags to the index, if there is one.
+func newGitHash(repo *git.Repository, index *gitkit.ObjectIndex, algorithm string, newHash func() hash.Hash) (githash.GitHash, error) {
+	if index == nil {
+		return githash.NewGitHash(repo, newHash())
+	}
+
+	state, err := gitkit.NewLazyRepoStateFromIndex(repo, index, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return githash.NewGitHashFromIndex(state, index, algorithm, newHash), nil
 }
 
 func loadRepoFromCwd() (*git.Repository, error) {
@@ -219,8 +274,11 @@ func hashSum(gitHash githash.GitHash, targetHash *plumbing.Hash, objectType gith
 
 // verifyOrExit verifies that the computed sha1 matches the target hash.
 // When testing has been improved this can be removed.
-func verifyTargetHashOrExit(repo *git.Repository, targetHash *plumbing.Hash, objectType githash.ObjectType) error {
-	verificationGitHash := githash.NewGitHash(repo, sha1.New())
+func verifyTargetHashOrExit(repo *git.Repository, index *gitkit.ObjectIndex, targetHash *plumbing.Hash, objectType githash.ObjectType) error {
+	verificationGitHash, err := newGitHash(repo, index, "sha1", sha1.New)
+	if err != nil {
+		return err
+	}
 
 	verificationHash, err := hashSum(verificationGitHash, targetHash, objectType)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 )
 
 const usage = `SYNOPSIS
@@ -78,6 +79,61 @@ SEARCH OPTIONS
 	--all-branches
 		Search the the tip of each branch.
 
+	--diff
+		Search only the lines each commit added compared to its parent, including dangling commits.
+		Each match is reported with the commits that introduced it. Merge commits are skipped and renamed
+		files are searched as if all lines were added.
+
+	--include-removed
+		With --diff, also search the lines each commit removed.
+
+	--metadata
+		With --all-history, --all-branches or --diff, also search the messages, authors and committers of all
+		commits, the messages and taggers of annotated tags, and notes in refs/notes/*. Matches are reported with
+		pseudo-paths 'commit:<hash>:message|author|committer', 'tag:<hash>:message|tagger' and
+		'note:<hash>:<notes ref>'. Path filters do not apply to metadata.
+
+	--submodules
+		With --all-history or --all-branches, search the commit pinned by each submodule, in the checkout of the
+		submodule or in .git/modules if present. Matches are reported with the path of the submodule as prefix.
+		Submodules that are not cloned are skipped. Checked out submodules are always searched without
+		--all-history or --all-branches.
+
+	--follow-symlinks
+		Search the files and directories that symlinks point to, if they are inside the repo. Matches are reported
+		with the path of the symlink. Only applies without --all-history, --all-branches or --diff, since the
+		targets are searched at their own path in history.
+
+	--skip-ignored
+		Skip files marked linguist-generated or linguist-vendored in .gitattributes, and untracked files ignored
+		by .gitignore or .git/info/exclude. Tracked files are searched even if they match .gitignore, like in
+		git. With --all-history, --all-branches or --diff only the .gitattributes files of each commit are used,
+		since every committed file is tracked.
+
+	--since=DATE
+		With --all-history, --all-branches or --diff, only search commits with a committer date on or after DATE.
+		DATE is 'YYYY-MM-DD' in local time or RFC 3339, e.g. '2024-01-01T00:00:00Z'. Like 'git log --since', the
+		history is not searched further back than the first commit before DATE.
+
+	--until=DATE
+		With --all-history, --all-branches or --diff, only search commits with a committer date on or before DATE.
+		A date without a time includes the whole day.
+
+	--refs=GLOB,...
+		With --all-history, --all-branches or --diff, only search the branches matching any of the globs, e.g.
+		'release/*', 'origin/release/*' or 'refs/remotes/origin/release/*'. Dangling commits are not searched.
+
+	--commit-range=A..B
+		With --all-history or --diff, only search the commits reachable from B but not from A, like 'git log A..B'.
+		B defaults to HEAD. Matches are reported on a branch named after the range. Dangling commits are not
+		searched.
+
+	--cache-dir=DIR
+		Cache the results of each blob in DIR, so that a later run with the same search terms and search
+		options only searches the blobs it has not seen before and replays the cached results. Only applies to
+		--all-history and --all-branches, and not with dorks. Path filters and --exclude-words can be changed
+		between runs.
+
 	--dorks=PATH:SEARCH_TERM,...
 		Each dork is a file path and search term separated by ':'
 
@@ -154,10 +210,11 @@ func main() {
 	var (
 		matchCharacters, characterSet, searchTermsFile, excludeWordsFile, excludeWordsList, excludeReposList,
 		excludeReposFile, includeReposList, includeReposFile, excludePathsList, excludePathsFile, includePathsList,
-		includePathsFile, dorksList, dorksFile string
+		includePathsFile, dorksList, dorksFile, cacheDir, since, until, refsList, commitRange string
 		anchorBeginning, anchorEnd, debugMode, stats, displayLineNumber,
 		hideRepoAndFilename, onlyMatching, displayColor, displayColumn, displayKeyword, help, h,
-		outputSearchStrings, allHistory, allBranches, heading, outputJsonLines bool
+		outputSearchStrings, allHistory, allBranches, diff, includeRemoved, searchMetadata, searchSubmodules, followSymlinks, skipIgnored, heading,
+		outputJsonLines bool
 		numThreads, numContext, linesBeforeContext, linesAfterContext, contextColumns int
 	)
 	flags := flag.NewFlagSet("all", flag.ExitOnError)
@@ -186,6 +243,17 @@ func main() {
 
 	flags.BoolVar(&allHistory, "all-history", false, "")
 	flags.BoolVar(&allBranches, "all-branches", false, "")
+	flags.BoolVar(&diff, "diff", false, "")
+	flags.BoolVar(&includeRemoved, "include-removed", false, "")
+	flags.BoolVar(&searchMetadata, "metadata", false, "")
+	flags.BoolVar(&searchSubmodules, "submodules", false, "")
+	flags.BoolVar(&followSymlinks, "follow-symlinks", false, "")
+	flags.BoolVar(&skipIgnored, "skip-ignored", false, "")
+	flags.StringVar(&cacheDir, "cache-dir", "", "")
+	flags.StringVar(&since, "since", "", "")
+	flags.StringVar(&until, "until", "", "")
+	flags.StringVar(&refsList, "refs", "", "")
+	flags.StringVar(&commitRange, "commit-range", "", "")
 
 	flags.StringVar(&dorksList, "dorks", "", "")
 	flags.StringVar(&dorksFile, "dorks-file", "", "")
@@ -269,6 +337,17 @@ func main() {
 		"anchor-end", anchorEnd,
 		"all-history", allHistory,
 		"all-branches", allBranches,
+		"diff", diff,
+		"include-removed", includeRemoved,
+		"metadata", searchMetadata,
+		"submodules", searchSubmodules,
+		"follow-symlinks", followSymlinks,
+		"skip-ignored", skipIgnored,
+		"cache-dir", cacheDir,
+		"since", since,
+		"until", until,
+		"refs", refsList,
+		"commit-range", commitRange,
 		"threads", numThreads)
 
 	slog.Debug("output options", "only-matching", onlyMatching,
@@ -289,12 +368,81 @@ func main() {
 		os.Exit(1)
 	}
 
+	if diff && (allHistory || allBranches) {
+		fmt.Println("--diff cannot be used together with --all-history or --all-branches")
+		os.Exit(1)
+	}
+
+	if includeRemoved && !diff {
+		fmt.Println("--include-removed can only be used with --diff")
+		os.Exit(1)
+	}
+
+	if searchMetadata && !(allHistory || allBranches || diff) {
+		fmt.Println("--metadata can only be used with --all-history, --all-branches or --diff")
+		os.Exit(1)
+	}
+
+	if searchSubmodules && !(allHistory || allBranches) {
+		fmt.Println("--submodules can only be used with --all-history or --all-branches")
+		os.Exit(1)
+	}
+
+	if followSymlinks && (allHistory || allBranches || diff) {
+		fmt.Println("--follow-symlinks cannot be used with --all-history, --all-branches or --diff")
+		os.Exit(1)
+	}
+
+	if (since != "" || until != "" || refsList != "") && !(allHistory || allBranches || diff) {
+		fmt.Println("--since, --until and --refs can only be used with --all-history, --all-branches or --diff")
+		os.Exit(1)
+	}
+
+	if commitRange != "" && !(allHistory || diff) {
+		fmt.Println("--commit-range can only be used with --all-history or --diff")
+		os.Exit(1)
+	}
+
+	if commitRange != "" && refsList != "" {
+		fmt.Println("--commit-range cannot be used together with --refs")
+		os.Exit(1)
+	}
+
+	if commitRange != "" {
+		_, _, err := gitkit.ParseCommitRange(commitRange)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	sinceTime := parseDate("--since", since, false)
+	untilTime := parseDate("--until", until, true)
+	if !sinceTime.IsZero() && !untilTime.IsZero() && untilTime.Before(sinceTime) {
+		fmt.Println("--until cannot be before --since")
+		os.Exit(1)
+	}
+
+	if cacheDir != "" && !(allHistory || allBranches) {
+		fmt.Println("--cache-dir can only be used with --all-history or --all-branches")
+		os.Exit(1)
+	}
+
+	if cacheDir != "" && (dorksList != "" || dorksFile != "") {
+		fmt.Println("--cache-dir cannot be used with --dorks or --dorks-file")
+		os.Exit(1)
+	}
+
 	mode := gitkit.ModeAllFiles
 
 	if allBranches {
 		mode = gitkit.ModeAllBranches
 	} else if allHistory {
 		mode = gitkit.ModeAllHistory
+	} else if diff && includeRemoved {
+		mode = gitkit.ModeDiffWithRemoved
+	} else if diff {
+		mode = gitkit.ModeDiff
 	}
 
 	file, err := os.Stdin.Stat()
@@ -427,31 +575,66 @@ func main() {
 	searchParameters.SetLinesAfterContext(linesAfterContext)
 	searchParameters.SetContextColumns(contextColumns)
 
-	config := gitsearch.NewConfig(searchTerms,
-		searchParameters,
-		mode,
-		candidateRepos,
-		numThreads,
-		excludeWords,
-		includeRepos,
-		excludeRepos,
-		includePaths,
-		excludePaths,
-		stats,
-		heading,
-		displayLineNumber,
-		displayColumn,
-		displayKeyword,
-		displayColor,
-		hideRepoAndFilename,
-		onlyMatching,
-		outputSearchStrings,
-		outputJsonLines,
-		dorks)
+	config := gitsearch.NewConfigWithOptions(&gitsearch.ConfigOptions{
+		SearchTerms:         searchTerms,
+		SearchParameters:    searchParameters,
+		Mode:                mode,
+		Repos:               candidateRepos,
+		Concurrency:         numThreads,
+		ExcludeWords:        excludeWords,
+		IncludeRepos:        includeRepos,
+		ExcludeRepos:        excludeRepos,
+		IncludePaths:        includePaths,
+		ExcludePaths:        excludePaths,
+		DisplayStats:        stats,
+		DisplayHeading:      heading,
+		DisplayLineNumber:   displayLineNumber,
+		DisplayColumn:       displayColumn,
+		DisplayKeyword:      displayKeyword,
+		DisplayColor:        displayColor,
+		HideRepoAndFilename: hideRepoAndFilename,
+		OnlyMatching:        onlyMatching,
+		OutputSearchStrings: outputSearchStrings,
+		OutputJsonLines:     outputJsonLines,
+		Dorks:               dorks,
+		SearchMetadata:      searchMetadata,
+		SearchSubmodules:    searchSubmodules,
+		FollowSymlinks:      followSymlinks,
+		SkipIgnored:         skipIgnored,
+		CacheDir:            cacheDir,
+		Since:               sinceTime,
+		Until:               untilTime,
+		Refs:                readFromList(refsList),
+		CommitRange:         commitRange,
+	})
 
 	gitsearch.SearchLocalRepos(config)
 }
 
+// parseDate returns the zero time for an empty value. A date without a time is the start of the day in local time,
+// or the end of the day if endOfDay is set.
+func parseDate(flagName string, value string, endOfDay bool) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err == nil {
+		if endOfDay {
+			return date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return date
+	}
+
+	date, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		fmt.Printf("%s must be 'YYYY-MM-DD' or RFC 3339, got '%s'\n", flagName, value)
+		os.Exit(1)
+	}
+
+	return date
+}
+
 func readFromList(list string) []string {
 	if len(list) == 0 {
 		return make([]string, 0)
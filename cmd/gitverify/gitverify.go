@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha1"
 	"crypto/sha512"
 	"encoding/json"
@@ -14,9 +15,12 @@ import (
 	"github.com/supply-chain-tools/go-sandbox/hashset"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"runtime/debug"
 	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
 )
 
 const usage = `Usage:
@@ -25,11 +29,23 @@ const usage = `Usage:
 COMMANDS
         verify
                 Verify the state of a Git repository. This is also the default if no command is specified.
+        verify-all [DIRECTORY]
+                Verify all repositories in DIRECTORY (default: the current directory). Repositories are laid
+                out as <forge>/<org>/<repo>, e.g. as created by repofetch, and DIRECTORY can be a single
+                repository, an organization (<forge>/<org>) or a forge (<forge>). Prints a pass/fail table
+                and a JSON report.
         after-candidates
                 Generate a list of all commits that is not pointed to by other commits. The list can be
                 used as the 'after' config.
         exempt-tags
                 Generate a list of all the tags in the repository to be used for the 'exemptTags' config.
+        ls-remote-check
+                List the refs advertised by the remote, without fetching, and compare the tags and protected
+                branches with the local state. Warns about deleted tags and branches, moved tags and
+                protected branches that are not fast-forwards.
+        forensics
+                List commits and annotated tags that are not reachable from any ref, e.g. stashes and
+                dangling commits, with their signer, why they fail verification and if they are safe to prune.
 
 VERIFY OPTIONS
         --config-file
@@ -46,6 +62,31 @@ VERIFY OPTIONS
                 Verify that --commit is at the tip of --branch.
         --verify-on-head
                 verify that HEAD points to the --commit. On by default.
+        --concurrency
+                Number of workers used for hashing and signature verification. Defaults to the number of CPUs.
+        --local-state-store
+                Where to keep the local state: a file path, 'git:<path to state repository>' or an http(s) URL
+                of a key-value endpoint. Defaults to ~/.config/gitverify/<forge>/<org>/<repo>/local.json.
+        --object-index
+                Keep an index of the commit graph and the SHA-1 and SHA-512 hashes of commits and tags in
+                <git dir>/gitkit/object-index.json and reuse it in later runs. Only new packs and loose objects
+                are decoded when the index is updated. The index is authenticated with a key in
+                ~/.config/gitkit/object-index.key and checked against the pack and loose object files.
+
+VERIFY-ALL OPTIONS
+        --config-file
+                Config file to use for all repositories. By default the config is inferred for each repository.
+        --concurrency
+                Number of repositories to verify concurrently. Default is 4.
+        --report-file
+                Write the JSON report to the file rather than stdout.
+        --local-state
+                Verify and update the local state of each repository. On by default.
+        --local-state-store
+                Where to keep the local state, 'git:<path to state repository>' or an http(s) URL. The
+                key for each repository is derived from the forge, organization and repository name.
+        --object-index
+                Keep an object index in each repository, see VERIFY OPTIONS.
 
 AFTER-CANDIDATES OPTIONS
         --config-file
@@ -59,6 +100,27 @@ EXEMPT-TAGS OPTIONS
         --sha512
                 Output SHA-512 hashes in addition to SHA-1.
 
+LS-REMOTE-CHECK OPTIONS
+        --remote
+                Remote to list. Default is origin.
+        --local-state-store
+                Local state to compare against, see VERIFY OPTIONS. By default it is inferred from the remote URL.
+        --local-state-file
+                Local state file to compare against, same as --local-state-store with a file path.
+
+FORENSICS OPTIONS
+        --config-file
+                Config file to use.
+        --repository-uri
+                URI to the repository in the config file.
+        --local-state
+                Keep objects referenced by the local state. On by default when the config is inferred.
+        --local-state-store
+                Where the local state is kept, see VERIFY OPTIONS. This also enables local state when
+                --config-file is used.
+        --evidence-file
+                Write a JSON evidence bundle, including the raw objects, to the file.
+
 GLOBAL OPTIONS
         --help, -h
                 Show help
@@ -71,6 +133,15 @@ Verify current repo
 Verify current repo, specify config file and uri
     $ gitverify --config-file gitverify.json --repository-uri git+https://github.com/supply-chain-tools/go-sandbox.git
 
+Verify all repos for all orgs in a directory populated by repofetch
+    $ gitverify verify-all ~/src/github.com
+
+Check the remote for rollbacks before fetching
+    $ gitverify ls-remote-check && git fetch
+
+Save evidence of unreachable objects before pruning them
+    $ gitverify forensics --evidence-file evidence.json
+
 Verify repo and make sure a given commit and tag is present, that the tag points to the commit, that the commit
 is on branch 'main' and that the commit is a descendant of 'after'
     $ gitverify --commit 1f46f2053221c040ce5bcba0239bc09214a37658 --tag v0.0.1 --branch main`
@@ -101,6 +172,18 @@ func main() {
 			print("verification failed: ", err.Error(), "\n")
 			os.Exit(1)
 		}
+	case "verify-all":
+		opts, err := parseVerifyAllOptions(os.Args[2:])
+		if err != nil {
+			print("failed to parse input: ", err.Error(), "\n")
+			os.Exit(1)
+		}
+
+		err = verifyAll(opts)
+		if err != nil {
+			print("verify-all failed: ", err.Error(), "\n")
+			os.Exit(1)
+		}
 	case "after-candidates":
 		opts, err := parseGenerateOptions(os.Args[2:])
 		if err != nil {
@@ -125,6 +208,30 @@ func main() {
 			os.Exit(1)
 		}
 		fmt.Println(result)
+	case "ls-remote-check":
+		opts, err := parseLsRemoteCheckOptions(os.Args[2:])
+		if err != nil {
+			print("failed to parse input: ", err.Error(), "\n")
+			os.Exit(1)
+		}
+
+		err = lsRemoteCheck(opts)
+		if err != nil {
+			print("ls-remote-check failed: ", err.Error(), "\n")
+			os.Exit(1)
+		}
+	case "forensics":
+		opts, err := parseForensicsOptions(os.Args[2:])
+		if err != nil {
+			print("failed to parse input: ", err.Error(), "\n")
+			os.Exit(1)
+		}
+
+		err = forensics(opts)
+		if err != nil {
+			print("forensics failed: ", err.Error(), "\n")
+			os.Exit(1)
+		}
 	default:
 		fmt.Printf("unknown command: %s\n", command)
 		os.Exit(1)
@@ -137,12 +244,15 @@ type VerifyOptions struct {
 	configFilePath  string
 	repoUri         string
 	localState      bool
+	localStateStore string
+	objectIndex     bool
 }
 
 func parseVerifyOptions(osArgs []string) (*VerifyOptions, error) {
 	flags := flag.NewFlagSet("all", flag.ExitOnError)
-	var help, h, debugMode, verifyOnHEAD, verifyOnTip, localState, version bool
-	var configFilePath, repoUri, commit, tag, branch string
+	var help, h, debugMode, verifyOnHEAD, verifyOnTip, localState, version, objectIndex bool
+	var configFilePath, repoUri, commit, tag, branch, localStateStore string
+	var concurrency int
 	flags.BoolVar(&help, "help", false, "")
 	flags.BoolVar(&h, "h", false, "")
 	flags.BoolVar(&version, "version", false, "")
@@ -151,12 +261,15 @@ func parseVerifyOptions(osArgs []string) (*VerifyOptions, error) {
 	flags.StringVar(&configFilePath, "config-file", "", "")
 	flags.StringVar(&repoUri, "repository-uri", "", "")
 	flags.BoolVar(&localState, "local-state", true, "")
+	flags.StringVar(&localStateStore, "local-state-store", "", "")
 
 	flags.StringVar(&commit, "commit", "", "")
 	flags.StringVar(&tag, "tag", "", "")
 	flags.StringVar(&branch, "branch", "", "")
 	flags.BoolVar(&verifyOnHEAD, "verify-on-head", true, "")
 	flags.BoolVar(&verifyOnTip, "verify-on-tip", false, "")
+	flags.IntVar(&concurrency, "concurrency", 0, "")
+	flags.BoolVar(&objectIndex, "object-index", false, "")
 
 	args := osArgs[1:]
 	if len(osArgs) > 2 && !strings.HasPrefix(osArgs[1], "-") {
@@ -209,12 +322,17 @@ func parseVerifyOptions(osArgs []string) (*VerifyOptions, error) {
 		return nil, fmt.Errorf("when using --verify-on-tip, --branch must be specified")
 	}
 
+	if concurrency < 0 {
+		return nil, fmt.Errorf("--concurrency must be a positive number or 0 (default)")
+	}
+
 	validateOptions := &gitverify.ValidateOptions{
 		Commit:       commit,
 		Tag:          tag,
 		Branch:       branch,
 		VerifyOnHEAD: verifyOnHEAD,
 		VerifyOnTip:  verifyOnTip,
+		Concurrency:  concurrency,
 	}
 
 	if configFilePath != "" || repoUri != "" {
@@ -226,8 +344,10 @@ func parseVerifyOptions(osArgs []string) (*VerifyOptions, error) {
 			return nil, fmt.Errorf("--repository-uri must be used with --config-file\n")
 		}
 
-		// TODO consider supporting local state
-		localState = false
+		if localStateStore == "" {
+			// TODO consider supporting local state in the default location
+			localState = false
+		}
 	}
 
 	if commit != "" {
@@ -241,6 +361,77 @@ func parseVerifyOptions(osArgs []string) (*VerifyOptions, error) {
 		configFilePath:  configFilePath,
 		repoUri:         repoUri,
 		localState:      localState,
+		localStateStore: localStateStore,
+		objectIndex:     objectIndex,
+	}, nil
+}
+
+type VerifyAllOptions struct {
+	rootDir         string
+	configFilePath  string
+	reportFilePath  string
+	concurrency     int
+	localState      bool
+	localStateStore string
+	objectIndex     bool
+}
+
+func parseVerifyAllOptions(args []string) (*VerifyAllOptions, error) {
+	var debugMode, localState, help, h, objectIndex bool
+	var configFilePath, reportFilePath, localStateStore string
+	var concurrency int
+	flags := flag.NewFlagSet("verify-all", flag.ExitOnError)
+	flags.BoolVar(&debugMode, "debug", false, "")
+	flags.StringVar(&configFilePath, "config-file", "", "")
+	flags.StringVar(&reportFilePath, "report-file", "", "")
+	flags.IntVar(&concurrency, "concurrency", 4, "")
+	flags.BoolVar(&localState, "local-state", true, "")
+	flags.StringVar(&localStateStore, "local-state-store", "", "")
+	flags.BoolVar(&objectIndex, "object-index", false, "")
+
+	flags.BoolVar(&help, "help", false, "")
+	flags.BoolVar(&h, "h", false, "")
+
+	err := flags.Parse(args)
+	if err != nil || help || h {
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if len(flags.Args()) > 1 {
+		return nil, fmt.Errorf("at most one directory expected, got: %s", strings.Join(flags.Args(), ","))
+	}
+
+	if concurrency < 1 {
+		return nil, fmt.Errorf("--concurrency must be a positive number")
+	}
+
+	configureLogger(debugMode)
+
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(flags.Args()) == 1 {
+		rootDir, err = filepath.Abs(flags.Args()[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if localStateStore != "" && !strings.HasPrefix(localStateStore, "git:") && !strings.HasPrefix(localStateStore, "http://") && !strings.HasPrefix(localStateStore, "https://") {
+		return nil, fmt.Errorf("--local-state-store must be 'git:<path>' or an http(s) URL when verifying many repositories")
+	}
+
+	return &VerifyAllOptions{
+		rootDir:         rootDir,
+		configFilePath:  configFilePath,
+		reportFilePath:  reportFilePath,
+		concurrency:     concurrency,
+		localState:      localState,
+		localStateStore: localStateStore,
+		objectIndex:     objectIndex,
 	}, nil
 }
 
@@ -288,6 +479,110 @@ func parseGenerateOptions(args []string) (*GenerateOptions, error) {
 	}, nil
 }
 
+type LsRemoteCheckOptions struct {
+	repoDir         string
+	remoteName      string
+	localStateStore string
+}
+
+func parseLsRemoteCheckOptions(args []string) (*LsRemoteCheckOptions, error) {
+	var debugMode, help, h bool
+	var remoteName, localStateStore, localStatePath string
+	flags := flag.NewFlagSet("ls-remote-check", flag.ExitOnError)
+	flags.BoolVar(&debugMode, "debug", false, "")
+	flags.StringVar(&remoteName, "remote", "origin", "")
+	flags.StringVar(&localStateStore, "local-state-store", "", "")
+	flags.StringVar(&localStatePath, "local-state-file", "", "")
+
+	flags.BoolVar(&help, "help", false, "")
+	flags.BoolVar(&h, "h", false, "")
+
+	err := flags.Parse(args)
+	if err != nil || help || h {
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if len(flags.Args()) > 0 {
+		return nil, fmt.Errorf("no arguments expected, got: %s", strings.Join(flags.Args(), ","))
+	}
+
+	configureLogger(debugMode)
+
+	repoDir, err := getRepoDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if localStatePath != "" {
+		if localStateStore != "" {
+			return nil, fmt.Errorf("--local-state-file and --local-state-store cannot be used together")
+		}
+		localStateStore = localStatePath
+	}
+
+	return &LsRemoteCheckOptions{
+		repoDir:         repoDir,
+		remoteName:      remoteName,
+		localStateStore: localStateStore,
+	}, nil
+}
+
+type ForensicsOptions struct {
+	repoDir          string
+	configFilePath   string
+	repoUri          string
+	evidenceFilePath string
+	localState       bool
+	localStateStore  string
+}
+
+func parseForensicsOptions(args []string) (*ForensicsOptions, error) {
+	var debugMode, localState, help, h bool
+	var configFilePath, repoUri, evidenceFilePath, localStateStore string
+	flags := flag.NewFlagSet("forensics", flag.ExitOnError)
+	flags.BoolVar(&debugMode, "debug", false, "")
+	flags.StringVar(&configFilePath, "config-file", "", "")
+	flags.StringVar(&repoUri, "repository-uri", "", "")
+	flags.StringVar(&evidenceFilePath, "evidence-file", "", "")
+	flags.BoolVar(&localState, "local-state", true, "")
+	flags.StringVar(&localStateStore, "local-state-store", "", "")
+
+	flags.BoolVar(&help, "help", false, "")
+	flags.BoolVar(&h, "h", false, "")
+
+	err := flags.Parse(args)
+	if err != nil || help || h {
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if len(flags.Args()) > 0 {
+		return nil, fmt.Errorf("no arguments expected, got: %s", strings.Join(flags.Args(), ","))
+	}
+
+	configureLogger(debugMode)
+
+	repoDir, err := getRepoDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if configFilePath != "" && localStateStore == "" {
+		// the default local state is tied to the inferred config
+		localState = false
+	}
+
+	return &ForensicsOptions{
+		repoDir:          repoDir,
+		configFilePath:   configFilePath,
+		repoUri:          repoUri,
+		evidenceFilePath: evidenceFilePath,
+		localState:       localState,
+		localStateStore:  localStateStore,
+	}, nil
+}
+
 func getRepoDir() (string, error) {
 	basePath, err := os.Getwd()
 	if err != nil {
@@ -316,60 +611,204 @@ func configureLogger(debugMode bool) {
 }
 
 func verify(opts *VerifyOptions) error {
-	repoDir := opts.repoDir
-	validateOptions := opts.validateOptions
-	configFilePath := opts.configFilePath
-	repoUri := opts.repoUri
-	localState := opts.localState
-
 	fmt.Println("validating...")
 
-	repo, err := gitkit.OpenRepoInLocalPath(repoDir)
+	repo, err := gitkit.OpenRepoInLocalPath(opts.repoDir)
+	if err != nil {
+		return err
+	}
+
+	branchUpdates, err := verifyRepo(repo, opts.validateOptions, opts.configFilePath, opts.repoUri, opts.localState, opts.localStateStore, opts.objectIndex)
 	if err != nil {
 		return err
 	}
 
-	state := gitkit.LoadRepoState(repo)
-	sha1Hash := githash.NewGitHashFromRepoState(state, sha1.New())
-	sha512Hash := githash.NewGitHashFromRepoState(state, sha512.New())
+	for _, update := range branchUpdates {
+		fmt.Printf("%s: git log -p --full-diff %s...%s\n", update.Ref, update.From, update.To)
+	}
+
+	fmt.Println("OK")
+	return nil
+}
+
+// verifyRepo returns the protected branches that have moved forward since the local state was saved.
+func verifyRepo(repo *git.Repository, validateOptions *gitverify.ValidateOptions, configFilePath string, repoUri string, localState bool, localStateStore string, objectIndex bool) ([]gitverify.BranchUpdate, error) {
+	repoConfig, _, err := loadRepoConfig(repo, configFilePath, repoUri)
+	if err != nil {
+		return nil, err
+	}
+
+	var store gitverify.LocalStateStore
+	if localState {
+		forge, org, repoName, err := gitverify.ForgeOrgAndRepo(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		store, err = gitverify.ParseLocalStateStore(localStateStore, forge, org, repoName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	verifier := gitverify.NewVerifier(repo, repoConfig, store, &gitverify.VerifierOptions{
+		ValidateOptions: validateOptions,
+		ObjectIndex:     objectIndex,
+	})
 
-	var localStatePath string
+	result, err := verifier.Verify(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return result.BranchUpdates, nil
+}
+
+type VerifyAllReport struct {
+	Root         string       `json:"root"`
+	Passed       int          `json:"passed"`
+	Failed       int          `json:"failed"`
+	Repositories []RepoReport `json:"repositories"`
+}
 
-	var repoConfig *gitverify.RepoConfig
-	repoConfig, repoUri, err = loadRepoConfig(repo, configFilePath, repoUri)
+type RepoReport struct {
+	Path   string `json:"path"`
+	Uri    string `json:"uri,omitempty"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+func verifyAll(opts *VerifyAllOptions) error {
+	repos, err := gitkit.InferReposFromPath(opts.rootDir)
 	if err != nil {
 		return err
 	}
 
-	err = gitverify.Verify(repo, state, repoConfig, sha1Hash, sha512Hash, validateOptions)
+	if len(repos) == 0 {
+		return fmt.Errorf("no repositories found in %s", opts.rootDir)
+	}
+
+	reports := make([]RepoReport, len(repos))
+	sem := make(chan struct{}, opts.concurrency)
+	var wg sync.WaitGroup
+
+	for i, r := range repos {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, repoDir string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			reports[i] = verifyOneOfMany(repoDir, opts)
+		}(i, r.LocalRootPath())
+	}
+	wg.Wait()
+	close(sem)
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Path < reports[j].Path
+	})
+
+	report := VerifyAllReport{
+		Root:         opts.rootDir,
+		Repositories: reports,
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err = fmt.Fprintln(tw, "STATUS\tREPOSITORY\tERROR")
 	if err != nil {
 		return err
 	}
 
-	if localState {
-		if configFilePath == "" {
-			forge, org, repoName := gitverify.InferForgeOrgAndRepo(repo)
-			localStatePath, err = gitverify.GetLocalStatePath(forge, org, repoName)
-			if err != nil {
-				return err
-			}
+	for _, r := range reports {
+		status := "pass"
+		if r.Passed {
+			report.Passed++
+		} else {
+			status = "fail"
+			report.Failed++
+		}
+
+		path, err := filepath.Rel(opts.rootDir, r.Path)
+		if err != nil || path == "." {
+			path = r.Path
 		}
 
-		err = gitverify.VerifyLocalState(repo, state, repoConfig, repoUri, localStatePath, sha1Hash, sha512Hash)
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\n", status, path, r.Error)
 		if err != nil {
-			return fmt.Errorf("failed to verify local state: %w", err)
+			return err
 		}
+	}
 
-		err = gitverify.SaveLocalState(repo, state, repoConfig, repoUri, localStatePath, sha1Hash, sha512Hash)
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d passed, %d failed\n", report.Passed, report.Failed)
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	if opts.reportFilePath != "" {
+		err = os.WriteFile(opts.reportFilePath, data, 0644)
 		if err != nil {
-			return fmt.Errorf("failed to save local state: %w", err)
+			return fmt.Errorf("failed to write report: %w", err)
 		}
+	} else {
+		fmt.Println(string(data))
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed verification", report.Failed, len(reports))
 	}
 
-	fmt.Println("OK")
 	return nil
 }
 
+func verifyOneOfMany(repoDir string, opts *VerifyAllOptions) RepoReport {
+	report := RepoReport{
+		Path: repoDir,
+	}
+
+	repo, err := gitkit.OpenRepoInLocalPath(repoDir)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	forge, org, repoName, err := gitverify.ForgeOrgAndRepo(repo)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	report.Uri = "git+https://" + forge + "/" + org + "/" + repoName + ".git"
+
+	configFilePath := opts.configFilePath
+	if configFilePath == "" {
+		configFilePath, err = gitverify.GetConfigPath(forge, org)
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+	}
+
+	slog.Debug("verifying", "path", repoDir, "uri", report.Uri, "config", configFilePath)
+
+	_, err = verifyRepo(repo, nil, configFilePath, report.Uri, opts.localState, opts.localStateStore, opts.objectIndex)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	report.Passed = true
+	return report
+}
+
 func loadRepoConfig(repo *git.Repository, configFilePath string, inputRepoUri string) (config *gitverify.RepoConfig, repoUri string, err error) {
 	repoUri = inputRepoUri
 	if configFilePath == "" {
@@ -492,9 +931,13 @@ func exemptTags(opts *GenerateOptions) (string, error) {
 		return "", err
 	}
 
-	state := gitkit.LoadRepoState(repo)
-	sha1Hash := githash.NewGitHashFromRepoState(state, sha1.New())
-	sha512Hash := githash.NewGitHashFromRepoState(state, sha512.New())
+	state, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		return "", err
+	}
+
+	sha1Hash := githash.NewGitHashFromRepoStateFunc(state, sha1.New)
+	sha512Hash := githash.NewGitHashFromRepoStateFunc(state, sha512.New)
 	exemptTags, err := gitverify.ComputeExemptTags(repo, state, sha1Hash, sha512Hash, useSHA512)
 	if err != nil {
 		return "", err
@@ -508,6 +951,155 @@ func exemptTags(opts *GenerateOptions) (string, error) {
 	return string(data), nil
 }
 
+func lsRemoteCheck(opts *LsRemoteCheckOptions) error {
+	repo, err := gitkit.OpenRepoInLocalPath(opts.repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to open repo: %w", err)
+	}
+
+	forge, org, repoName, err := gitverify.ForgeOrgAndRepo(repo)
+	if err != nil {
+		return err
+	}
+
+	store, err := gitverify.ParseLocalStateStore(opts.localStateStore, forge, org, repoName)
+	if err != nil {
+		return err
+	}
+
+	localState, err := store.Load(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to load local state: %w", err)
+	}
+
+	if localState == nil {
+		return fmt.Errorf("no local state found, run gitverify first")
+	}
+
+	advertised, err := gitverify.ListRemote(repo, opts.remoteName, nil)
+	if err != nil {
+		return err
+	}
+
+	checks, err := gitverify.CheckRemoteRefs(repo, opts.remoteName, advertised, localState)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err = fmt.Fprintln(tw, "STATUS\tREF\tLOCAL STATE\tREMOTE")
+	if err != nil {
+		return err
+	}
+
+	warnings := 0
+	for _, check := range checks {
+		if check.Warning() {
+			warnings++
+		}
+
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", check.Status, check.RemoteRef, check.LocalHash, check.RemoteHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if warnings > 0 {
+		return fmt.Errorf("%d of %d refs have been deleted, moved or rolled back on the remote", warnings, len(checks))
+	}
+
+	fmt.Println("OK")
+	return nil
+}
+
+func forensics(opts *ForensicsOptions) error {
+	repo, err := gitkit.OpenRepoInLocalPath(opts.repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to open repo: %w", err)
+	}
+
+	repoConfig, repoUri, err := loadRepoConfig(repo, opts.configFilePath, opts.repoUri)
+	if err != nil {
+		return err
+	}
+
+	var store gitverify.LocalStateStore
+	if opts.localState {
+		forge, org, repoName := gitverify.InferForgeOrgAndRepo(repo)
+		store, err = gitverify.ParseLocalStateStore(opts.localStateStore, forge, org, repoName)
+		if err != nil {
+			return err
+		}
+	}
+
+	state, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		return err
+	}
+
+	sha1Hash := githash.NewGitHashFromRepoStateFunc(state, sha1.New)
+	sha512Hash := githash.NewGitHashFromRepoStateFunc(state, sha512.New)
+
+	report, err := gitverify.Forensics(context.Background(), repo, state, repoConfig, repoUri, store, sha1Hash, sha512Hash)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err = fmt.Fprintln(tw, "TYPE\tHASH\tSTATUS\tSIGNER\tPRUNE\tPOLICY")
+	if err != nil {
+		return err
+	}
+
+	safeToPrune := 0
+	for _, o := range report.Objects {
+		prune := "yes"
+		if o.SafeToPrune {
+			safeToPrune++
+		} else {
+			prune = "no, " + o.KeepReason
+		}
+
+		policy := o.PolicyError
+		if policy == "" {
+			policy = "passes"
+		}
+
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", o.Type, *o.Hash.SHA1, o.Status, o.Signer, prune, policy)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d unreachable objects, %d safe to prune\n", len(report.Objects), safeToPrune)
+
+	if opts.evidenceFilePath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal evidence: %w", err)
+		}
+
+		err = os.WriteFile(opts.evidenceFilePath, data, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write evidence: %w", err)
+		}
+
+		fmt.Printf("evidence written to %s\n", opts.evidenceFilePath)
+	}
+
+	return nil
+}
+
 func printVersion() error {
 	info, ok := debug.ReadBuildInfo()
 	if !ok {
@@ -6,8 +6,10 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"log/slog"
 
@@ -16,28 +18,89 @@ import (
 
 const usage = `Usage:
   repofetch [options] <path>...
+  repofetch [options] --repos-file <file>
+  repofetch sync [options] [sync options] <path>...
+
+Commands:
+  sync           Clone or fetch like the default command, and keep a manifest of the fetched repos with the
+                 remote HEAD and a digest of all refs of each. Repos that were archived, renamed or deleted
+                 upstream are marked in the manifest. Only repos that are already in the manifest are checked
+                 for renames and deletions.
 
 Options:
   --gh-auth      Use GitHub CLI for authentication
-  --token        Set GitHub token
+  --token        Set token, used for github.com, or for all hosts if --forge is set. Use --credentials-file
+                 to set tokens for other hosts
+  --forge        Forge of self-hosted instances, 'github', 'gitlab' or 'gitea' (also Forgejo).
+                 Inferred for github.com, gitlab.com, codeberg.org, gitea.com and hosts like gitlab.example.com
+  --ssh          Clone over SSH with ssh-agent, or the key of --ssh-key. Existing clones keep their URL
+  --ssh-key      SSH private key file, the passphrase is read from REPOFETCH_SSH_PASSPHRASE if set
+  --credential-helper
+                 Use the git credential helpers for HTTPS and the API if there is no token
+  --credentials-file
+                 JSON file with credentials per host, takes precedence over the options above, e.g.
+                 {"hosts": {"gitlab.example.com": {"tokenEnv": "CORP_TOKEN", "ssh": true, "sshKeyFile": "~/.ssh/corp"}}}
   --depth        Set cloning/fetching depth
   --concurrency  Set number of concurrent fetches (default: 10)
   --bare         Enable bare cloning
   --debug        Enable debug logging
+  --dry-run      List the repos that would be fetched without fetching them
+  --repos-file   Fetch the repos in the file instead of paths, and check that pinned refs point to the expected
+                 commits. One '<url> [ref] [commit]' per line, or JSON {"repositories": [{"url", "ref", "commit"}]}.
+                 Filters and the resume file are not used
+  --resume-file  Progress file, a re-run after failures skips the repos that were fetched and the file is
+                 removed once all succeed. It is ignored after 24 hours, and not used by sync, which has the
+                 manifest (default: .repofetch-resume.json, '' to disable)
   -h, --help     Display help
 
+Filter options (only apply when fetching all repos of an org/user/group):
+  --forks         Forks to 'include', 'exclude' or 'only' (default: include)
+  --archived      Archived repos to 'include', 'exclude' or 'only' (default: include)
+  --visibility    Only repos with visibility 'public', 'private' or 'internal'
+  --language      Only repos with one of the comma-separated primary languages
+  --topic         Only repos with one of the comma-separated topics
+  --pushed-after  Only repos pushed to after the date, YYYY-MM-DD or RFC 3339
+  --name          Only repos with a name matching one of the comma-separated glob patterns
+
+Sync options:
+  --manifest        Manifest file (default: repofetch-manifest.json)
+  --verify          Run gitverify in each new repo or repo with changed refs, the manifest is only
+                    advanced if it passes
+  --verify-command  Command to run for --verify (default: gitverify)
+  --prune           Remove the local copy of repos that were deleted upstream
+
 Environment Variables:
   GITHUB_TOKEN  GitHub token (optional)
+  GITLAB_TOKEN  GitLab token (optional)
+  GITEA_TOKEN   Gitea/Forgejo token (optional)
+  REPOFETCH_SSH_PASSPHRASE  Passphrase of the --ssh-key (optional)
 
 Notes:
   If no token is provided, repositories will be cloned unauthenticated.
+  Repos are placed in <host>/<org>/<repo>, or <org>/<repo> if the current directory is named after the host.
+  Nested GitLab groups are joined with '+', e.g. gitlab.com/group+subgroup/repo.
 
 Examples:
   Fetch all repos for one org/user:
     $ repofetch github.com/torvalds
 
   Fetch one repo:
-    $ repofetch github.com/torvalds/linux`
+    $ repofetch github.com/torvalds/linux
+
+  Fetch a curated list of repos and check the pins:
+    $ repofetch --repos-file audit-scope.txt
+
+  Fetch private repos over SSH with the keys in ssh-agent:
+    $ repofetch --ssh github.com/my-org
+
+  Fetch a GitLab group including subgroups:
+    $ repofetch gitlab.com/gitlab-org/security-products
+
+  List the Go repos of an org that are not forks or archived:
+    $ repofetch --dry-run --forks exclude --archived exclude --language go github.com/kubernetes
+
+  Mirror an org and verify every update:
+    $ repofetch sync --verify github.com/supply-chain-tools`
 
 type options struct {
 	token         string
@@ -46,10 +109,30 @@ type options struct {
 	depth         int
 	concurrency   int
 	bare          bool
+	sync          bool
+	manifestPath  string
+	verify        bool
+	verifyCommand string
+	prune         bool
+	dryRun        bool
+	forks         string
+	archived      string
+	visibility    string
+	languages     string
+	topics        string
+	pushedAfter   string
+	names         string
+	forge         string
+	resumeFile    string
+	reposFile     string
+	ssh           bool
+	sshKey        string
+	credHelper    bool
+	credsFile     string
 }
 
 func main() {
-	args, opts := parseArgsAndOptions()
+	args, opts := parseArgsAndOptions(os.Args[1:])
 
 	logLevel := slog.LevelInfo
 	if opts.debug {
@@ -64,37 +147,72 @@ func main() {
 
 	slog.Debug("Running repofetch", "args", args, "options", opts)
 
-	token, err := getToken(opts)
-	if err != nil {
-		slog.Debug("Failed to get token", "error", err)
+	var hostCredentials map[string]*gitkit.Credentials
+	if opts.credsFile != "" {
+		var err error
+		hostCredentials, err = gitkit.LoadHostCredentials(opts.credsFile)
+		if err != nil {
+			fmt.Printf("[error]: %s\n", err)
+			os.Exit(1)
+		}
 	}
 
-	client, err := createGitHubClient(token)
-	if err != nil {
-		slog.Debug("Failed to create GitHub client", "error", err)
+	clients := newForgeClients(opts, hostCredentials)
+
+	if opts.sync {
+		if err := syncRepositories(clients, args, opts); err != nil {
+			fmt.Printf("[error]: sync failed: %s\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	if err := fetchRepositories(client, args, opts); err != nil {
-		slog.Debug("Failed to fetch repositories", "error", err)
+	if opts.reposFile != "" {
+		if len(args) > 0 {
+			fmt.Println("[error]: paths can't be combined with --repos-file")
+			os.Exit(1)
+		}
+
+		if err := fetchRepoList(clients, opts); err != nil {
+			fmt.Printf("[error]: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := fetchRepositories(clients, args, opts); err != nil {
+		fmt.Printf("[error]: %s\n", err)
 		os.Exit(1)
 	}
 }
 
-func getToken(opts options) (token string, err error) {
-	if opts.token != "" {
-		token = opts.token
-	} else if opts.useGitHubAuth {
-		token, err = getTokenFromCLI()
-		if err != nil {
-			return "", fmt.Errorf("failed to get token from CLI: %w", err)
+// getToken returns the token for a host. --token is only used for github.com unless --forge is set, so that
+// a GitHub token is never sent to other hosts.
+func getToken(opts options, forgeType gitkit.ForgeType, host string) (token string, err error) {
+	if opts.token != "" && (host == "github.com" || opts.forge != "") {
+		return opts.token, nil
+	}
+
+	switch forgeType {
+	case gitkit.ForgeGitHub:
+		if opts.useGitHubAuth {
+			token, err = getTokenFromCLI()
+			if err != nil {
+				return "", fmt.Errorf("failed to get token from CLI: %w", err)
+			}
+			return token, nil
 		}
-	} else {
 		token, err = getTokenFromEnv("GITHUB_TOKEN")
-		if err != nil {
-			return "", fmt.Errorf("failed to get token from environment: %w", err)
-		}
+	case gitkit.ForgeGitLab:
+		token, err = getTokenFromEnv("GITLAB_TOKEN")
+	case gitkit.ForgeGitea:
+		token, err = getTokenFromEnv("GITEA_TOKEN")
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get token from environment: %w", err)
 	}
-	return token, err
+	return token, nil
 }
 
 func getTokenFromEnv(name string) (string, error) {
@@ -114,101 +232,380 @@ func getTokenFromCLI() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func createGitHubClient(token string) (*gitkit.GitHubClient, error) {
-	if token == "" {
-		slog.Debug("Using unauthenticated client")
-		return gitkit.NewGitHubClient(), nil
+// forgeClients creates one client per host, picking the forge from the host unless --forge is set.
+type forgeClients struct {
+	opts        options
+	credentials map[string]*gitkit.Credentials
+	mutex       sync.Mutex
+	clients     map[string]gitkit.ForgeClient
+}
+
+func newForgeClients(opts options, hostCredentials map[string]*gitkit.Credentials) *forgeClients {
+	return &forgeClients{
+		opts:        opts,
+		credentials: hostCredentials,
+		clients:     make(map[string]gitkit.ForgeClient),
 	}
-	slog.Debug("Using authenticated client")
-	return gitkit.NewAuthenticatedGitHubClient(token), nil
 }
 
-func parseArgsAndOptions() ([]string, options) {
-	flag.Usage = func() {
-		fmt.Fprintln(os.Stderr, usage)
+// forURI returns the client for the host of the URI and the path of the owner or repository on it.
+func (fc *forgeClients) forURI(uri string) (gitkit.ForgeClient, string, error) {
+	host, repoPath, err := gitkit.ParseForgeURI(uri)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := fc.forHost(host)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return client, repoPath, nil
+}
+
+func (fc *forgeClients) forHost(host string) (gitkit.ForgeClient, error) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	host = strings.ToLower(host)
+	client, found := fc.clients[host]
+	if found {
+		return client, nil
+	}
+
+	forgeType := gitkit.ForgeType(fc.opts.forge)
+	if forgeType == "" {
+		forgeType = gitkit.DetectForgeType(host)
+	}
+
+	if forgeType == "" {
+		return nil, fmt.Errorf("unable to infer the forge of '%s', use --forge", host)
 	}
 
+	credentials := fc.hostCredentials(host)
+	if credentials.TokenEnv == "" {
+		token, err := getToken(fc.opts, forgeType, host)
+		if err != nil {
+			slog.Debug("Failed to get token", "host", host, "error", err)
+		}
+		credentials.Token = token
+	}
+
+	client, err := gitkit.NewForgeClientWithCredentials(forgeType, host, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up credentials for %s: %w", host, err)
+	}
+
+	slog.Debug("Created client", "host", host, "forge", forgeType, "ssh", credentials.SSH, "credentialHelper", credentials.CredentialHelper)
+
+	fc.clients[host] = client
+	return client, nil
+}
+
+// hostCredentials returns the credentials from --credentials-file for the host, or from the options if the host
+// is not in the file.
+func (fc *forgeClients) hostCredentials(host string) *gitkit.Credentials {
+	fileCredentials, found := fc.credentials[host]
+	if found {
+		credentials := *fileCredentials
+		return &credentials
+	}
+
+	credentials := &gitkit.Credentials{
+		SSH:              fc.opts.ssh || fc.opts.sshKey != "",
+		SSHKeyFile:       fc.opts.sshKey,
+		CredentialHelper: fc.opts.credHelper,
+	}
+
+	if fc.opts.sshKey != "" && os.Getenv("REPOFETCH_SSH_PASSPHRASE") != "" {
+		credentials.SSHKeyPassphraseEnv = "REPOFETCH_SSH_PASSPHRASE"
+	}
+
+	return credentials
+}
+
+// mirrorRoot returns the directory for the repositories of host. Repositories are laid out as
+// '<host>/<org>/<repo>', so the current directory is used as is if it is already named after the host.
+// Repositories synced before the host was part of the layout are in '<org>/<repo>', and are cloned again
+// into the new layout unless they are moved there.
+func mirrorRoot(cwd string, host string) string {
+	if strings.EqualFold(filepath.Base(cwd), host) {
+		return cwd
+	}
+
+	return filepath.Join(cwd, host)
+}
+
+// cloneURL falls back to '<host>/<full name>.git' if the forge didn't return a clone URL.
+func cloneURL(client gitkit.ForgeClient, info *gitkit.RepositoryInfo) string {
+	if info.CloneURL != "" {
+		return info.CloneURL
+	}
+
+	return "https://" + client.Host() + "/" + info.FullName + ".git"
+}
+
+func parseArgsAndOptions(osArgs []string) ([]string, options) {
 	opts := options{}
 
-	help := flag.Bool("help", false, "")
-	flag.BoolVar(help, "h", false, "")
-	flag.BoolVar(&opts.debug, "debug", false, "")
-	flag.BoolVar(&opts.useGitHubAuth, "gh-auth", false, "")
-	flag.BoolVar(&opts.bare, "bare", false, "")
-	flag.StringVar(&opts.token, "token", "", "")
-	flag.IntVar(&opts.concurrency, "concurrency", 10, "")
-	flag.IntVar(&opts.depth, "depth", 0, "")
+	if len(osArgs) > 0 && osArgs[0] == "sync" {
+		opts.sync = true
+		osArgs = osArgs[1:]
+	}
+
+	flags := flag.NewFlagSet("repofetch", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+	}
+
+	help := flags.Bool("help", false, "")
+	flags.BoolVar(help, "h", false, "")
+	flags.BoolVar(&opts.debug, "debug", false, "")
+	flags.BoolVar(&opts.useGitHubAuth, "gh-auth", false, "")
+	flags.BoolVar(&opts.bare, "bare", false, "")
+	flags.StringVar(&opts.token, "token", "", "")
+	flags.StringVar(&opts.forge, "forge", "", "")
+	flags.BoolVar(&opts.ssh, "ssh", false, "")
+	flags.StringVar(&opts.sshKey, "ssh-key", "", "")
+	flags.BoolVar(&opts.credHelper, "credential-helper", false, "")
+	flags.StringVar(&opts.credsFile, "credentials-file", "", "")
+	flags.StringVar(&opts.resumeFile, "resume-file", ".repofetch-resume.json", "")
+	if !opts.sync {
+		flags.StringVar(&opts.reposFile, "repos-file", "", "")
+	}
+	flags.IntVar(&opts.concurrency, "concurrency", 10, "")
+	flags.IntVar(&opts.depth, "depth", 0, "")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "")
+	flags.StringVar(&opts.forks, "forks", "include", "")
+	flags.StringVar(&opts.archived, "archived", "include", "")
+	flags.StringVar(&opts.visibility, "visibility", "", "")
+	flags.StringVar(&opts.languages, "language", "", "")
+	flags.StringVar(&opts.topics, "topic", "", "")
+	flags.StringVar(&opts.pushedAfter, "pushed-after", "", "")
+	flags.StringVar(&opts.names, "name", "", "")
+
+	if opts.sync {
+		flags.StringVar(&opts.manifestPath, "manifest", "repofetch-manifest.json", "")
+		flags.BoolVar(&opts.verify, "verify", false, "")
+		flags.StringVar(&opts.verifyCommand, "verify-command", "gitverify", "")
+		flags.BoolVar(&opts.prune, "prune", false, "")
+	}
 
-	flag.Parse()
-	args := flag.Args()
+	_ = flags.Parse(osArgs)
+	args := flags.Args()
 
-	if *help || len(args) == 0 {
-		flag.Usage()
+	if *help || (len(args) == 0 && opts.reposFile == "") {
+		flags.Usage()
 		os.Exit(0)
 	}
 
 	return args, opts
 }
 
-func fetchRepositories(client *gitkit.GitHubClient, uris []string, opts options) error {
-	var reposToClone []string
+// forgeOptions converts the clone and filter flags.
+func forgeOptions(opts options) (*gitkit.ForgeOptions, error) {
+	forks, err := parseInclusion("forks", opts.forks)
+	if err != nil {
+		return nil, err
+	}
 
-	cloneOpts := gitkit.GitHubOptions{
-		Depth: opts.depth,
-		Bare:  opts.bare,
+	archived, err := parseInclusion("archived", opts.archived)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, uri := range uris {
-		normalizedURIs, err := validateAndNormalizeURI(uri)
+	forgeOpts := &gitkit.ForgeOptions{
+		Depth:        opts.depth,
+		Bare:         opts.bare,
+		Fork:         forks,
+		Archived:     archived,
+		Visibility:   opts.visibility,
+		Languages:    splitList(opts.languages),
+		Topics:       splitList(opts.topics),
+		NamePatterns: splitList(opts.names),
+	}
+
+	if opts.pushedAfter != "" {
+		forgeOpts.PushedAfter, err = time.Parse(time.DateOnly, opts.pushedAfter)
 		if err != nil {
-			return fmt.Errorf("failed to normalize path %s: %w", uri, err)
+			forgeOpts.PushedAfter, err = time.Parse(time.RFC3339, opts.pushedAfter)
+			if err != nil {
+				return nil, fmt.Errorf("--pushed-after must be YYYY-MM-DD or RFC 3339, got '%s'", opts.pushedAfter)
+			}
 		}
+	}
 
-		repos, err := client.GetRepositories(normalizedURIs)
-		if err != nil {
-			return fmt.Errorf("failed to list repositories for path %s: %w", normalizedURIs, err)
+	err = forgeOpts.ValidateFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	return forgeOpts, nil
+}
+
+// parseInclusion maps 'include' to nil, i.e. no filter, 'only' to true and 'exclude' to false.
+func parseInclusion(name string, value string) (*bool, error) {
+	switch value {
+	case "include":
+		return nil, nil
+	case "only":
+		only := true
+		return &only, nil
+	case "exclude":
+		only := false
+		return &only, nil
+	default:
+		return nil, fmt.Errorf("--%s must be 'include', 'exclude' or 'only', got '%s'", name, value)
+	}
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
 		}
-		reposToClone = append(reposToClone, repos...)
 	}
 
-	sem := make(chan struct{}, opts.concurrency)
-	var wg sync.WaitGroup
+	return values
+}
+
+func fetchRepositories(clients *forgeClients, uris []string, opts options) error {
+	type mirrorJob struct {
+		client gitkit.ForgeClient
+		url    string
+	}
+
+	cloneOpts, err := forgeOptions(opts)
+	if err != nil {
+		return err
+	}
 
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get current directory: %w", err)
 	}
 
-	for _, repoURL := range reposToClone {
+	var resume *resumeState
+	if opts.resumeFile != "" && !opts.dryRun {
+		resume, err = loadResumeState(opts.resumeFile, uris, filterSummary(opts), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	summary := &fetchSummary{}
+	var reposToClone []mirrorJob
+
+	if resume != nil && len(resume.Repositories) > 0 {
+		slog.Info("Resuming from previous run", "file", opts.resumeFile, "repositories", len(resume.Repositories), "completed", len(resume.completed))
+
+		for _, repoURL := range resume.Repositories {
+			client, _, err := clients.forURI(repoURL)
+			if err != nil {
+				return fmt.Errorf("invalid URL %s in resume file %s: %w", repoURL, opts.resumeFile, err)
+			}
+			reposToClone = append(reposToClone, mirrorJob{client: client, url: repoURL})
+		}
+	} else {
+		listedAll := true
+		for _, uri := range uris {
+			client, repoPath, err := clients.forURI(uri)
+			if err != nil {
+				return fmt.Errorf("failed to parse path %s: %w", uri, err)
+			}
+
+			repos, err := client.ListRepositories(repoPath, cloneOpts)
+			if err != nil {
+				fmt.Printf("[error]: failed to list repositories for path %s: %s\n", uri, err)
+				summary.addFailed(uri, "failed to list repositories: "+err.Error())
+				listedAll = false
+				continue
+			}
+
+			for _, repo := range repos {
+				reposToClone = append(reposToClone, mirrorJob{client: client, url: cloneURL(client, repo)})
+			}
+		}
+
+		// a partial listing is not reused, the next run lists again
+		if resume != nil && listedAll {
+			repoURLs := make([]string, 0, len(reposToClone))
+			for _, job := range reposToClone {
+				repoURLs = append(repoURLs, job.url)
+			}
+
+			err = resume.setRepositories(repoURLs)
+			if err != nil {
+				return fmt.Errorf("failed to write resume file: %w", err)
+			}
+		}
+	}
+
+	if opts.dryRun {
+		for _, job := range reposToClone {
+			fmt.Printf("[dry-run]: %s\n", job.url)
+		}
+		return nil
+	}
+
+	sem := make(chan struct{}, opts.concurrency)
+	var wg sync.WaitGroup
+
+	for _, job := range reposToClone {
+		if resume != nil && resume.isCompleted(job.url) {
+			summary.addSkipped(job.url, "fetched by a previous run")
+			continue
+		}
+
 		wg.Add(1)
 		sem <- struct{}{}
 
-		go func(repoURL string) {
+		go func(client gitkit.ForgeClient, repoURL string) {
 			defer wg.Done()
 			defer func() { <-sem }()
 
-			result, err := client.CloneOrFetchRepo(repoURL, cwd, &io.Discard, &cloneOpts)
+			result, err := client.CloneOrFetchRepo(repoURL, mirrorRoot(cwd, client.Host()), &io.Discard, cloneOpts)
 			if err != nil {
-				fmt.Printf("[error]: %s %s: %s\n", result.GitCommand, result.RepoURL, result.Error)
-			} else {
-				fmt.Printf("[done]: %s %s\n", result.GitCommand, result.RepoURL)
+				fmt.Printf("[error]: %s: %s\n", repoURL, err)
+				summary.addFailed(repoURL, err.Error())
+				return
 			}
-		}(repoURL)
+
+			fmt.Printf("[done]: %s %s\n", result.GitCommand, result.RepoURL)
+			summary.addSucceeded(repoURL)
+
+			if resume != nil {
+				err = resume.markCompleted(repoURL)
+				if err != nil {
+					fmt.Printf("[error]: failed to write resume file: %s\n", err)
+				}
+			}
+		}(job.client, job.url)
 	}
 	wg.Wait()
 	close(sem)
 
-	return nil
-}
+	summary.print()
 
-func validateAndNormalizeURI(gitHubURI string) (string, error) {
-	if strings.HasPrefix(gitHubURI, "github.com/") {
-		gitHubURI = "https://" + gitHubURI
+	if len(summary.failed) > 0 {
+		if resume != nil {
+			return fmt.Errorf("%d failed, run the same command again to retry them", len(summary.failed))
+		}
+		return fmt.Errorf("%d failed", len(summary.failed))
 	}
 
-	if !strings.HasPrefix(gitHubURI, "https://github.com/") {
-		return "", fmt.Errorf("invalid URI '%s'; must be prefixed with 'https://github.com/' or 'github.com/'", gitHubURI)
+	if resume != nil {
+		return resume.remove()
 	}
 
-	return gitHubURI, nil
+	return nil
+}
+
+// filterSummary identifies the options that change what is fetched, a resume file is only reused if
+// they are the same.
+func filterSummary(opts options) string {
+	return fmt.Sprintf("forks=%s archived=%s visibility=%s language=%s topic=%s pushed-after=%s name=%s forge=%s bare=%t depth=%d",
+		opts.forks, opts.archived, opts.visibility, opts.languages, opts.topics, opts.pushedAfter, opts.names, opts.forge, opts.bare, opts.depth)
 }
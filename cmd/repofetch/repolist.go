@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+// fetchRepoList clones or fetches the repos in the list into the standard layout and checks the pinned
+// refs. Repos that fail to fetch or don't match the pin are reported in the summary.
+func fetchRepoList(clients *forgeClients, opts options) error {
+	pins, err := gitkit.LoadRepoList(opts.reposFile)
+	if err != nil {
+		return err
+	}
+
+	cloneOpts, err := forgeOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	type pinJob struct {
+		client   gitkit.ForgeClient
+		cloneURL string
+		pin      gitkit.RepoPin
+	}
+
+	jobs := make([]pinJob, 0, len(pins))
+	// repos are fetched concurrently into the same directory, so each can only be listed once
+	seen := make(map[string]string)
+	for _, pin := range pins {
+		client, repoPath, err := clients.forURI(pin.Url)
+		if err != nil {
+			return fmt.Errorf("invalid url '%s' in %s: %w", pin.Url, opts.reposFile, err)
+		}
+
+		cloneURL := "https://" + client.Host() + "/" + repoPath + ".git"
+		previous, found := seen[strings.ToLower(cloneURL)]
+		if found {
+			return fmt.Errorf("'%s' and '%s' in %s are the same repository, list it once", previous, pin.Url, opts.reposFile)
+		}
+		seen[strings.ToLower(cloneURL)] = pin.Url
+
+		jobs = append(jobs, pinJob{
+			client:   client,
+			cloneURL: cloneURL,
+			pin:      pin,
+		})
+	}
+
+	if opts.dryRun {
+		for _, job := range jobs {
+			fmt.Printf("[dry-run]: %s %s\n", job.cloneURL, job.pin.Ref)
+		}
+		return nil
+	}
+
+	summary := &fetchSummary{}
+	sem := make(chan struct{}, opts.concurrency)
+	var wg sync.WaitGroup
+
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(job pinJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := job.client.CloneOrFetchRepo(job.cloneURL, mirrorRoot(cwd, job.client.Host()), &io.Discard, cloneOpts)
+			if err != nil {
+				fmt.Printf("[error]: %s: %s\n", job.cloneURL, err)
+				summary.addFailed(job.cloneURL, err.Error())
+				return
+			}
+
+			repo, err := git.PlainOpen(result.RepoPath)
+			if err != nil {
+				summary.addFailed(job.cloneURL, err.Error())
+				return
+			}
+
+			commit, matched, err := job.pin.Check(repo)
+			if err != nil {
+				fmt.Printf("[mismatch]: %s: %s\n", job.cloneURL, err)
+				summary.addFailed(job.cloneURL, err.Error())
+				return
+			}
+
+			if !matched {
+				reason := fmt.Sprintf("ref '%s' is %s, expected %s", refOrDefault(job.pin.Ref), commit, job.pin.Commit)
+				fmt.Printf("[mismatch]: %s: %s\n", job.cloneURL, reason)
+				summary.addFailed(job.cloneURL, reason)
+				return
+			}
+
+			fmt.Printf("[done]: %s %s %s at %s\n", result.GitCommand, result.RepoURL, refOrDefault(job.pin.Ref), commit)
+			summary.addSucceeded(job.cloneURL)
+		}(job)
+	}
+	wg.Wait()
+	close(sem)
+
+	summary.print()
+
+	if len(summary.failed) > 0 {
+		return fmt.Errorf("%d of %d repositories failed or did not match the pin", len(summary.failed), len(jobs))
+	}
+
+	return nil
+}
+
+func refOrDefault(ref string) string {
+	if ref == "" {
+		return "HEAD"
+	}
+	return ref
+}
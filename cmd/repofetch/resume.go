@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// resumeMaxAge is how long a resume file is used, an older one is ignored so that the repositories are listed
+// and fetched again rather than skipped based on a stale listing.
+const resumeMaxAge = 24 * time.Hour
+
+// resumeState is checkpointed after every fetched repo, so that an interrupted run, e.g. by rate limits,
+// can be picked up by running the same command again. It is only reused for the same paths and filters, and
+// for resumeMaxAge after the run that created it started.
+type resumeState struct {
+	Paths     []string  `json:"paths"`
+	Filters   string    `json:"filters"`
+	StartedAt time.Time `json:"startedAt"`
+	// Repositories is the clone URLs that were listed, empty if listing failed for any of the paths.
+	Repositories []string `json:"repositories,omitempty"`
+	Completed    []string `json:"completed"`
+
+	path      string
+	mutex     sync.Mutex
+	completed map[string]bool
+}
+
+// loadResumeState returns an empty state if the file does not exist, is for another run or is older than resumeMaxAge.
+func loadResumeState(path string, uris []string, filters string, now time.Time) (*resumeState, error) {
+	state := &resumeState{
+		Paths:     uris,
+		Filters:   filters,
+		StartedAt: now.UTC(),
+		path:      path,
+		completed: make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	saved := &resumeState{}
+	err = json.Unmarshal(data, saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse resume file %s: %w", path, err)
+	}
+
+	if !slices.Equal(saved.Paths, uris) || saved.Filters != filters {
+		slog.Info("Ignoring resume file for other paths or filters", "path", path)
+		return state, nil
+	}
+
+	if now.Sub(saved.StartedAt) > resumeMaxAge {
+		slog.Info("Ignoring resume file older than the max age", "path", path, "startedAt", saved.StartedAt, "maxAge", resumeMaxAge)
+		return state, nil
+	}
+
+	state.StartedAt = saved.StartedAt
+	state.Repositories = saved.Repositories
+	for _, url := range saved.Completed {
+		state.completed[url] = true
+	}
+
+	return state, nil
+}
+
+func (r *resumeState) isCompleted(url string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.completed[url]
+}
+
+// markCompleted records the repo and writes the file.
+func (r *resumeState) markCompleted(url string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.completed[url] = true
+	return r.save()
+}
+
+func (r *resumeState) setRepositories(urls []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Repositories = urls
+	return r.save()
+}
+
+// remove deletes the file once every repo has been fetched, so the next run starts from scratch.
+func (r *resumeState) remove() error {
+	err := os.Remove(r.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (r *resumeState) save() error {
+	r.Completed = make([]string, 0, len(r.completed))
+	for url := range r.completed {
+		r.Completed = append(r.Completed, url)
+	}
+	sort.Strings(r.Completed)
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(data, '\n'))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), r.path)
+}
+
+type outcome struct {
+	url    string
+	reason string
+}
+
+// fetchSummary collects the outcome of every repo for the summary printed at the end of a run.
+type fetchSummary struct {
+	mutex     sync.Mutex
+	succeeded []string
+	failed    []outcome
+	skipped   []outcome
+}
+
+func (s *fetchSummary) addSucceeded(url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.succeeded = append(s.succeeded, url)
+}
+
+func (s *fetchSummary) addFailed(url string, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failed = append(s.failed, outcome{url: url, reason: reason})
+}
+
+func (s *fetchSummary) addSkipped(url string, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.skipped = append(s.skipped, outcome{url: url, reason: reason})
+}
+
+func (s *fetchSummary) print() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fmt.Printf("\nSummary: %d succeeded, %d failed, %d skipped\n", len(s.succeeded), len(s.failed), len(s.skipped))
+
+	for _, o := range sortOutcomes(s.failed) {
+		fmt.Printf("  [failed]: %s: %s\n", o.url, o.reason)
+	}
+
+	for _, o := range sortOutcomes(s.skipped) {
+		fmt.Printf("  [skipped]: %s: %s\n", o.url, o.reason)
+	}
+}
+
+func sortOutcomes(outcomes []outcome) []outcome {
+	sorted := slices.Clone(outcomes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].url < sorted[j].url
+	})
+	return sorted
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+// listedRepo is a repository to sync, keyed by '<host>/<owner>/<repo>' like the manifest.
+type listedRepo struct {
+	client gitkit.ForgeClient
+	info   *gitkit.RepositoryInfo
+}
+
+// syncRepositories clones or fetches the repositories like fetchRepositories and records the result in the
+// manifest. Repositories in the manifest that are no longer listed upstream are checked for renames and
+// deletions. The HEAD in the manifest is only advanced when the optional verification passes.
+func syncRepositories(clients *forgeClients, uris []string, opts options) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	cloneOpts, err := forgeOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := gitkit.LoadManifest(opts.manifestPath)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]listedRepo)
+	for _, uri := range uris {
+		client, repoPath, err := clients.forURI(uri)
+		if err != nil {
+			return fmt.Errorf("failed to parse path %s: %w", uri, err)
+		}
+
+		err = listForSync(client, repoPath, cloneOpts, manifest, listed)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(manifest.Repositories) {
+		entry := manifest.Repositories[name]
+		_, found := listed[name]
+		if found || !inScope(name, uris) || entry.Status == gitkit.RepoStatusDeleted || entry.Status == gitkit.RepoStatusRenamed {
+			continue
+		}
+
+		host, fullName, _ := strings.Cut(name, "/")
+		client, err := clients.forHost(host)
+		if err != nil {
+			return err
+		}
+
+		info, found, err := client.GetRepository(fullName)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			info = nil
+		}
+
+		status, renamedTo := gitkit.UpstreamStatus(fullName, info)
+		if status == gitkit.RepoStatusActive || status == gitkit.RepoStatusArchived {
+			// not in the listing, e.g. a fork of a user, but still there
+			if cloneOpts.Matches(info) {
+				listed[name] = listedRepo{client: client, info: info}
+			}
+			continue
+		}
+
+		markUpstreamChange(name, entry, status, renamedTo)
+	}
+
+	if opts.dryRun {
+		for _, name := range sortedKeys(listed) {
+			fmt.Printf("[dry-run]: %s\n", cloneURL(listed[name].client, listed[name].info))
+		}
+		return nil
+	}
+
+	sem := make(chan struct{}, opts.concurrency)
+	var wg sync.WaitGroup
+	summary := &fetchSummary{}
+
+	for _, name := range sortedKeys(listed) {
+		repo := listed[name]
+
+		entry, found := manifest.Repositories[name]
+		if !found {
+			entry = &gitkit.ManifestEntry{}
+			manifest.Repositories[name] = entry
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(repo listedRepo, entry *gitkit.ManifestEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			url := cloneURL(repo.client, repo.info)
+			err := syncRepository(repo, cwd, entry, cloneOpts, opts)
+			if err != nil {
+				summary.addFailed(url, err.Error())
+			} else {
+				summary.addSucceeded(url)
+			}
+		}(repo, entry)
+	}
+	wg.Wait()
+	close(sem)
+
+	if opts.prune {
+		for _, name := range sortedKeys(manifest.Repositories) {
+			entry := manifest.Repositories[name]
+			if entry.Status != gitkit.RepoStatusDeleted || !inScope(name, uris) {
+				continue
+			}
+
+			if entry.Path != "" {
+				err = prune(cwd, entry.Path)
+				if err != nil {
+					return fmt.Errorf("failed to prune %s: %w", name, err)
+				}
+			}
+
+			delete(manifest.Repositories, name)
+			fmt.Printf("[pruned]: %s\n", entry.Url)
+		}
+	}
+
+	manifest.UpdatedAt = time.Now().UTC()
+	err = manifest.Save(opts.manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+
+	summary.print()
+
+	if len(summary.failed) > 0 {
+		return fmt.Errorf("%d of %d repositories failed", len(summary.failed), len(listed))
+	}
+
+	return nil
+}
+
+// prune removes the repository at path, which must be strictly below cwd so that a broken manifest
+// can never remove the mirror itself.
+func prune(cwd string, path string) error {
+	if !filepath.IsLocal(path) || filepath.Clean(path) == "." {
+		return fmt.Errorf("refusing to remove '%s', it is not below %s", path, cwd)
+	}
+
+	return os.RemoveAll(filepath.Join(cwd, path))
+}
+
+// listForSync adds the repositories at the path to listed. A single repository that is not found, or
+// found under a different name, is marked in the manifest rather than failing the sync.
+func listForSync(client gitkit.ForgeClient, repoPath string, cloneOpts *gitkit.ForgeOptions, manifest *gitkit.Manifest, listed map[string]listedRepo) error {
+	repos, listErr := client.ListRepositories(repoPath, cloneOpts)
+	if listErr == nil {
+		for _, repo := range repos {
+			listed[client.Host()+"/"+repo.FullName] = listedRepo{client: client, info: repo}
+		}
+		return nil
+	}
+
+	name := client.Host() + "/" + repoPath
+	entry, found := lookupEntry(manifest, name)
+	if !found {
+		return fmt.Errorf("failed to list repositories for path %s: %w", name, listErr)
+	}
+
+	info, found, err := client.GetRepository(repoPath)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		info = nil
+	}
+
+	status, renamedTo := gitkit.UpstreamStatus(repoPath, info)
+	if status == gitkit.RepoStatusActive || status == gitkit.RepoStatusArchived {
+		return fmt.Errorf("failed to list repositories for path %s: %w", name, listErr)
+	}
+
+	markUpstreamChange(name, entry, status, renamedTo)
+	return nil
+}
+
+func syncRepository(repo listedRepo, cwd string, entry *gitkit.ManifestEntry, cloneOpts *gitkit.ForgeOptions, opts options) error {
+	url := cloneURL(repo.client, repo.info)
+	entry.Url = url
+	entry.DefaultBranch = repo.info.DefaultBranch
+	entry.Status, entry.RenamedTo = gitkit.UpstreamStatus(repo.info.FullName, repo.info)
+
+	result, err := repo.client.CloneOrFetchRepo(url, mirrorRoot(cwd, repo.client.Host()), &io.Discard, cloneOpts)
+	if err != nil {
+		entry.Error = err.Error()
+		fmt.Printf("[error]: %s: %s\n", url, err)
+		return err
+	}
+
+	now := time.Now().UTC()
+	entry.FetchedAt = &now
+
+	entry.Path, err = filepath.Rel(cwd, result.RepoPath)
+	if err != nil {
+		entry.Error = err.Error()
+		return err
+	}
+
+	head, refsDigest, err := fetchedRefs(result.RepoPath, entry.DefaultBranch)
+	if err != nil {
+		entry.Error = err.Error()
+		fmt.Printf("[error]: %s %s: %s\n", result.GitCommand, url, err)
+		return err
+	}
+
+	// any ref can change what verification sees, not only the default branch
+	if refsDigest == entry.RefsDigest {
+		entry.Error = ""
+		fmt.Printf("[done]: %s %s (unchanged)\n", result.GitCommand, url)
+		return nil
+	}
+
+	if opts.verify && refsDigest != "" {
+		err = runVerify(opts.verifyCommand, result.RepoPath)
+		if err != nil {
+			entry.Error = "verification failed: " + err.Error()
+			fmt.Printf("[verify failed]: %s: %s\n", url, err)
+			return err
+		}
+
+		entry.VerifiedAt = &now
+	}
+
+	entry.Head = head
+	entry.RefsDigest = refsDigest
+	entry.Error = ""
+	fmt.Printf("[done]: %s %s\n", result.GitCommand, url)
+
+	return nil
+}
+
+func markUpstreamChange(name string, entry *gitkit.ManifestEntry, status gitkit.RepoStatus, renamedTo string) {
+	entry.Status = status
+	entry.RenamedTo = renamedTo
+
+	if status == gitkit.RepoStatusRenamed {
+		fmt.Printf("[renamed]: %s -> %s\n", name, renamedTo)
+	} else {
+		fmt.Printf("[%s]: %s\n", status, name)
+	}
+}
+
+// fetchedRefs returns the commit of the default branch and the digest of all refs, both are empty if the
+// repository is empty.
+func fetchedRefs(path string, branch string) (string, string, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	refsDigest, err := gitkit.RefsDigest(repo)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, name := range []plumbing.ReferenceName{plumbing.NewRemoteReferenceName("origin", branch), plumbing.NewBranchReferenceName(branch)} {
+		ref, err := repo.Reference(name, true)
+		if err == nil {
+			return ref.Hash().String(), refsDigest, nil
+		}
+
+		if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return "", "", err
+		}
+	}
+
+	return "", refsDigest, nil
+}
+
+func runVerify(command string, repoPath string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty verify command")
+	}
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Dir = repoPath
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		return fmt.Errorf("%s: %w", lines[len(lines)-1], err)
+	}
+
+	return nil
+}
+
+// inScope returns true if the manifest entry was fetched by one of the URIs, so that repositories
+// of other owners in the same manifest are left alone.
+func inScope(name string, uris []string) bool {
+	for _, uri := range uris {
+		host, repoPath, err := gitkit.ParseForgeURI(uri)
+		if err != nil {
+			continue
+		}
+
+		scope := strings.ToLower(host + "/" + repoPath)
+		if strings.EqualFold(name, scope) || strings.HasPrefix(strings.ToLower(name), scope+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupEntry finds the entry ignoring casing, since GitHub names are case-insensitive.
+func lookupEntry(manifest *gitkit.Manifest, name string) (*gitkit.ManifestEntry, bool) {
+	for key, entry := range manifest.Repositories {
+		if strings.EqualFold(key, name) {
+			return entry, true
+		}
+	}
+
+	return nil, false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -2,6 +2,7 @@ package githash
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -11,6 +12,7 @@ import (
 	"hash"
 	"io"
 	"strings"
+	"sync"
 )
 
 type ObjectType string
@@ -29,21 +31,35 @@ type GitHash interface {
 	TagSum(tagHash plumbing.Hash) ([]byte, error)
 }
 
+// gitHash is safe for concurrent use. Objects that are requested concurrently before
+// they are cached might be hashed more than once, which is harmless since the result is the same.
 type gitHash struct {
-	repoState *gitkit.RepoState
+	repoState gitkit.RepoObjects
 	hash      hash.Hash
+	hashMutex sync.Mutex
+	hashPool  *sync.Pool
+	mutex     sync.RWMutex
 	commitMap map[plumbing.Hash][]byte
 	treeMap   map[plumbing.Hash][]byte
 	blobMap   map[plumbing.Hash][]byte
 	tagMap    map[plumbing.Hash][]byte
+	index     *gitkit.ObjectIndex
+	algorithm string
 }
 
-func NewGitHash(repo *git.Repository, hash hash.Hash) GitHash {
-	repoState := gitkit.LoadRepoState(repo)
-	return NewGitHashFromRepoState(repoState, hash)
+// NewGitHash reads objects on demand from the repository, see gitkit.NewLazyRepoState.
+func NewGitHash(repo *git.Repository, hash hash.Hash) (GitHash, error) {
+	repoState, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewGitHashFromRepoState(repoState, hash), nil
 }
 
-func NewGitHashFromRepoState(repoState *gitkit.RepoState, hash hash.Hash) GitHash {
+// NewGitHashFromRepoState uses a single hash instance, so hashing is serialized
+// when used concurrently. Use NewGitHashFromRepoStateFunc to hash in parallel.
+func NewGitHashFromRepoState(repoState gitkit.RepoObjects, hash hash.Hash) GitHash {
 	return &gitHash{
 		repoState: repoState,
 		hash:      hash,
@@ -54,15 +70,83 @@ func NewGitHashFromRepoState(repoState *gitkit.RepoState, hash hash.Hash) GitHas
 	}
 }
 
+// NewGitHashFromRepoStateFunc creates hash instances with newHash as needed, e.g. sha512.New,
+// so that objects can be hashed in parallel.
+func NewGitHashFromRepoStateFunc(repoState gitkit.RepoObjects, newHash func() hash.Hash) GitHash {
+	return &gitHash{
+		repoState: repoState,
+		hashPool: &sync.Pool{
+			New: func() any {
+				return newHash()
+			},
+		},
+		commitMap: make(map[plumbing.Hash][]byte),
+		treeMap:   make(map[plumbing.Hash][]byte),
+		blobMap:   make(map[plumbing.Hash][]byte),
+		tagMap:    make(map[plumbing.Hash][]byte),
+	}
+}
+
+// NewGitHashFromIndex is like NewGitHashFromRepoStateFunc, but commit and tag sums are also read from and
+// added to the index, so that they can be reused across runs and tools. algorithm names the hash in the
+// index, e.g. "sha512". Call index.Save to persist new sums.
+func NewGitHashFromIndex(repoState gitkit.RepoObjects, index *gitkit.ObjectIndex, algorithm string, newHash func() hash.Hash) GitHash {
+	gh := NewGitHashFromRepoStateFunc(repoState, newHash).(*gitHash)
+	gh.index = index
+	gh.algorithm = algorithm
+
+	return gh
+}
+
+func (gh *gitHash) cached(m map[plumbing.Hash][]byte, objectHash plumbing.Hash) ([]byte, bool) {
+	gh.mutex.RLock()
+	h, found := m[objectHash]
+	gh.mutex.RUnlock()
+
+	if !found && gh.index != nil {
+		h, found = gh.index.Digest(gh.algorithm, objectHash)
+	}
+
+	return h, found
+}
+
+func (gh *gitHash) store(m map[plumbing.Hash][]byte, objectHash plumbing.Hash, h []byte) {
+	gh.mutex.Lock()
+	m[objectHash] = h
+	gh.mutex.Unlock()
+
+	if gh.index != nil {
+		// only kept for commits and tags
+		gh.index.AddDigest(gh.algorithm, objectHash, h)
+	}
+}
+
+func (gh *gitHash) sum(data []byte, objectType ObjectType) []byte {
+	if gh.hashPool != nil {
+		h := gh.hashPool.Get().(hash.Hash)
+		defer gh.hashPool.Put(h)
+
+		return objectHash(data, objectType, h)
+	}
+
+	gh.hashMutex.Lock()
+	defer gh.hashMutex.Unlock()
+
+	return objectHash(data, objectType, gh.hash)
+}
+
 func (gh *gitHash) CommitSum(commitHash plumbing.Hash) ([]byte, error) {
-	h, found := gh.commitMap[commitHash]
+	h, found := gh.cached(gh.commitMap, commitHash)
 	if found {
 		return h, nil
 	}
 
-	commit, found := gh.repoState.CommitMap[commitHash]
-	if !found {
-		return nil, fmt.Errorf("commit %s not found", commitHash)
+	commit, err := gh.repoState.Commit(commitHash)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, fmt.Errorf("commit %s not found", commitHash)
+		}
+		return nil, err
 	}
 
 	content, err := gh.commitContent(commit)
@@ -70,8 +154,8 @@ func (gh *gitHash) CommitSum(commitHash plumbing.Hash) ([]byte, error) {
 		return nil, err
 	}
 
-	h = objectHash([]byte(content), CommitObject, gh.hash)
-	gh.commitMap[commitHash] = h
+	h = gh.sum([]byte(content), CommitObject)
+	gh.store(gh.commitMap, commitHash, h)
 
 	return h, nil
 }
@@ -79,7 +163,7 @@ func (gh *gitHash) CommitSum(commitHash plumbing.Hash) ([]byte, error) {
 func (gh *gitHash) commitContent(commit *object.Commit) (string, error) {
 	sb := strings.Builder{}
 
-	treeHash, found := gh.treeMap[commit.TreeHash]
+	treeHash, found := gh.cached(gh.treeMap, commit.TreeHash)
 	if !found {
 		h, err := gh.TreeSum(commit.TreeHash)
 		if err != nil {
@@ -91,7 +175,7 @@ func (gh *gitHash) commitContent(commit *object.Commit) (string, error) {
 	sb.WriteString("tree " + hex.EncodeToString(treeHash) + "\n")
 
 	for _, parent := range commit.ParentHashes {
-		parentHash, found := gh.commitMap[parent]
+		parentHash, found := gh.cached(gh.commitMap, parent)
 		if !found {
 			h, err := gh.CommitSum(parent)
 			if err != nil {
@@ -159,14 +243,17 @@ func gpgSigString(commit *object.Commit) (string, error) {
 }
 
 func (gh *gitHash) TagSum(tagHash plumbing.Hash) ([]byte, error) {
-	h, found := gh.tagMap[tagHash]
+	h, found := gh.cached(gh.tagMap, tagHash)
 	if found {
 		return h, nil
 	}
 
-	tag, found := gh.repoState.TagMap[tagHash]
-	if !found {
-		return nil, fmt.Errorf("tag %s not found", tagHash)
+	tag, err := gh.repoState.Tag(tagHash)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, fmt.Errorf("tag %s not found", tagHash)
+		}
+		return nil, err
 	}
 
 	content, err := gh.tagContent(tag)
@@ -174,8 +261,8 @@ func (gh *gitHash) TagSum(tagHash plumbing.Hash) ([]byte, error) {
 		return nil, err
 	}
 
-	h = objectHash([]byte(content), TagObject, gh.hash)
-	gh.tagMap[tagHash] = h
+	h = gh.sum([]byte(content), TagObject)
+	gh.store(gh.tagMap, tagHash, h)
 
 	return h, nil
 }
@@ -183,7 +270,7 @@ func (gh *gitHash) TagSum(tagHash plumbing.Hash) ([]byte, error) {
 func (gh *gitHash) tagContent(tag *object.Tag) (string, error) {
 	sb := strings.Builder{}
 
-	targetHash, found := gh.commitMap[tag.Target]
+	targetHash, found := gh.cached(gh.commitMap, tag.Target)
 	if !found {
 		h, err := gh.CommitSum(tag.Target)
 		if err != nil {
@@ -207,14 +294,17 @@ func (gh *gitHash) tagContent(tag *object.Tag) (string, error) {
 }
 
 func (gh *gitHash) TreeSum(treeHash plumbing.Hash) ([]byte, error) {
-	h, found := gh.treeMap[treeHash]
+	h, found := gh.cached(gh.treeMap, treeHash)
 	if found {
 		return h, nil
 	}
 
-	tree, found := gh.repoState.TreeMap[treeHash]
-	if !found {
-		return nil, fmt.Errorf("tree %s not found", treeHash)
+	tree, err := gh.repoState.Tree(treeHash)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, fmt.Errorf("tree %s not found", treeHash)
+		}
+		return nil, err
 	}
 
 	entries := tree.Entries
@@ -248,22 +338,25 @@ func (gh *gitHash) TreeSum(treeHash plumbing.Hash) ([]byte, error) {
 		data = append(data, entryHash...)
 
 	}
-	h = objectHash(data, TreeObject, gh.hash)
+	h = gh.sum(data, TreeObject)
 
-	gh.treeMap[treeHash] = h
+	gh.store(gh.treeMap, treeHash, h)
 
 	return h, nil
 }
 
 func (gh *gitHash) BlobSum(treeHash plumbing.Hash) ([]byte, error) {
-	h, found := gh.blobMap[treeHash]
+	h, found := gh.cached(gh.blobMap, treeHash)
 	if found {
 		return h, nil
 	}
 
-	blob, found := gh.repoState.BlobMap[treeHash]
-	if !found {
-		return nil, fmt.Errorf("blob %s not found", treeHash)
+	blob, err := gh.repoState.Blob(treeHash)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, fmt.Errorf("blob %s not found", treeHash)
+		}
+		return nil, err
 	}
 
 	reader, err := blob.Reader()
@@ -276,8 +369,8 @@ func (gh *gitHash) BlobSum(treeHash plumbing.Hash) ([]byte, error) {
 		return nil, err
 	}
 
-	h = objectHash(data, BlobObject, gh.hash)
-	gh.blobMap[treeHash] = h
+	h = gh.sum(data, BlobObject)
+	gh.store(gh.blobMap, treeHash, h)
 
 	return h, nil
 }
@@ -0,0 +1,244 @@
+package gitkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// Credentials configures how git operations and API requests are authenticated for a host.
+type Credentials struct {
+	// Token is used for the API and as the password for HTTPS. It is not read from files, use TokenEnv.
+	Token    string `json:"-"`
+	TokenEnv string `json:"tokenEnv,omitempty"`
+	// Username for HTTPS with a token, the default depends on the forge.
+	Username string `json:"username,omitempty"`
+	// CredentialHelper looks up HTTPS credentials with 'git credential fill' if there is no token, i.e. the
+	// helpers in the git config are used.
+	CredentialHelper bool `json:"credentialHelper,omitempty"`
+	// SSH clones with '<SSHUser>@<host>:<org>/<repo>.git', with SSHKeyFile if set and ssh-agent otherwise.
+	// Host keys are checked against known_hosts.
+	SSH        bool   `json:"ssh,omitempty"`
+	SSHUser    string `json:"sshUser,omitempty"`
+	SSHKeyFile string `json:"sshKeyFile,omitempty"`
+	// SSHKeyPassphraseEnv names the environment variable with the passphrase of SSHKeyFile.
+	SSHKeyPassphraseEnv string `json:"sshKeyPassphraseEnv,omitempty"`
+}
+
+type hostCredentialsFile struct {
+	Hosts map[string]*Credentials `json:"hosts"`
+}
+
+// LoadHostCredentials reads per-host credentials from JSON, e.g.
+//
+//	{"hosts": {"github.com": {"ssh": true}, "gitlab.example.com": {"tokenEnv": "CORP_GITLAB_TOKEN"}}}
+func LoadHostCredentials(path string) (map[string]*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &hostCredentialsFile{}
+	err = json.Unmarshal(data, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+
+	hosts := make(map[string]*Credentials, len(file.Hosts))
+	for host, credentials := range file.Hosts {
+		if credentials == nil {
+			credentials = &Credentials{}
+		}
+		hosts[strings.ToLower(host)] = credentials
+	}
+
+	return hosts, nil
+}
+
+// ResolveToken returns the token, reading it from TokenEnv or the credential helper if needed. An empty
+// string means unauthenticated.
+func (c *Credentials) ResolveToken(host string) (string, error) {
+	if c.Token != "" {
+		return c.Token, nil
+	}
+
+	if c.TokenEnv != "" {
+		token := os.Getenv(c.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is empty", c.TokenEnv)
+		}
+		return token, nil
+	}
+
+	if c.CredentialHelper {
+		_, password, err := GitCredentialFill("https://" + host)
+		if err != nil {
+			return "", err
+		}
+		return password, nil
+	}
+
+	return "", nil
+}
+
+// GitCredentialFill asks the credential helpers configured in git for the credentials of the URL. Prompting
+// is disabled, so it fails rather than blocking if no helper has credentials.
+func GitCredentialFill(url string) (username string, password string, err error) {
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	cmd.Stdin = strings.NewReader("url=" + url + "\n\n")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git credential fill for %s failed: %s: %w", url, strings.TrimSpace(stderr.String()), err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+
+	if password == "" {
+		return "", "", fmt.Errorf("no credentials for %s from git credential helpers", url)
+	}
+
+	return username, password, nil
+}
+
+// gitAuth picks the clone URL and the transport auth for the repositories of a host.
+type gitAuth struct {
+	credentials     Credentials
+	defaultUsername string
+}
+
+func newGitAuth(credentials *Credentials, defaultUsername string) *gitAuth {
+	auth := &gitAuth{defaultUsername: defaultUsername}
+	if credentials != nil {
+		auth.credentials = *credentials
+	}
+	return auth
+}
+
+// cloneURL returns the SSH URL of an HTTPS clone URL if SSH is enabled.
+func (a *gitAuth) cloneURL(httpsURL string) string {
+	if a == nil || !a.credentials.SSH {
+		return httpsURL
+	}
+
+	host, repoPath, found := strings.Cut(strings.TrimPrefix(httpsURL, "https://"), "/")
+	if !found || !strings.HasPrefix(httpsURL, "https://") {
+		return httpsURL
+	}
+
+	user := a.credentials.SSHUser
+	if user == "" {
+		user = "git"
+	}
+
+	if !strings.HasSuffix(repoPath, ".git") {
+		repoPath += ".git"
+	}
+
+	return user + "@" + host + ":" + repoPath
+}
+
+// method returns the auth for the URL, which can be HTTPS or SSH since existing clones keep the URL
+// they were cloned with.
+func (a *gitAuth) method(url string) (transport.AuthMethod, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	if isSSHURL(url) {
+		user := a.credentials.SSHUser
+		if user == "" {
+			user = "git"
+		}
+
+		if a.credentials.SSHKeyFile != "" {
+			passphrase := ""
+			if a.credentials.SSHKeyPassphraseEnv != "" {
+				passphrase = os.Getenv(a.credentials.SSHKeyPassphraseEnv)
+			}
+
+			auth, err := gitssh.NewPublicKeysFromFile(user, expandHome(a.credentials.SSHKeyFile), passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load SSH key %s: %w", a.credentials.SSHKeyFile, err)
+			}
+			return auth, nil
+		}
+
+		auth, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use ssh-agent: %w", err)
+		}
+		return auth, nil
+	}
+
+	username := a.credentials.Username
+	if username == "" {
+		username = a.defaultUsername
+	}
+
+	if a.credentials.Token != "" {
+		return &githttp.BasicAuth{Username: username, Password: a.credentials.Token}, nil
+	}
+
+	if a.credentials.CredentialHelper {
+		helperUsername, password, err := GitCredentialFill(url)
+		if err != nil {
+			return nil, err
+		}
+
+		if helperUsername != "" {
+			username = helperUsername
+		}
+		return &githttp.BasicAuth{Username: username, Password: password}, nil
+	}
+
+	return nil, nil
+}
+
+// isSSHURL is true for 'ssh://' and scp-like 'user@host:path' URLs.
+func isSSHURL(url string) bool {
+	if strings.HasPrefix(url, "ssh://") {
+		return true
+	}
+
+	if strings.Contains(url, "://") {
+		return false
+	}
+
+	at := strings.Index(url, "@")
+	colon := strings.Index(url, ":")
+	return at > 0 && colon > at
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,188 @@
+package gitkit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGitAuthCloneURL(t *testing.T) {
+	tests := []struct {
+		credentials *Credentials
+		input       string
+		want        string
+	}{
+		{nil, "https://github.com/foo/bar.git", "https://github.com/foo/bar.git"},
+		{&Credentials{SSH: true}, "https://github.com/foo/bar.git", "git@github.com:foo/bar.git"},
+		{&Credentials{SSH: true}, "https://gitlab.com/group/sub/repo", "git@gitlab.com:group/sub/repo.git"},
+		{&Credentials{SSH: true, SSHUser: "forgejo"}, "https://codeberg.org/foo/bar.git", "forgejo@codeberg.org:foo/bar.git"},
+		{&Credentials{SSH: true}, "git@github.com:foo/bar.git", "git@github.com:foo/bar.git"},
+	}
+
+	for _, tt := range tests {
+		got := newGitAuth(tt.credentials, "token").cloneURL(tt.input)
+		if got != tt.want {
+			t.Errorf("cloneURL(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsSSHURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"git@github.com:foo/bar.git", true},
+		{"ssh://git@github.com/foo/bar.git", true},
+		{"https://github.com/foo/bar.git", false},
+		{"https://user@github.com:443/foo/bar.git", false},
+		{"/tmp/foo:bar", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSSHURL(tt.input); got != tt.want {
+			t.Errorf("isSSHURL(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestGitAuthMethodToken(t *testing.T) {
+	method, err := newGitAuth(&Credentials{Token: "secret"}, "oauth2").method("https://gitlab.com/foo/bar.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basicAuth, ok := method.(*githttp.BasicAuth)
+	if !ok {
+		t.Fatalf("expected basic auth, got %T", method)
+	}
+
+	if basicAuth.Username != "oauth2" || basicAuth.Password != "secret" {
+		t.Errorf("got %s:%s, want oauth2:secret", basicAuth.Username, basicAuth.Password)
+	}
+
+	method, err = newGitAuth(nil, "oauth2").method("https://gitlab.com/foo/bar.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method != nil {
+		t.Errorf("expected no auth without credentials, got %T", method)
+	}
+}
+
+func TestGitAuthMethodSSHKeyFile(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := newGitAuth(&Credentials{SSH: true, SSHKeyFile: keyFile}, "token")
+	method, err := auth.method(auth.cloneURL("https://github.com/foo/bar.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKeys, ok := method.(*gitssh.PublicKeys)
+	if !ok {
+		t.Fatalf("expected public keys, got %T", method)
+	}
+
+	if publicKeys.User != "git" {
+		t.Errorf("got user %s, want git", publicKeys.User)
+	}
+
+	_, err = newGitAuth(&Credentials{SSH: true, SSHKeyFile: keyFile + ".missing"}, "token").method("git@github.com:foo/bar.git")
+	if err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+}
+
+func TestCredentialHelper(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "gitconfig")
+	err := os.WriteFile(config, []byte("[credential]\n\thelper = \"!f() { echo username=alice; echo password=secret; }; f\"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GIT_CONFIG_GLOBAL", config)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	method, err := newGitAuth(&Credentials{CredentialHelper: true}, "token").method("https://git.example.com/foo/bar.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basicAuth, ok := method.(*githttp.BasicAuth)
+	if !ok {
+		t.Fatalf("expected basic auth, got %T", method)
+	}
+
+	if basicAuth.Username != "alice" || basicAuth.Password != "secret" {
+		t.Errorf("got %s:%s, want alice:secret", basicAuth.Username, basicAuth.Password)
+	}
+
+	token, err := (&Credentials{CredentialHelper: true}).ResolveToken("git.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "secret" {
+		t.Errorf("got token %s, want secret", token)
+	}
+}
+
+func TestLoadHostCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	err := os.WriteFile(path, []byte(`{"hosts": {"GitLab.example.com": {"tokenEnv": "TEST_GITLAB_TOKEN", "ssh": true, "token": "ignored"}}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := LoadHostCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, found := hosts["gitlab.example.com"]
+	if !found {
+		t.Fatalf("expected lowercased host, got %v", hosts)
+	}
+
+	if credentials.Token != "" || !credentials.SSH {
+		t.Errorf("unexpected credentials %+v", credentials)
+	}
+
+	_, err = credentials.ResolveToken("gitlab.example.com")
+	if err == nil {
+		t.Error("expected an error for an empty token environment variable")
+	}
+
+	t.Setenv("TEST_GITLAB_TOKEN", "secret")
+	token, err := credentials.ResolveToken("gitlab.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "secret" {
+		t.Errorf("got token %s, want secret", token)
+	}
+}
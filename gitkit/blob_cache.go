@@ -0,0 +1,92 @@
+package gitkit
+
+import (
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/supply-chain-tools/go-sandbox/hashset"
+)
+
+// BlobSearcher is optionally implemented by a Searcher where the results of a file only depend on the content of
+// the blob once the path is accepted. Search then shares the results of each blob between its workers, so that a
+// blob in several repositories, e.g. forks or vendored copies, is only searched once. The results are still
+// reported for every repository and path. Process is used for files without a blob, e.g. in ModeAllFiles.
+type BlobSearcher[T SearchResult] interface {
+	// SearchPath returns false if the file at path should be skipped.
+	SearchPath(path string) bool
+	// ProcessBlob searches the content of the blob.
+	ProcessBlob(hash plumbing.Hash, loadData func() []byte) []T
+}
+
+// blobCache is shared by the workers of Search. Two workers can search the same blob at the same time, then the
+// results of the first one are kept. Most blobs have no results, so those are only kept as hashes in empty, and
+// results only holds the blobs that matched.
+type blobCache[T SearchResult] struct {
+	mutex   sync.Mutex
+	results map[plumbing.Hash][]T
+	empty   hashset.Set[plumbing.Hash]
+}
+
+func newBlobCache[T SearchResult]() *blobCache[T] {
+	return &blobCache[T]{
+		results: make(map[plumbing.Hash][]T),
+		empty:   hashset.New[plumbing.Hash](),
+	}
+}
+
+func (bc *blobCache[T]) get(hash plumbing.Hash) ([]T, bool) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if bc.empty.Contains(hash) {
+		return nil, true
+	}
+
+	results, found := bc.results[hash]
+	return results, found
+}
+
+// put returns the results in the cache, which are the existing results if another worker was first.
+func (bc *blobCache[T]) put(hash plumbing.Hash, results []T) []T {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if bc.empty.Contains(hash) {
+		return nil
+	}
+
+	existing, found := bc.results[hash]
+	if found {
+		return existing
+	}
+
+	if len(results) == 0 {
+		bc.empty.Add(hash)
+		return results
+	}
+
+	bc.results[hash] = results
+	return results
+}
+
+// searchBlob searches the blob at path, using the cache if there is one and the searcher is a BlobSearcher. Blobs
+// found in the cache are not loaded, and their size is counted as skipped.
+func (ps *ProcessedState[T]) searchBlob(searcher Searcher[T], hash plumbing.Hash, size int64, loadData func() []byte, path string) []T {
+	blobSearcher, ok := searcher.(BlobSearcher[T])
+	if !ok || ps.blobCache == nil {
+		return searcher.Process(&hash, loadData, path)
+	}
+
+	if !blobSearcher.SearchPath(path) {
+		return nil
+	}
+
+	results, found := ps.blobCache.get(hash)
+	if found {
+		ps.skippedFiles += 1
+		ps.skippedSize += uint64(size)
+		return results
+	}
+
+	return ps.blobCache.put(hash, blobSearcher.ProcessBlob(hash, loadData))
+}
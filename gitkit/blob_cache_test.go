@@ -0,0 +1,127 @@
+package gitkit
+
+import (
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// blobLineSearcher counts the blobs that are searched, and skips 'skip.txt'.
+type blobLineSearcher struct {
+	lineSearcher
+	blobs *atomic.Int64
+}
+
+func (bs *blobLineSearcher) SearchPath(path string) bool {
+	return filepath.Base(path) != "skip.txt"
+}
+
+func (bs *blobLineSearcher) ProcessBlob(hash plumbing.Hash, loadData func() []byte) []lineResult {
+	bs.blobs.Add(1)
+	return bs.lineSearcher.Process(&hash, loadData, "")
+}
+
+func TestBlobCache(t *testing.T) {
+	cache := newBlobCache[lineResult]()
+	empty := plumbing.NewHash("0000000000000000000000000000000000000001")
+	matched := plumbing.NewHash("0000000000000000000000000000000000000002")
+
+	cache.put(empty, nil)
+	cache.put(matched, []lineResult{{line: 1, text: "secret"}})
+
+	if len(cache.results) != 1 || !cache.empty.Contains(empty) {
+		t.Errorf("expected only the blob with results to be stored with its results, got %v", cache.results)
+	}
+
+	results, found := cache.get(empty)
+	if !found || len(results) != 0 {
+		t.Errorf("got %v, %v for the empty blob", results, found)
+	}
+
+	results = cache.put(matched, nil)
+	if len(results) != 1 {
+		t.Errorf("expected the results of the first worker to be kept, got %v", results)
+	}
+
+	_, found = cache.get(plumbing.NewHash("0000000000000000000000000000000000000003"))
+	if found {
+		t.Error("expected a blob that was not searched to not be found")
+	}
+}
+
+func TestSearchSharesBlobResults(t *testing.T) {
+	dir := t.TempDir()
+	signature := &object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Unix(1700000000, 0)}
+
+	content := "secret shared\n"
+	repos := make([]Repository, 0)
+	for _, name := range []string{"a", "b"} {
+		root := filepath.Join(dir, name)
+		writeFile(t, filepath.Join(root, "shared.txt"), content)
+		writeFile(t, filepath.Join(root, "vendor", "copy.txt"), content)
+		writeFile(t, filepath.Join(root, "skip.txt"), content)
+		writeFile(t, filepath.Join(root, "own.txt"), "secret "+name+"\n")
+
+		repo, err := git.PlainInit(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		worktree, err := repo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = worktree.AddGlob(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		commitHash, err := worktree.Commit("add files", &git.CommitOptions{Author: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/main", commitHash))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		repos = append(repos, NewRepo("", name, root, nil))
+	}
+
+	blobs := &atomic.Int64{}
+	newSearcher := func(Repository) Searcher[lineResult] {
+		return &blobLineSearcher{blobs: blobs}
+	}
+
+	resultsChannel, stats := Search(repos, newSearcher, ModeAllBranches, 2)
+
+	paths := make([]string, 0)
+	for workerResult := range resultsChannel {
+		for _, path := range resultPaths(workerResult.RepoResults) {
+			paths = append(paths, workerResult.Repo.RepositoryName()+"/"+path)
+		}
+	}
+	sort.Strings(paths)
+
+	expected := []string{"a/own.txt", "a/shared.txt", "a/vendor/copy.txt", "b/own.txt", "b/shared.txt", "b/vendor/copy.txt"}
+	if !equalStrings(paths, expected) {
+		t.Errorf("got %v, want %v", paths, expected)
+	}
+
+	// the shared blob and the two own.txt
+	if blobs.Load() != 3 {
+		t.Errorf("expected 3 blobs to be searched, got %d", blobs.Load())
+	}
+
+	if stats.NumberOfSkippedFiles() != 3 || stats.SkippedFileSize() != uint64(3*len(content)) {
+		t.Errorf("expected 3 skipped files of %d bytes, got %d of %d bytes", 3*len(content), stats.NumberOfSkippedFiles(), stats.SkippedFileSize())
+	}
+}
@@ -0,0 +1,249 @@
+package gitkit
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitFilter limits the commits that are searched in history modes, see SearchOptions. The commits are pruned
+// while the history is traversed: the parents of a commit before Since, or of a commit reachable from the start
+// of the commit range, are not visited. Like 'git log --since' this relies on committer dates mostly increasing.
+type commitFilter struct {
+	since time.Time
+	until time.Time
+	refs  []string
+	// rangeTip is the end of the commit range, nil without a commit range
+	rangeTip *plumbing.Reference
+	// excluded are the commits reachable from the start of the commit range
+	excluded map[plumbing.Hash]struct{}
+}
+
+// newCommitFilter resolves the commit range in repo, opts can be nil.
+func newCommitFilter(repo *git.Repository, opts *SearchOptions) (*commitFilter, error) {
+	cf := &commitFilter{
+		excluded: make(map[plumbing.Hash]struct{}),
+	}
+	if opts == nil {
+		return cf, nil
+	}
+
+	cf.since = opts.Since
+	cf.until = opts.Until
+
+	for _, pattern := range opts.Refs {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid ref pattern '%s': %w", pattern, err)
+		}
+		cf.refs = append(cf.refs, pattern)
+	}
+
+	if opts.CommitRange == "" {
+		return cf, nil
+	}
+
+	from, to, err := ParseCommitRange(opts.CommitRange)
+	if err != nil {
+		return nil, err
+	}
+
+	fromHash, err := repo.ResolveRevision(plumbing.Revision(from))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve '%s' in commit range: %w", from, err)
+	}
+
+	toHash, err := repo.ResolveRevision(plumbing.Revision(to))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve '%s' in commit range: %w", to, err)
+	}
+
+	cf.rangeTip = plumbing.NewHashReference(plumbing.ReferenceName(opts.CommitRange), *toHash)
+
+	// the commits are collected without recursion, since the history can be deep
+	pending := []plumbing.Hash{*fromHash}
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, found := cf.excluded[hash]; found {
+			continue
+		}
+
+		commit, err := repo.CommitObject(hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			// the boundary of a shallow clone
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cf.excluded[hash] = struct{}{}
+		pending = append(pending, commit.ParentHashes...)
+	}
+
+	return cf, nil
+}
+
+// ParseCommitRange splits 'A..B' into A and B, B is HEAD if it is left out like in 'A..'.
+func ParseCommitRange(commitRange string) (from string, to string, err error) {
+	if strings.Contains(commitRange, "...") {
+		return "", "", fmt.Errorf("symmetric difference '%s' is not supported, use 'A..B'", commitRange)
+	}
+
+	from, to, found := strings.Cut(commitRange, "..")
+	if !found || from == "" {
+		return "", "", fmt.Errorf("commit range '%s' must be on the form 'A..B'", commitRange)
+	}
+
+	if to == "" {
+		to = "HEAD"
+	}
+
+	return from, to, nil
+}
+
+// limitsRefs is true if only some refs are searched, then dangling commits are not searched either.
+func (cf *commitFilter) limitsRefs() bool {
+	return len(cf.refs) > 0 || cf.rangeTip != nil
+}
+
+// tips returns the refs to search, which is the end of the commit range if there is one.
+func (cf *commitFilter) tips(repo *git.Repository) ([]*plumbing.Reference, error) {
+	if cf.rangeTip != nil {
+		return []*plumbing.Reference{cf.rangeTip}, nil
+	}
+
+	remotes, err := ListRemoteReferences(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make([]*plumbing.Reference, 0, len(remotes))
+	for _, remote := range remotes {
+		if remote.Name() == "refs/remotes/origin/HEAD" || !cf.matchesRef(remote.Name()) {
+			continue
+		}
+		tips = append(tips, remote)
+	}
+
+	return tips, nil
+}
+
+// matchesRef matches the patterns against the full name, e.g. 'refs/remotes/origin/release/1.0', the name with the
+// remote, 'origin/release/1.0', and the branch name, 'release/1.0'.
+func (cf *commitFilter) matchesRef(name plumbing.ReferenceName) bool {
+	if len(cf.refs) == 0 {
+		return true
+	}
+
+	candidates := []string{name.String(), name.Short()}
+	if name.IsRemote() {
+		_, branch, found := strings.Cut(strings.TrimPrefix(name.String(), "refs/remotes/"), "/")
+		if found {
+			candidates = append(candidates, branch)
+		}
+	}
+
+	for _, pattern := range cf.refs {
+		for _, candidate := range candidates {
+			matched, _ := path.Match(pattern, candidate)
+			if matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// prune returns true if neither the commit nor its parents should be searched.
+func (cf *commitFilter) prune(commit *object.Commit) bool {
+	if !cf.since.IsZero() && commit.Committer.When.Before(cf.since) {
+		return true
+	}
+
+	_, excluded := cf.excluded[commit.Hash]
+	return excluded
+}
+
+// skip returns true if the commit should not be searched, but its parents might be.
+func (cf *commitFilter) skip(commit *object.Commit) bool {
+	return !cf.until.IsZero() && commit.Committer.When.After(cf.until)
+}
+
+// selectCommits returns the commits to search in ModeDiff and for metadata, all commits if cf is nil. If the refs
+// are limited, only the commits reachable from the tips are returned, otherwise all commits, including dangling
+// ones, are filtered.
+func (cf *commitFilter) selectCommits(repo *git.Repository, objects RepoObjects) ([]plumbing.Hash, error) {
+	if cf == nil || cf.empty() {
+		return objects.CommitHashes(), nil
+	}
+
+	selected := make([]plumbing.Hash, 0)
+	if !cf.limitsRefs() {
+		for _, hash := range objects.CommitHashes() {
+			commit, err := objects.Commit(hash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+			}
+
+			if !cf.prune(commit) && !cf.skip(commit) {
+				selected = append(selected, hash)
+			}
+		}
+		return selected, nil
+	}
+
+	tips, err := cf.tips(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := make(map[plumbing.Hash]struct{})
+	pending := make([]plumbing.Hash, 0, len(tips))
+	for _, tip := range tips {
+		pending = append(pending, tip.Hash())
+	}
+
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, found := visited[hash]; found {
+			continue
+		}
+		visited[hash] = struct{}{}
+
+		commit, err := objects.Commit(hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if cf.prune(commit) {
+			continue
+		}
+
+		if !cf.skip(commit) {
+			selected = append(selected, hash)
+		}
+		pending = append(pending, commit.ParentHashes...)
+	}
+
+	return selected, nil
+}
+
+// empty is true if all commits are searched.
+func (cf *commitFilter) empty() bool {
+	return cf.since.IsZero() && cf.until.IsZero() && !cf.limitsRefs()
+}
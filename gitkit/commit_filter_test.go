@@ -0,0 +1,119 @@
+package gitkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestProcessAllCommitsCommitFilter(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// main: c1 -> c2 -> c3, release/1.0: c2 -> c4
+	c1 := storeCommit(t, repo, "2023-06-01", map[string]string{"old.txt": "secret old\n"})
+	c2 := storeCommit(t, repo, "2024-02-01", map[string]string{"mid.txt": "secret mid\n"}, c1)
+	c3 := storeCommit(t, repo, "2024-06-01", map[string]string{"mid.txt": "secret mid\n", "new.txt": "secret new\n"}, c2)
+	c4 := storeCommit(t, repo, "2024-03-01", map[string]string{"mid.txt": "secret mid\n", "rel.txt": "secret rel\n"}, c2)
+
+	for name, hash := range map[string]plumbing.Hash{"main": c3, "release/1.0": c4} {
+		err = repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", name), hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	since := date(t, "2024-01-01")
+	until := date(t, "2024-02-15")
+	commitRange := c1.String() + ".." + c3.String()
+
+	tests := []struct {
+		name     string
+		mode     Mode
+		opts     *SearchOptions
+		expected []string
+	}{
+		{"all", ModeAllHistory, nil, []string{"mid.txt", "new.txt", "old.txt", "rel.txt"}},
+		{"since", ModeAllHistory, &SearchOptions{Since: since}, []string{"mid.txt", "new.txt", "rel.txt"}},
+		{"until", ModeAllHistory, &SearchOptions{Until: until}, []string{"mid.txt", "old.txt"}},
+		{"refs", ModeAllHistory, &SearchOptions{Refs: []string{"release/*"}}, []string{"mid.txt", "old.txt", "rel.txt"}},
+		{"full ref", ModeAllHistory, &SearchOptions{Refs: []string{"refs/remotes/origin/main"}}, []string{"mid.txt", "new.txt", "old.txt"}},
+		{"range", ModeAllHistory, &SearchOptions{CommitRange: commitRange}, []string{"mid.txt", "new.txt"}},
+		{"branches since", ModeAllBranches, &SearchOptions{Since: date(t, "2024-04-01")}, []string{"mid.txt", "new.txt"}},
+		{"diff since", ModeDiff, &SearchOptions{Since: since}, []string{"mid.txt", "new.txt", "rel.txt"}},
+		{"diff refs", ModeDiff, &SearchOptions{Refs: []string{"main"}}, []string{"mid.txt", "new.txt", "old.txt"}},
+		{"diff range", ModeDiff, &SearchOptions{CommitRange: c2.String() + ".." + c4.String()}, []string{"rel.txt"}},
+	}
+
+	for _, test := range tests {
+		results, _, err := ProcessAllCommits[lineResult]("test", repo, &lineSearcher{}, test.mode, test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if paths := resultPaths(results); !equalStrings(paths, test.expected) {
+			t.Errorf("%s: got %v, want %v", test.name, paths, test.expected)
+		}
+
+		if test.name != "range" {
+			continue
+		}
+
+		for _, result := range results {
+			if len(result.Results) == 0 {
+				continue
+			}
+
+			branches := result.Matches[result.Results[0]]
+			if len(branches) != 1 || branches[0].Name() != commitRange {
+				t.Errorf("expected %s to be found in the range, got %v", result.Path, branches)
+			}
+		}
+	}
+
+	_, _, err = ProcessAllCommits[lineResult]("test", repo, &lineSearcher{}, ModeAllHistory, &SearchOptions{CommitRange: "missing..main"})
+	if err == nil {
+		t.Error("expected an error for a commit range that can't be resolved")
+	}
+}
+
+func TestParseCommitRange(t *testing.T) {
+	tests := []struct {
+		commitRange string
+		from        string
+		to          string
+		valid       bool
+	}{
+		{"v1..v2", "v1", "v2", true},
+		{"v1..", "v1", "HEAD", true},
+		{"..v2", "", "", false},
+		{"v1...v2", "", "", false},
+		{"v1", "", "", false},
+	}
+
+	for _, test := range tests {
+		from, to, err := ParseCommitRange(test.commitRange)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v", test.commitRange, err)
+			continue
+		}
+
+		if from != test.from || to != test.to {
+			t.Errorf("%s: got '%s' and '%s', want '%s' and '%s'", test.commitRange, from, to, test.from, test.to)
+		}
+	}
+}
+
+func date(t *testing.T, day string) time.Time {
+	d, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
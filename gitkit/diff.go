@@ -0,0 +1,409 @@
+package gitkit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DiffResult is a commit that added, or removed, a line with a match compared to its parent.
+type DiffResult struct {
+	commit  *object.Commit
+	removed bool
+}
+
+func (dr *DiffResult) Commit() *object.Commit {
+	return dr.commit
+}
+
+// Removed is true if the commit removed the line, the line number is then in the file of the parent.
+func (dr *DiffResult) Removed() bool {
+	return dr.removed
+}
+
+type diffKey struct {
+	oldHash plumbing.Hash
+	newHash plumbing.Hash
+	path    string
+}
+
+type diffMatch[T SearchResult] struct {
+	result  T
+	removed bool
+}
+
+type diffState[T SearchResult] struct {
+	objects        RepoObjects
+	searcher       Searcher[T]
+	includeRemoved bool
+	// filter is the filter of the root tree, nil unless ignored files are skipped
+	filter *fileFilter
+
+	// path, result
+	results     map[string]map[T][]DiffResult
+	resultOrder map[string][]T
+	// the same change is often in several commits, e.g. after a cherry-pick or a rebase
+	diffResults map[diffKey][]diffMatch[T]
+
+	size         uint64
+	numFiles     uint64
+	dataLoadTime int64
+	queryTime    int64
+}
+
+// processAllDiffs searches the lines that each commit, including dangling ones, added compared to its parent,
+// and the removed lines if includeRemoved is set. A root commit is compared to the empty tree. Merge commits are
+// skipped like in 'git log -p', their changes are found in the commits of the merged branches, so lines that only
+// a merge conflict resolution added are not searched. Renames are not detected, a renamed file is searched as if
+// all of its lines were removed and added. Only the added (or removed) lines are passed to the searcher, the other
+// lines are blanked so that line numbers are those of the file. If skipIgnored is set the .gitattributes of the new
+// tree, or of the old tree for removed directories, are applied. Only the commits selected by commitFilter are
+// diffed, if it is not nil.
+func processAllDiffs[T SearchResult](path string,
+	repo *git.Repository,
+	searcher Searcher[T],
+	includeRemoved bool,
+	skipIgnored bool,
+	commitFilter *commitFilter) ([]RepoResult[T], Stats, error) {
+	start := time.Now()
+	objects, err := NewLazyRepoState(repo, DefaultObjectCacheSize)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+
+	hashes, err := commitFilter.selectCommits(repo, objects)
+	if err != nil {
+		return nil, Stats{}, fmt.Errorf("failed to filter commits in '%s': %w", path, err)
+	}
+
+	commits := make([]*object.Commit, 0)
+	for _, hash := range hashes {
+		commit, err := objects.Commit(hash)
+		if err != nil {
+			return nil, Stats{}, fmt.Errorf("failed to read commit %s in '%s': %w", hash, path, err)
+		}
+		commits = append(commits, commit)
+	}
+	listFilesTime := time.Since(start).Nanoseconds()
+
+	sort.Slice(commits, func(i, j int) bool {
+		if !commits[i].Committer.When.Equal(commits[j].Committer.When) {
+			return commits[i].Committer.When.Before(commits[j].Committer.When)
+		}
+		return commits[i].Hash.String() < commits[j].Hash.String()
+	})
+
+	state := &diffState[T]{
+		objects:        objects,
+		searcher:       searcher,
+		includeRemoved: includeRemoved,
+		results:        make(map[string]map[T][]DiffResult),
+		resultOrder:    make(map[string][]T),
+		diffResults:    make(map[diffKey][]diffMatch[T]),
+	}
+	if skipIgnored {
+		state.filter = newAttributesFilter()
+	}
+
+	for _, commit := range commits {
+		if len(commit.ParentHashes) > 1 {
+			continue
+		}
+
+		parentTreeHash := plumbing.ZeroHash
+		if len(commit.ParentHashes) > 0 {
+			parent, err := objects.Commit(commit.ParentHashes[0])
+			if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+				return nil, Stats{}, err
+			}
+
+			// the parent is missing at the boundary of a shallow clone, like git it is diffed as a root commit
+			if parent != nil {
+				parentTreeHash = parent.TreeHash
+			}
+		}
+
+		err = state.diffTrees(commit, parentTreeHash, commit.TreeHash, "", state.filter)
+		if err != nil {
+			return nil, Stats{}, fmt.Errorf("failed to diff commit %s in '%s': %w", commit.Hash, path, err)
+		}
+	}
+
+	repoResults := make([]RepoResult[T], 0, len(state.results))
+	for filePath, resultMap := range state.results {
+		commitResults := make(map[T][]DiffResult, len(resultMap))
+		for result, diffResults := range resultMap {
+			commitResults[result] = diffResults
+		}
+
+		repoResults = append(repoResults, RepoResult[T]{
+			Path:    filePath,
+			Results: state.resultOrder[filePath],
+			Commits: commitResults,
+		})
+	}
+
+	return repoResults, Stats{
+		queryTime:     state.queryTime,
+		dataLoadTime:  state.dataLoadTime,
+		listFilesTime: listFilesTime,
+		numFiles:      state.numFiles,
+		size:          state.size,
+	}, nil
+}
+
+// diffTrees compares two trees, a zero hash is the empty tree. Subtrees with the same hash are skipped.
+func (ds *diffState[T]) diffTrees(commit *object.Commit,
+	oldTreeHash plumbing.Hash,
+	newTreeHash plumbing.Hash,
+	path string,
+	filter *fileFilter) error {
+	if oldTreeHash == newTreeHash {
+		return nil
+	}
+
+	oldEntries, err := ds.treeEntries(oldTreeHash)
+	if err != nil {
+		return err
+	}
+
+	newEntries, err := ds.treeEntries(newTreeHash)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(newEntries)+len(oldEntries))
+	for name := range newEntries {
+		names = append(names, name)
+	}
+	for name := range oldEntries {
+		if _, found := newEntries[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if filter != nil {
+		filterTreeHash := newTreeHash
+		if filterTreeHash.IsZero() {
+			filterTreeHash = oldTreeHash
+		}
+
+		tree, err := ds.objects.Tree(filterTreeHash)
+		if err != nil {
+			return fmt.Errorf("failed to read tree %s: %w", filterTreeHash, err)
+		}
+		filter = filter.withTree(ds.objects, tree, pathComponents(path))
+	}
+
+	for _, name := range names {
+		oldEntry, oldFound := oldEntries[name]
+		newEntry, newFound := newEntries[name]
+		if oldFound && newFound && oldEntry.Hash == newEntry.Hash && oldEntry.Mode == newEntry.Mode {
+			continue
+		}
+
+		// a path can change between a directory and a file, so both sides are handled separately
+		oldTree, oldBlob := splitEntry(oldEntry, oldFound)
+		newTree, newBlob := splitEntry(newEntry, newFound)
+
+		if filter != nil && filter.skip(pathComponents(path+name), true) {
+			oldTree, newTree = plumbing.ZeroHash, plumbing.ZeroHash
+		}
+		if filter != nil && filter.skip(pathComponents(path+name), false) {
+			oldBlob, newBlob = plumbing.ZeroHash, plumbing.ZeroHash
+		}
+
+		if oldTree != newTree {
+			err = ds.diffTrees(commit, oldTree, newTree, path+name+"/", filter)
+			if err != nil {
+				return err
+			}
+		}
+
+		if oldBlob != newBlob {
+			err = ds.diffBlobs(commit, oldBlob, newBlob, path+name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitEntry returns the tree or the file blob of the entry, or zero hashes. Symlinks and submodules are skipped.
+func splitEntry(entry object.TreeEntry, found bool) (treeHash plumbing.Hash, blobHash plumbing.Hash) {
+	if !found {
+		return plumbing.ZeroHash, plumbing.ZeroHash
+	}
+
+	switch entry.Mode {
+	case filemode.Dir:
+		return entry.Hash, plumbing.ZeroHash
+	case filemode.Regular, filemode.Executable, filemode.Deprecated:
+		return plumbing.ZeroHash, entry.Hash
+	default:
+		return plumbing.ZeroHash, plumbing.ZeroHash
+	}
+}
+
+func (ds *diffState[T]) treeEntries(hash plumbing.Hash) (map[string]object.TreeEntry, error) {
+	entries := make(map[string]object.TreeEntry)
+	if hash.IsZero() {
+		return entries, nil
+	}
+
+	tree, err := ds.objects.Tree(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+
+	for _, entry := range tree.Entries {
+		entries[entry.Name] = entry
+	}
+
+	return entries, nil
+}
+
+func (ds *diffState[T]) diffBlobs(commit *object.Commit, oldHash plumbing.Hash, newHash plumbing.Hash, path string) error {
+	key := diffKey{oldHash: oldHash, newHash: newHash, path: path}
+	matches, found := ds.diffResults[key]
+	if !found {
+		var err error
+		matches, err = ds.searchDiff(oldHash, newHash, path)
+		if err != nil {
+			return err
+		}
+		ds.diffResults[key] = matches
+	}
+
+	for _, match := range matches {
+		ds.addResult(path, match.result, DiffResult{commit: commit, removed: match.removed})
+	}
+
+	return nil
+}
+
+func (ds *diffState[T]) searchDiff(oldHash plumbing.Hash, newHash plumbing.Hash, path string) ([]diffMatch[T], error) {
+	oldData, err := ds.loadBlob(oldHash)
+	if err != nil {
+		return nil, err
+	}
+
+	newData, err := ds.loadBlob(newHash)
+	if err != nil {
+		return nil, err
+	}
+
+	added, removed := diffLines(oldData, newData)
+
+	matches := make([]diffMatch[T], 0)
+	for _, result := range ds.search(added, path) {
+		matches = append(matches, diffMatch[T]{result: result})
+	}
+
+	if ds.includeRemoved {
+		for _, result := range ds.search(removed, path) {
+			matches = append(matches, diffMatch[T]{result: result, removed: true})
+		}
+	}
+
+	return matches, nil
+}
+
+// search passes a nil hash, since the data is not the content of a blob.
+func (ds *diffState[T]) search(data []byte, path string) []T {
+	if len(bytes.Trim(data, "\n")) == 0 {
+		return nil
+	}
+
+	ds.numFiles += 1
+	ds.size += uint64(len(data))
+
+	start := time.Now()
+	results := ds.searcher.Process(nil, func() []byte { return data }, path)
+	ds.queryTime += time.Since(start).Nanoseconds()
+
+	return results
+}
+
+func (ds *diffState[T]) loadBlob(hash plumbing.Hash) ([]byte, error) {
+	if hash.IsZero() {
+		return nil, nil
+	}
+
+	start := time.Now()
+	blob, err := ds.objects.Blob(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	ds.dataLoadTime += time.Since(start).Nanoseconds()
+
+	return data, nil
+}
+
+func (ds *diffState[T]) addResult(path string, result T, diffResult DiffResult) {
+	resultMap, found := ds.results[path]
+	if !found {
+		resultMap = make(map[T][]DiffResult)
+		ds.results[path] = resultMap
+	}
+
+	existing, found := resultMap[result]
+	if !found {
+		ds.resultOrder[path] = append(ds.resultOrder[path], result)
+	}
+
+	for _, e := range existing {
+		if e.commit.Hash == diffResult.commit.Hash && e.removed == diffResult.removed {
+			return
+		}
+	}
+
+	resultMap[result] = append(existing, diffResult)
+}
+
+// diffLines returns the added lines of newData and the removed lines of oldData. All other lines are kept as
+// empty lines, so that the line numbers are the same as in the files.
+func diffLines(oldData []byte, newData []byte) (added []byte, removed []byte) {
+	addedBuffer := bytes.Buffer{}
+	removedBuffer := bytes.Buffer{}
+
+	for _, d := range diff.Do(string(oldData), string(newData)) {
+		blank := bytes.Repeat([]byte("\n"), bytes.Count([]byte(d.Text), []byte("\n")))
+
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			addedBuffer.Write(blank)
+			removedBuffer.Write(blank)
+		case diffmatchpatch.DiffInsert:
+			addedBuffer.WriteString(d.Text)
+		case diffmatchpatch.DiffDelete:
+			removedBuffer.WriteString(d.Text)
+		}
+	}
+
+	return addedBuffer.Bytes(), removedBuffer.Bytes()
+}
@@ -0,0 +1,119 @@
+package gitkit
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+type lineResult struct {
+	line int
+	text string
+}
+
+func (r lineResult) MatchId() string {
+	return r.text
+}
+
+func (r lineResult) SearchTermId() string {
+	return "secret"
+}
+
+// lineSearcher matches the lines containing 'secret'.
+type lineSearcher struct{}
+
+func (ls *lineSearcher) Process(hash *plumbing.Hash, loadData func() []byte, path string) []lineResult {
+	results := make([]lineResult, 0)
+	for i, line := range bytes.Split(loadData(), []byte("\n")) {
+		if bytes.Contains(line, []byte("secret")) {
+			results = append(results, lineResult{line: i + 1, text: string(line)})
+		}
+	}
+	return results
+}
+
+func TestProcessAllDiffs(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := "unchanged secret\n"
+	first := storeCommit(t, repo, "2023-11-01", map[string]string{"a.txt": "one\nsecret one\n", "dir/b.txt": b})
+	second := storeCommit(t, repo, "2023-11-02", map[string]string{"a.txt": "one\nsecret one\ntwo\nsecret two\n", "dir/b.txt": b}, first)
+	third := storeCommit(t, repo, "2023-11-03", map[string]string{"a.txt": "one\ntwo\nsecret two\n", "dir/b.txt": b}, second)
+	side := storeCommit(t, repo, "2023-11-04", map[string]string{"a.txt": "one\ntwo\nsecret two\n", "dir/b.txt": b, "c.txt": "secret side\n"}, third)
+
+	// compared to its first parent the merge would add 'secret side' and remove 'secret one' again
+	storeCommit(t, repo, "2023-11-05", map[string]string{"a.txt": "one\ntwo\nsecret two\n", "dir/b.txt": b, "c.txt": "secret side\n"}, second, side)
+
+	results, _, err := ProcessAllCommits[lineResult]("test", repo, &lineSearcher{}, ModeDiff, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	introduced := diffCommits(results, false)
+	expected := map[string]plumbing.Hash{
+		"a.txt:2:secret one":           first,
+		"dir/b.txt:1:unchanged secret": first,
+		"a.txt:4:secret two":           second,
+		"c.txt:1:secret side":          side,
+	}
+
+	if len(introduced) != len(expected) {
+		t.Fatalf("got %v, want %v", introduced, expected)
+	}
+
+	for key, hash := range expected {
+		if len(introduced[key]) != 1 || introduced[key][0] != hash {
+			t.Errorf("got %v for '%s', want %s", introduced[key], key, hash)
+		}
+	}
+
+	if len(diffCommits(results, true)) != 0 {
+		t.Errorf("removed lines should only be searched with ModeDiffWithRemoved")
+	}
+
+	results, _, err = ProcessAllCommits[lineResult]("test", repo, &lineSearcher{}, ModeDiffWithRemoved, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed := diffCommits(results, true)
+	if len(removed) != 1 || len(removed["a.txt:2:secret one"]) != 1 || removed["a.txt:2:secret one"][0] != third {
+		t.Errorf("got removed %v, want 'secret one' removed by %s", removed, third)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	added, removed := diffLines([]byte("a\nb\nc\n"), []byte("a\nx\nc\nd\n"))
+
+	if string(added) != "\nx\n\nd\n" {
+		t.Errorf("got added %q", added)
+	}
+
+	if string(removed) != "\nb\n\n" {
+		t.Errorf("got removed %q", removed)
+	}
+}
+
+func diffCommits(results []RepoResult[lineResult], removed bool) map[string][]plumbing.Hash {
+	commits := make(map[string][]plumbing.Hash)
+	for _, result := range results {
+		for r, diffResults := range result.Commits {
+			for _, diffResult := range diffResults {
+				if diffResult.Removed() != removed {
+					continue
+				}
+
+				key := result.Path + ":" + strconv.Itoa(r.line) + ":" + r.text
+				commits[key] = append(commits[key], diffResult.Commit().Hash)
+			}
+		}
+	}
+	return commits
+}
@@ -22,7 +22,7 @@ func main() {
 	}
 
 	for _, path := range repoPaths {
-		repos, err := client.GetRepositories(path)
+		repos, err := client.GetRepositories(path, nil)
 		if err != nil {
 			fmt.Printf("Failed to list repositories for path %s: %v\n", path, err)
 			continue
@@ -0,0 +1,437 @@
+package gitkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type ForgeType string
+
+const (
+	ForgeGitHub ForgeType = "github"
+	ForgeGitLab ForgeType = "gitlab"
+	// ForgeGitea is also used for Forgejo, which has the same API.
+	ForgeGitea ForgeType = "gitea"
+)
+
+// ForgeClient lists and mirrors the repositories of a forge. A path is '<owner>' or '<owner>/<repo>', where
+// the owner of a GitLab project can be a nested group, e.g. 'group/subgroup/repo'.
+type ForgeClient interface {
+	// Host returns the host of the forge, e.g. 'github.com'.
+	Host() string
+	// ListRepositories returns the repository at the path, or all the repositories of the owner at the path that
+	// match the filters in opts. The repositories of GitLab subgroups are included.
+	ListRepositories(path string, opts *ForgeOptions) ([]*RepositoryInfo, error)
+	// GetRepository returns the repository with the full name, following renames and transfers if the forge does.
+	GetRepository(fullName string) (info *RepositoryInfo, found bool, err error)
+	// CloneOrFetchRepo mirrors the repository into '<localBasePath>/<owner>/<repo>', see LocalRepoPath.
+	CloneOrFetchRepo(url string, localBasePath string, progressWriter *io.Writer, opts *ForgeOptions) (*ForgeResult, error)
+}
+
+// RepositoryInfo is the forge-agnostic metadata of a repository.
+type RepositoryInfo struct {
+	// FullName is '<owner>/<repo>', the owner can be a nested group on GitLab.
+	FullName      string
+	Name          string
+	CloneURL      string
+	DefaultBranch string
+	Fork          bool
+	Archived      bool
+	// Visibility is 'public', 'private' or 'internal'.
+	Visibility string
+	// Language is the primary language. GitLab only reports the share of each language, it is looked up
+	// when listing with a language filter and is the language with the largest share.
+	Language string
+	Topics   []string
+	// PushedAt is the last activity on GitLab and the last update on Gitea, which also changes when the
+	// repository settings are edited.
+	PushedAt time.Time
+}
+
+type ForgeResult struct {
+	GitCommand string
+	RepoName   string
+	RepoURL    string
+	RepoPath   string
+	Error      error
+}
+
+// ForgeOptions configures cloning and fetching. The filters only apply when listing all the repositories of
+// an owner, a repository that is requested by name is always included. Zero values match everything.
+type ForgeOptions struct {
+	Depth int
+	Bare  bool
+
+	// Fork and Archived only match forks/archived repositories if true, and exclude them if false.
+	Fork     *bool
+	Archived *bool
+	// Visibility is 'public', 'private' or 'internal'.
+	Visibility string
+	// Languages and Topics match if the repository has any of them, ignoring casing.
+	Languages []string
+	Topics    []string
+	// PushedAfter excludes repositories that have not been pushed to since.
+	PushedAfter time.Time
+	// NamePatterns match the repository name with path.Match, ignoring casing.
+	NamePatterns []string
+}
+
+// Matches returns true if the repository passes all the filters. A nil receiver matches everything.
+func (opts *ForgeOptions) Matches(repo *RepositoryInfo) bool {
+	if opts == nil {
+		return true
+	}
+
+	if opts.Fork != nil && repo.Fork != *opts.Fork {
+		return false
+	}
+
+	if opts.Archived != nil && repo.Archived != *opts.Archived {
+		return false
+	}
+
+	if opts.Visibility != "" && !strings.EqualFold(repo.Visibility, opts.Visibility) {
+		return false
+	}
+
+	if len(opts.Languages) > 0 && !containsFold(opts.Languages, repo.Language) {
+		return false
+	}
+
+	if len(opts.Topics) > 0 && !slices.ContainsFunc(repo.Topics, func(topic string) bool {
+		return containsFold(opts.Topics, topic)
+	}) {
+		return false
+	}
+
+	if !opts.PushedAfter.IsZero() && !repo.PushedAt.After(opts.PushedAfter) {
+		return false
+	}
+
+	if len(opts.NamePatterns) > 0 && !slices.ContainsFunc(opts.NamePatterns, func(pattern string) bool {
+		matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(repo.Name))
+		return err == nil && matched
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// ValidateFilters returns an error for filters that can never match, e.g. a malformed name pattern.
+func (opts *ForgeOptions) ValidateFilters() error {
+	switch strings.ToLower(opts.Visibility) {
+	case "", "public", "private", "internal":
+	default:
+		return fmt.Errorf("visibility must be 'public', 'private' or 'internal', got '%s'", opts.Visibility)
+	}
+
+	for _, pattern := range opts.NamePatterns {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid name pattern '%s': %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
+
+// DetectForgeType infers the forge from well-known hosts and from hosts named after the forge, e.g.
+// 'gitlab.example.com'. An empty string is returned if it can't be inferred.
+func DetectForgeType(host string) ForgeType {
+	host = strings.ToLower(host)
+
+	switch host {
+	case "github.com":
+		return ForgeGitHub
+	case "gitlab.com":
+		return ForgeGitLab
+	case "codeberg.org", "gitea.com":
+		return ForgeGitea
+	}
+
+	labels := strings.Split(host, ".")
+	switch {
+	case slices.Contains(labels, "gitlab"):
+		return ForgeGitLab
+	case slices.Contains(labels, "gitea"), slices.Contains(labels, "forgejo"):
+		return ForgeGitea
+	}
+
+	return ""
+}
+
+// NewForgeClient returns an unauthenticated client if token is empty.
+func NewForgeClient(forgeType ForgeType, host string, token string) (ForgeClient, error) {
+	return NewForgeClientWithCredentials(forgeType, host, &Credentials{Token: token})
+}
+
+func NewForgeClientWithCredentials(forgeType ForgeType, host string, credentials *Credentials) (ForgeClient, error) {
+	switch forgeType {
+	case ForgeGitHub:
+		if host != "github.com" {
+			return nil, fmt.Errorf("only github.com is supported for GitHub, got '%s'", host)
+		}
+		return NewGitHubClientWithCredentials(credentials)
+	case ForgeGitLab:
+		return NewGitLabClientWithCredentials(host, credentials)
+	case ForgeGitea:
+		return NewGiteaClientWithCredentials(host, credentials)
+	default:
+		return nil, fmt.Errorf("unsupported forge type '%s' for '%s'", forgeType, host)
+	}
+}
+
+// resolveCredentials returns a copy with the token resolved, so that it is only looked up once.
+func resolveCredentials(host string, credentials *Credentials) (*Credentials, error) {
+	resolved := &Credentials{}
+	if credentials != nil {
+		*resolved = *credentials
+	}
+
+	token, err := resolved.ResolveToken(host)
+	if err != nil {
+		return nil, err
+	}
+	resolved.Token = token
+
+	return resolved, nil
+}
+
+// ParseForgeURI splits e.g. 'https://gitlab.com/group/subgroup' or 'gitlab.com/group/subgroup' into the host
+// and the path of the owner or repository.
+func ParseForgeURI(uri string) (host string, repoPath string, err error) {
+	uri = strings.TrimPrefix(uri, "https://")
+	if strings.Contains(uri, "://") {
+		return "", "", fmt.Errorf("invalid URI '%s'; only https is supported", uri)
+	}
+
+	host, repoPath, _ = strings.Cut(uri, "/")
+	repoPath = strings.Trim(strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git"), "/")
+
+	if !strings.Contains(host, ".") {
+		return "", "", fmt.Errorf("invalid URI '%s'; must start with a host, e.g. 'github.com/'", uri)
+	}
+
+	if repoPath == "" {
+		return "", "", fmt.Errorf("'owner' or 'owner/repo' must be specified in '%s'", uri)
+	}
+
+	if slices.Contains(strings.Split(repoPath, "/"), "") || slices.Contains(strings.Split(repoPath, "/"), "..") {
+		return "", "", fmt.Errorf("invalid path '%s' in '%s'", repoPath, uri)
+	}
+
+	return host, repoPath, nil
+}
+
+// LocalRepoPath returns '<localBasePath>/<owner>/<repo>'. Nested GitLab groups are joined with '+', which
+// is not allowed in group names, to keep the '<org>/<repo>' layout that InferReposFromPath expects.
+func LocalRepoPath(localBasePath string, fullName string) string {
+	owner, repoName := splitFullName(fullName)
+	return filepath.Join(localBasePath, strings.ReplaceAll(owner, "/", "+"), repoName)
+}
+
+func splitFullName(fullName string) (owner string, repoName string) {
+	i := strings.LastIndex(fullName, "/")
+	if i < 0 {
+		return "", fullName
+	}
+
+	return fullName[:i], fullName[i+1:]
+}
+
+// fullNameFromURL returns the path of a clone URL on host, including a '.git' suffix if present.
+func fullNameFromURL(url string, host string) (string, error) {
+	prefix := "https://" + host + "/"
+	if !strings.HasPrefix(url, prefix) {
+		return "", fmt.Errorf("invalid URL '%s'; must be prefixed with '%s'", url, prefix)
+	}
+
+	fullName := strings.Trim(strings.TrimPrefix(url, prefix), "/")
+	if !strings.Contains(fullName, "/") {
+		return "", fmt.Errorf("expected 'owner/repo' in '%s'", url)
+	}
+
+	return fullName, nil
+}
+
+// mirrorRepo fetches the repository at localRepoPath, or clones it if it does not exist.
+func mirrorRepo(url string, fullName string, localRepoPath string, auth *gitAuth, progressWriter *io.Writer, opts *ForgeOptions) (*ForgeResult, error) {
+	result, err := fetchRepo(url, fullName, localRepoPath, auth, progressWriter, opts)
+	if err != nil {
+		if strings.Contains(err.Error(), git.ErrRepositoryNotExists.Error()) {
+			return cloneRepo(url, fullName, localRepoPath, auth, progressWriter, opts)
+		}
+	}
+	return result, err
+}
+
+// cloneRepo clones over SSH instead of url if SSH is enabled in auth.
+func cloneRepo(url string, fullName string, localRepoPath string, auth *gitAuth, progressWriter *io.Writer, opts *ForgeOptions) (*ForgeResult, error) {
+	if opts == nil {
+		opts = &ForgeOptions{}
+	}
+
+	url = auth.cloneURL(url)
+	authMethod, err := auth.method(url)
+	if err != nil {
+		return &ForgeResult{RepoURL: url, GitCommand: "Clone", Error: err}, err
+	}
+
+	if !opts.Bare {
+		localRepoPath = strings.TrimSuffix(localRepoPath, ".git")
+	}
+
+	cloneOptions := &git.CloneOptions{
+		Auth:     authMethod,
+		URL:      url,
+		Progress: os.Stdout,
+		Depth:    opts.Depth,
+	}
+
+	_, repoName := splitFullName(strings.TrimSuffix(fullName, ".git"))
+	result := ForgeResult{
+		RepoName:   repoName,
+		RepoURL:    url,
+		RepoPath:   localRepoPath,
+		GitCommand: "Clone",
+	}
+
+	if progressWriter != nil {
+		cloneOptions.Progress = *progressWriter
+	}
+
+	_, err = fmt.Fprintf(cloneOptions.Progress, "Cloning '%s' into '%s'\n", fullName, localRepoPath)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to write progress: %w", err)
+		return &result, err
+	}
+	slog.Debug("Cloning", "repo", url, "to", localRepoPath)
+
+	_, err = git.PlainClone(localRepoPath, opts.Bare, cloneOptions)
+	if err != nil {
+		result.Error = fmt.Errorf("error cloning repo: '%s': %w", fullName, err)
+	}
+
+	return &result, result.Error
+}
+
+// fetchRepo fetches from the URL of origin, with the auth for that URL.
+func fetchRepo(url string, fullName string, localRepoPath string, auth *gitAuth, progressWriter *io.Writer, opts *ForgeOptions) (*ForgeResult, error) {
+	if opts == nil {
+		opts = &ForgeOptions{}
+	}
+
+	if !opts.Bare {
+		url = strings.TrimSuffix(url, ".git")
+		localRepoPath = strings.TrimSuffix(localRepoPath, ".git")
+	}
+
+	fetchOptions := &git.FetchOptions{
+		RemoteName: "origin",
+		Progress:   os.Stdout,
+		Prune:      true,
+		Depth:      opts.Depth,
+	}
+
+	_, repoName := splitFullName(strings.TrimSuffix(fullName, ".git"))
+	result := ForgeResult{
+		RepoName:   repoName,
+		RepoURL:    url,
+		RepoPath:   localRepoPath,
+		GitCommand: "Fetch",
+	}
+
+	if progressWriter != nil {
+		fetchOptions.Progress = *progressWriter
+	}
+
+	repo, err := git.PlainOpen(localRepoPath)
+	if err != nil {
+		result.Error = fmt.Errorf("unable to fetch '%s': %v", fullName, err)
+		return &result, result.Error
+	}
+
+	_, err = fmt.Fprintf(fetchOptions.Progress, "Repository '%s' exists. Fetching updates...\n", fullName)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to write proress: %w", err)
+		return &result, result.Error
+	}
+	slog.Debug("Fetching updates for", "repo", localRepoPath)
+
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		result.Error = fmt.Errorf("error retrieving remote 'origin': %v", err)
+		return &result, result.Error
+	}
+
+	if len(remote.Config().URLs) > 0 {
+		fetchOptions.Auth, err = auth.method(remote.Config().URLs[0])
+		if err != nil {
+			result.Error = err
+			return &result, result.Error
+		}
+	}
+
+	err = remote.Fetch(fetchOptions)
+	if err != nil {
+		if !errors.Is(err, git.NoErrAlreadyUpToDate) && err.Error() != "remote repository is empty" {
+			result.Error = fmt.Errorf("error fetching repo '%s:': %v", repoName, err)
+			return &result, result.Error
+		}
+	}
+
+	return &result, nil
+}
+
+// getJSON decodes the response of a GET request into out. found is false on 404.
+func getJSON(client *http.Client, url string, header http.Header, out any) (res *http.Response, found bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err = client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("GET %s failed: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return res, false, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return res, false, fmt.Errorf("GET %s failed: status code %d", url, res.StatusCode)
+	}
+
+	err = json.NewDecoder(res.Body).Decode(out)
+	if err != nil {
+		return res, false, fmt.Errorf("failed to decode response from %s: %w", url, err)
+	}
+
+	return res, true, nil
+}
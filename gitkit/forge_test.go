@@ -0,0 +1,263 @@
+package gitkit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestParseForgeURI(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantErr  bool
+		wantHost string
+		wantPath string
+	}{
+		{"github.com/foo", false, "github.com", "foo"},
+		{"https://gitlab.com/group/sub/repo.git", false, "gitlab.com", "group/sub/repo"},
+		{"codeberg.org/foo/", false, "codeberg.org", "foo"},
+		{"http://gitlab.com/group", true, "", ""},
+		{"gitlab.com", true, "", ""},
+		{"localhost/foo", true, "", ""},
+		{"gitlab.com/group/../other", true, "", ""},
+	}
+
+	for _, tt := range tests {
+		host, repoPath, err := ParseForgeURI(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseForgeURI(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+
+		if host != tt.wantHost || repoPath != tt.wantPath {
+			t.Errorf("ParseForgeURI(%q) = %q %q, want %q %q", tt.input, host, repoPath, tt.wantHost, tt.wantPath)
+		}
+	}
+}
+
+func TestDetectForgeType(t *testing.T) {
+	tests := map[string]ForgeType{
+		"github.com":          ForgeGitHub,
+		"gitlab.com":          ForgeGitLab,
+		"gitlab.example.com":  ForgeGitLab,
+		"codeberg.org":        ForgeGitea,
+		"forgejo.example.com": ForgeGitea,
+		"git.example.com":     "",
+	}
+
+	for host, want := range tests {
+		if got := DetectForgeType(host); got != want {
+			t.Errorf("DetectForgeType(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestLocalRepoPath(t *testing.T) {
+	got := LocalRepoPath("/src/gitlab.com", "group/sub/repo")
+	want := filepath.Join("/src/gitlab.com", "group+sub", "repo")
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestGitLabListRepositories(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/groups/group/projects", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include_subgroups") != "true" {
+			t.Error("expected subgroups to be included")
+		}
+
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			t.Error("expected token to be sent")
+		}
+
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("X-Next-Page", "2")
+			_ = json.NewEncoder(w).Encode([]gitLabProject{
+				{ID: 1, Path: "api", PathWithNamespace: "group/api", Visibility: "public"},
+			})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode([]gitLabProject{
+			{ID: 2, Path: "lib", PathWithNamespace: "group/sub/lib", Visibility: "public", ForkedFromProject: &struct{}{}},
+			{ID: 3, Path: "old", PathWithNamespace: "group/sub/old", Visibility: "private", Archived: true},
+		})
+	})
+	languagesRequested := make(map[string]bool)
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/1/languages":
+			languagesRequested["group/api"] = true
+			_ = json.NewEncoder(w).Encode(map[string]float64{"Shell": 30.5, "Go": 69.5})
+			return
+		case "/api/v4/projects/2/languages":
+			languagesRequested["group/sub/lib"] = true
+			_ = json.NewEncoder(w).Encode(map[string]float64{"Go": 50, "C": 50})
+			return
+		case "/api/v4/projects/3/languages":
+			languagesRequested["group/sub/old"] = true
+			_ = json.NewEncoder(w).Encode(map[string]float64{})
+			return
+		}
+
+		// the full path is a single URL-encoded segment
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsub%2Flib" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(gitLabProject{Path: "lib", PathWithNamespace: "group/sub/lib"})
+	})
+	mux.HandleFunc("/api/v4/users/alice/projects", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]gitLabProject{{Path: "dotfiles", PathWithNamespace: "alice/dotfiles"}})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewGitLabClient("gitlab.example.com", "secret")
+	client.baseURL = server.URL + "/api/v4"
+
+	no := false
+	tests := []struct {
+		path string
+		opts *ForgeOptions
+		want []string
+	}{
+		{"group", nil, []string{"group/api", "group/sub/lib", "group/sub/old"}},
+		{"group", &ForgeOptions{Fork: &no, Archived: &no}, []string{"group/api"}},
+		{"group", &ForgeOptions{Languages: []string{"go"}, Archived: &no}, []string{"group/api"}},
+		{"group", &ForgeOptions{Languages: []string{"c"}, Visibility: "public"}, []string{"group/sub/lib"}},
+		{"group/sub/lib", nil, []string{"group/sub/lib"}},
+		{"alice", nil, []string{"alice/dotfiles"}},
+	}
+
+	for _, tt := range tests {
+		infos, err := client.ListRepositories(tt.path, tt.opts)
+		if err != nil {
+			t.Fatalf("ListRepositories(%q): %v", tt.path, err)
+		}
+
+		if got := fullNames(infos); !slices.Equal(got, tt.want) {
+			t.Errorf("ListRepositories(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if languagesRequested["group/sub/old"] {
+		t.Error("expected languages to only be requested for projects that pass the other filters")
+	}
+
+	_, err := client.ListRepositories("nobody", nil)
+	if err == nil {
+		t.Error("expected unknown group to fail")
+	}
+}
+
+func TestGiteaListRepositories(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/orgs/foo/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			t.Error("expected token to be sent")
+		}
+
+		repos := []giteaRepository{}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			repos = append(repos, giteaRepository{Name: "a", FullName: "foo/a", Language: "Go"})
+		case "2":
+			repos = append(repos, giteaRepository{Name: "b", FullName: "foo/b", Private: true})
+		}
+		_ = json.NewEncoder(w).Encode(repos)
+	})
+	mux.HandleFunc("/api/v1/users/bar/repos", func(w http.ResponseWriter, r *http.Request) {
+		repos := []giteaRepository{}
+		if r.URL.Query().Get("page") == "1" {
+			repos = append(repos, giteaRepository{Name: "c", FullName: "bar/c"})
+		}
+		_ = json.NewEncoder(w).Encode(repos)
+	})
+	mux.HandleFunc("/api/v1/repos/foo/a", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(giteaRepository{Name: "a", FullName: "foo/a"})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewGiteaClient("codeberg.org", "secret")
+	client.baseURL = server.URL + "/api/v1"
+
+	tests := []struct {
+		path string
+		opts *ForgeOptions
+		want []string
+	}{
+		{"foo", nil, []string{"foo/a", "foo/b"}},
+		{"foo", &ForgeOptions{Visibility: "private"}, []string{"foo/b"}},
+		{"foo", &ForgeOptions{Languages: []string{"go"}}, []string{"foo/a"}},
+		{"foo/a", nil, []string{"foo/a"}},
+		{"bar", nil, []string{"bar/c"}},
+	}
+
+	for _, tt := range tests {
+		infos, err := client.ListRepositories(tt.path, tt.opts)
+		if err != nil {
+			t.Fatalf("ListRepositories(%q): %v", tt.path, err)
+		}
+
+		if got := fullNames(infos); !slices.Equal(got, tt.want) {
+			t.Errorf("ListRepositories(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	_, found, err := client.GetRepository("foo/missing")
+	if err != nil || found {
+		t.Errorf("expected missing repository to not be found, got found=%t err=%v", found, err)
+	}
+}
+
+func TestMirrorRepo(t *testing.T) {
+	source := t.TempDir()
+	repo, err := git.PlainInit(source, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := &object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Unix(1700000000, 0)}
+	_, err = worktree.Commit("first", &git.CommitOptions{Author: signature, AllowEmptyCommits: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress io.Writer = io.Discard
+	localRepoPath := LocalRepoPath(t.TempDir(), "group/sub/repo.git")
+	for _, want := range []string{"Clone", "Fetch"} {
+		result, err := mirrorRepo(source, "group/sub/repo.git", localRepoPath, nil, &progress, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.GitCommand != want || result.RepoName != "repo" || filepath.Base(result.RepoPath) != "repo" {
+			t.Errorf("got %+v, want %s of repo", result, want)
+		}
+	}
+}
+
+func fullNames(infos []*RepositoryInfo) []string {
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.FullName)
+	}
+	return names
+}
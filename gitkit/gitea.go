@@ -0,0 +1,184 @@
+package gitkit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const giteaPageSize = 50
+
+// GiteaClient uses the Gitea API (v1), which is also served by Forgejo, e.g. on codeberg.org.
+type GiteaClient struct {
+	host    string
+	baseURL string
+	token   string
+	auth    *gitAuth
+	client  *http.Client
+}
+
+// NewGiteaClient returns an unauthenticated client if token is empty.
+func NewGiteaClient(host string, token string) *GiteaClient {
+	return &GiteaClient{
+		host:    host,
+		baseURL: "https://" + host + "/api/v1",
+		token:   token,
+		auth:    newGitAuth(&Credentials{Token: token}, "oauth2"),
+		client:  http.DefaultClient,
+	}
+}
+
+// NewGiteaClientWithCredentials uses the token for the API and HTTPS, and SSH for cloning if enabled.
+func NewGiteaClientWithCredentials(host string, credentials *Credentials) (*GiteaClient, error) {
+	resolved, err := resolveCredentials(host, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewGiteaClient(host, resolved.Token)
+	client.auth = newGitAuth(resolved, "oauth2")
+	return client, nil
+}
+
+type giteaRepository struct {
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	CloneURL      string    `json:"clone_url"`
+	DefaultBranch string    `json:"default_branch"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	Private       bool      `json:"private"`
+	Internal      bool      `json:"internal"`
+	Language      string    `json:"language"`
+	Topics        []string  `json:"topics"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (gt *GiteaClient) Host() string {
+	return gt.host
+}
+
+func (gt *GiteaClient) ListRepositories(path string, opts *ForgeOptions) ([]*RepositoryInfo, error) {
+	if strings.Contains(path, "/") {
+		info, found, err := gt.GetRepository(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			return nil, fmt.Errorf("repository '%s' not found on %s", path, gt.host)
+		}
+
+		return []*RepositoryInfo{info}, nil
+	}
+
+	repos, found, err := gt.listRepos("/orgs/" + url.PathEscape(path) + "/repos")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list Gitea repos for org '%s': %w", path, err)
+	}
+
+	if !found {
+		repos, found, err = gt.listRepos("/users/" + url.PathEscape(path) + "/repos")
+		if err != nil {
+			return nil, fmt.Errorf("unable to list Gitea repos for user '%s': %w", path, err)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no user or organization named '%s' on %s", path, gt.host)
+	}
+
+	infos := make([]*RepositoryInfo, 0, len(repos))
+	for _, repo := range repos {
+		info := repo.repositoryInfo()
+		if opts.Matches(info) {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos, nil
+}
+
+func (gt *GiteaClient) GetRepository(fullName string) (*RepositoryInfo, bool, error) {
+	owner, repoName := splitFullName(fullName)
+
+	repo := &giteaRepository{}
+	_, found, err := getJSON(gt.client, gt.baseURL+"/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(repoName), gt.header(), repo)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to get Gitea repo '%s': %w", fullName, err)
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+
+	return repo.repositoryInfo(), true, nil
+}
+
+func (gt *GiteaClient) CloneOrFetchRepo(url string, localBasePath string, progressWriter *io.Writer, opts *ForgeOptions) (*ForgeResult, error) {
+	fullName, err := fullNameFromURL(url, gt.host)
+	if err != nil {
+		return nil, err
+	}
+
+	return mirrorRepo(url, fullName, LocalRepoPath(localBasePath, fullName), gt.auth, progressWriter, opts)
+}
+
+// listRepos reads pages until an empty one, since the server may use a smaller page size than requested.
+// found is false if the org or user does not exist.
+func (gt *GiteaClient) listRepos(endpoint string) ([]*giteaRepository, bool, error) {
+	repos := make([]*giteaRepository, 0)
+
+	for page := 1; ; page++ {
+		var result []*giteaRepository
+		requestURL := gt.baseURL + endpoint + "?limit=" + strconv.Itoa(giteaPageSize) + "&page=" + strconv.Itoa(page)
+		_, found, err := getJSON(gt.client, requestURL, gt.header(), &result)
+		if err != nil || !found {
+			return nil, found, err
+		}
+
+		if len(result) == 0 {
+			break
+		}
+
+		repos = append(repos, result...)
+	}
+
+	return repos, true, nil
+}
+
+func (gt *GiteaClient) header() http.Header {
+	header := http.Header{}
+	if gt.token != "" {
+		header.Set("Authorization", "token "+gt.token)
+	}
+	return header
+}
+
+func (r *giteaRepository) repositoryInfo() *RepositoryInfo {
+	visibility := "public"
+	if r.Private {
+		visibility = "private"
+	} else if r.Internal {
+		visibility = "internal"
+	}
+
+	return &RepositoryInfo{
+		FullName:      r.FullName,
+		Name:          r.Name,
+		CloneURL:      r.CloneURL,
+		DefaultBranch: r.DefaultBranch,
+		Fork:          r.Fork,
+		Archived:      r.Archived,
+		Visibility:    visibility,
+		Language:      r.Language,
+		Topics:        r.Topics,
+		// Gitea does not report pushes, updated_at also moves when the description, topics or settings change,
+		// so PushedAfter can include repositories that were not pushed to
+		PushedAt: r.UpdatedAt,
+	}
+}
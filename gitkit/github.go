@@ -2,30 +2,31 @@ package gitkit
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/google/go-github/v61/github"
 )
 
 type GitHubClient struct {
-	token  *string
+	auth   *gitAuth
 	client *github.Client
+	retry  RetryPolicy
+	sleep  func(time.Duration)
 }
 
 func NewAuthenticatedGitHubClient(token string) *GitHubClient {
 	client := github.NewClient(nil).WithAuthToken(token)
 	return &GitHubClient{
 		client: client,
-		token:  &token,
+		auth:   newGitAuth(&Credentials{Token: token}, "token"),
+		retry:  DefaultRetryPolicy,
+		sleep:  time.Sleep,
 	}
 }
 
@@ -33,241 +34,235 @@ func NewGitHubClient() *GitHubClient {
 	client := github.NewClient(nil)
 	return &GitHubClient{
 		client: client,
-		token:  nil,
+		auth:   newGitAuth(nil, "token"),
+		retry:  DefaultRetryPolicy,
+		sleep:  time.Sleep,
 	}
 }
 
-type GitHubResult struct {
-	GitCommand string
-	RepoName   string
-	RepoURL    string
-	RepoPath   string
-	Error      error
+// NewGitHubClientWithCredentials uses the token for the API and HTTPS, and SSH for cloning if enabled.
+func NewGitHubClientWithCredentials(credentials *Credentials) (*GitHubClient, error) {
+	resolved, err := resolveCredentials("github.com", credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	client := github.NewClient(nil)
+	if resolved.Token != "" {
+		client = client.WithAuthToken(resolved.Token)
+	}
+
+	return &GitHubClient{
+		client: client,
+		auth:   newGitAuth(resolved, "token"),
+		retry:  DefaultRetryPolicy,
+		sleep:  time.Sleep,
+	}, nil
 }
 
-type GitHubOptions struct {
-	Depth int
-	Bare  bool
+// GitHubResult and GitHubOptions are kept for existing users, they are the same for all forges.
+type GitHubResult = ForgeResult
+
+type GitHubOptions = ForgeOptions
+
+func (gc *GitHubClient) Host() string {
+	return "github.com"
 }
 
 func (gc *GitHubClient) CloneOrFetchRepo(url string, localBasePath string, progressWriter *io.Writer, opts *GitHubOptions) (*GitHubResult, error) {
-
-	if err := isGitHubURL(url); err != nil {
+	fullName, localRepoPath, err := gc.localRepoPath(url, localBasePath)
+	if err != nil {
 		return nil, err
 	}
 
-	result, err := gc.FetchRepo(url, localBasePath, progressWriter, opts)
+	return mirrorRepo(url, fullName, localRepoPath, gc.auth, progressWriter, opts)
+}
+
+func (gc *GitHubClient) CloneRepo(url string, localBasePath string, progressWriter *io.Writer, opts *GitHubOptions) (*GitHubResult, error) {
+	fullName, localRepoPath, err := gc.localRepoPath(url, localBasePath)
 	if err != nil {
-		if strings.Contains(err.Error(), git.ErrRepositoryNotExists.Error()) {
-			return gc.CloneRepo(url, localBasePath, progressWriter, opts)
-		}
+		return &GitHubResult{}, err
 	}
-	return result, err
+
+	return cloneRepo(url, fullName, localRepoPath, gc.auth, progressWriter, opts)
 }
 
-func (gc *GitHubClient) CloneRepo(url string, localBasePath string, progressWriter *io.Writer, opts *GitHubOptions) (*GitHubResult, error) {
-	var result GitHubResult
+func (gc *GitHubClient) FetchRepo(url string, localBasePath string, progressWriter *io.Writer, opts *GitHubOptions) (*GitHubResult, error) {
+	fullName, localRepoPath, err := gc.localRepoPath(url, localBasePath)
+	if err != nil {
+		return &GitHubResult{}, err
+	}
+
+	return fetchRepo(url, fullName, localRepoPath, gc.auth, progressWriter, opts)
+}
 
+func (gc *GitHubClient) localRepoPath(url string, localBasePath string) (fullName string, localRepoPath string, err error) {
 	if err := isGitHubURL(url); err != nil {
-		return &result, err
+		return "", "", err
 	}
 
 	owner, repoName, err := ExtractOwnerAndRepoName(url)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
 
-	localRepoPath, err := getLocalRepoPath(localBasePath, owner, *repoName)
-	if err != nil {
-		return nil, err
+	if repoName == nil {
+		return "", "", fmt.Errorf("expected 'owner/repo' in '%s'", url)
 	}
 
-	if !opts.Bare {
-		localRepoPath = strings.TrimSuffix(localRepoPath, ".git")
+	localRepoPath, err = getLocalRepoPath(localBasePath, owner, *repoName)
+	if err != nil {
+		return "", "", err
 	}
 
-	auth := configureAuth(gc.token)
+	return owner + "/" + *repoName, localRepoPath, nil
+}
 
-	cloneOptions := &git.CloneOptions{
-		Auth:     auth,
-		URL:      url,
-		Progress: os.Stdout,
-		Depth:    opts.Depth,
+func (gc *GitHubClient) GetRepositories(url string, opts *GitHubOptions) ([]string, error) {
+	repos, err := gc.listRepositories(url, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	result = GitHubResult{
-		RepoName:   *repoName,
-		RepoURL:    url,
-		RepoPath:   localRepoPath,
-		GitCommand: "Clone",
+	var repoURLs []string
+	for _, repo := range repos {
+		repoURLs = append(repoURLs, "https://github.com/"+repo.GetFullName()+".git")
 	}
 
-	if progressWriter != nil {
-		cloneOptions.Progress = *progressWriter
-	}
+	return repoURLs, nil
+}
 
-	_, err = fmt.Fprintf(cloneOptions.Progress, "Cloning '%s/%s' into '%s'\n", owner, *repoName, localRepoPath)
+func (gc *GitHubClient) ListRepositories(path string, opts *ForgeOptions) ([]*RepositoryInfo, error) {
+	repos, err := gc.listRepositories("https://github.com/"+path, opts)
 	if err != nil {
-		result.Error = fmt.Errorf("failed to write progress: %w", err)
-		return &result, err
+		return nil, err
 	}
-	slog.Debug("Cloning", "repo", url, "to", localRepoPath)
 
-	_, err = git.PlainClone(localRepoPath, opts.Bare, cloneOptions)
-	if err != nil {
-		result.Error = fmt.Errorf("error cloning repo: '%s/%s': %w", owner, *repoName, err)
+	infos := make([]*RepositoryInfo, 0, len(repos))
+	for _, repo := range repos {
+		infos = append(infos, gitHubRepositoryInfo(repo))
 	}
 
-	return &result, result.Error
+	return infos, nil
 }
 
-func (gc *GitHubClient) FetchRepo(url string, localBasePath string, progressWriter *io.Writer, opts *GitHubOptions) (*GitHubResult, error) {
-	var result GitHubResult
+func (gc *GitHubClient) GetRepository(fullName string) (*RepositoryInfo, bool, error) {
+	owner, repoName := splitFullName(fullName)
+
+	repo, found, err := gc.GetGitHubRepo(owner, repoName)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	return gitHubRepositoryInfo(repo), true, nil
+}
 
+func (gc *GitHubClient) listRepositories(url string, opts *GitHubOptions) ([]*github.Repository, error) {
 	if err := isGitHubURL(url); err != nil {
-		return &result, err
+		return nil, err
 	}
 
 	owner, repoName, err := ExtractOwnerAndRepoName(url)
 	if err != nil {
-		return &result, err
+		return nil, fmt.Errorf("invalid URL '%s': %w", url, err)
 	}
 
-	localRepoPath, err := getLocalRepoPath(localBasePath, owner, *repoName)
+	owner, isOrg, err := gc.resolveOwner(owner)
 	if err != nil {
-		return &result, err
-	}
-
-	if !opts.Bare {
-		url = strings.TrimSuffix(url, ".git")
-		localRepoPath = strings.TrimSuffix(localRepoPath, ".git")
-	}
-
-	auth := configureAuth(gc.token)
-
-	fetchOptions := &git.FetchOptions{
-		RemoteName: "origin",
-		Auth:       auth,
-		Progress:   os.Stdout,
-		Prune:      true,
-		Depth:      opts.Depth,
+		return nil, err
 	}
 
-	result = GitHubResult{
-		RepoName:   *repoName,
-		RepoURL:    url,
-		RepoPath:   localRepoPath,
-		GitCommand: "Fetch",
-	}
+	if repoName == nil { // all repos
+		var repos []*github.Repository
+		if isOrg {
+			repos, err = gc.ListAllReposForOrg(owner)
+		} else {
+			repos, err = gc.ListAllReposForUser(owner)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing repositories: %w", err)
+		}
 
-	if progressWriter != nil {
-		fetchOptions.Progress = *progressWriter
+		return slices.DeleteFunc(repos, func(repo *github.Repository) bool {
+			if !opts.Matches(gitHubRepositoryInfo(repo)) {
+				slog.Debug("Skipping filtered repository", "repo", repo.GetFullName())
+				return true
+			}
+			return false
+		}), nil
 	}
 
-	repo, err := git.PlainOpen(localRepoPath)
-	if err != nil {
-		result.Error = fmt.Errorf("unable to fetch '%s/%s': %v", owner, *repoName, err)
-		return &result, result.Error
+	// single repo
+	if *repoName == "" {
+		return nil, fmt.Errorf("repository name must not be empty")
 	}
 
-	_, err = fmt.Fprintf(fetchOptions.Progress, "Repository '%s/%s' exists. Fetching updates...\n", owner, *repoName)
+	repoInfo, found, err := gc.GetGitHubRepo(owner, *repoName)
 	if err != nil {
-		result.Error = fmt.Errorf("failed to write proress: %w", err)
-		return &result, result.Error
+		return nil, fmt.Errorf("error getting repo '%s/%s': %w", owner, *repoName, err)
 	}
-	slog.Debug("Fetching updates for", "repo", localRepoPath)
-
-	remote, err := repo.Remote("origin")
-	if err != nil {
-		result.Error = fmt.Errorf("error retrieving remote 'origin': %v", err)
-		return &result, result.Error
+	if !found {
+		return nil, fmt.Errorf("repository '%s/%s' not found", owner, *repoName)
 	}
-
-	err = remote.Fetch(fetchOptions)
-	if err != nil {
-		if !errors.Is(err, git.NoErrAlreadyUpToDate) && err.Error() != "remote repository is empty" {
-			result.Error = fmt.Errorf("error fetching repo '%s:': %v", *repoName, err)
-			return &result, result.Error
-		}
+	if !strings.EqualFold(*repoInfo.Name, *repoName) {
+		return nil, fmt.Errorf("actual '%s' and requested '%s' repo differ in more than casing", *repoInfo.Name, *repoName)
 	}
 
-	return &result, nil
+	return []*github.Repository{repoInfo}, nil
 }
 
-func (gc *GitHubClient) GetRepositories(url string) ([]string, error) {
-	if err := isGitHubURL(url); err != nil {
-		return nil, err
-	}
-
-	owner, repoName, err := ExtractOwnerAndRepoName(url)
-	if err != nil {
-		return nil, fmt.Errorf("invalid URL '%s': %w", url, err)
+func gitHubRepositoryInfo(repo *github.Repository) *RepositoryInfo {
+	return &RepositoryInfo{
+		FullName:      repo.GetFullName(),
+		Name:          repo.GetName(),
+		CloneURL:      repo.GetCloneURL(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		Fork:          repo.GetFork(),
+		Archived:      repo.GetArchived(),
+		Visibility:    repo.GetVisibility(),
+		Language:      repo.GetLanguage(),
+		Topics:        repo.Topics,
+		PushedAt:      repo.GetPushedAt().Time,
 	}
+}
 
-	orgInfo, res, err := gc.client.Organizations.Get(context.Background(), owner)
+// resolveOwner returns the login of the organization or user with its canonical casing.
+func (gc *GitHubClient) resolveOwner(owner string) (string, bool, error) {
+	var orgInfo *github.Organization
+	res, err := gc.withRetry("get org "+owner, func() (res *github.Response, err error) {
+		orgInfo, res, err = gc.client.Organizations.Get(context.Background(), owner)
+		return res, err
+	})
 	isOrg := res != nil && res.StatusCode == 200
 	if err != nil && (res == nil || res.StatusCode != 404) {
-		return nil, fmt.Errorf("error fetching organization info: %w", err)
+		return "", false, fmt.Errorf("error fetching organization info: %w", err)
 	}
 
-	userInfo, res, err := gc.client.Users.Get(context.Background(), owner)
+	var userInfo *github.User
+	res, err = gc.withRetry("get user "+owner, func() (res *github.Response, err error) {
+		userInfo, res, err = gc.client.Users.Get(context.Background(), owner)
+		return res, err
+	})
 	isUser := res != nil && res.StatusCode == 200
 	if err != nil && (res == nil || res.StatusCode != 404) {
-		return nil, fmt.Errorf("error fetching user info: %w", err)
+		return "", false, fmt.Errorf("error fetching user info: %w", err)
 	}
 
 	if !(isOrg || isUser) {
-		return nil, fmt.Errorf("no user or organization named '%s'", owner)
+		return "", false, fmt.Errorf("no user or organization named '%s'", owner)
 	}
 
 	if isOrg {
 		if strings.ToLower(owner) != strings.ToLower(*orgInfo.Login) {
-			return nil, fmt.Errorf("actual '%s' and requested '%s' org differ in more than casing", *orgInfo.Login, owner)
+			return "", false, fmt.Errorf("actual '%s' and requested '%s' org differ in more than casing", *orgInfo.Login, owner)
 		}
-		owner = *orgInfo.Login
-	} else {
-		if strings.ToLower(owner) != strings.ToLower(*userInfo.Login) {
-			return nil, fmt.Errorf("actual '%s' and requested '%s' user differ in more than casing", *userInfo.Login, owner)
-		}
-		owner = *userInfo.Login
+		return *orgInfo.Login, true, nil
 	}
 
-	var repoURLs []string
-
-	if repoName == nil { // all repos
-		var repos []*github.Repository
-		if isOrg {
-			repos, err = gc.ListAllReposForOrg(owner)
-		} else {
-			repos, err = gc.ListAllReposForUser(owner)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("error listing repositories: %w", err)
-		}
-
-		for _, repo := range repos {
-			repoURLs = append(repoURLs, fmt.Sprintf("https://github.com/%s/%s.git", owner, repo.GetName()))
-		}
-	} else { // single repo
-		if *repoName == "" {
-			return nil, fmt.Errorf("repository name must not be empty")
-		}
-
-		repoInfo, found, err := gc.GetGitHubRepo(owner, *repoName)
-		if err != nil {
-			return nil, fmt.Errorf("error getting repo '%s/%s': %w", owner, *repoName, err)
-		}
-		if !found {
-			return nil, fmt.Errorf("repository '%s/%s' not found", owner, *repoName)
-		}
-		if !strings.EqualFold(*repoInfo.Name, *repoName) {
-			return nil, fmt.Errorf("actual '%s' and requested '%s' repo differ in more than casing", *repoInfo.Name, *repoName)
-		}
-
-		repoURLs = append(repoURLs, fmt.Sprintf("https://github.com/%s/%s.git", owner, *repoName))
+	if strings.ToLower(owner) != strings.ToLower(*userInfo.Login) {
+		return "", false, fmt.Errorf("actual '%s' and requested '%s' user differ in more than casing", *userInfo.Login, owner)
 	}
-
-	return repoURLs, nil
+	return *userInfo.Login, false, nil
 }
 
 func ExtractOwnerAndRepoName(url string) (owner string, repoName *string, err error) {
@@ -298,7 +293,11 @@ func ExtractOwnerAndRepoName(url string) (owner string, repoName *string, err er
 }
 
 func (gc *GitHubClient) GetGitHubOrganization(org string) (info *github.Organization, found bool, err error) {
-	result, res, err := gc.client.Organizations.Get(context.Background(), org)
+	var result *github.Organization
+	res, err := gc.withRetry("get org "+org, func() (res *github.Response, err error) {
+		result, res, err = gc.client.Organizations.Get(context.Background(), org)
+		return res, err
+	})
 	if err != nil {
 		if res != nil && res.StatusCode == 404 {
 			return nil, false, nil
@@ -310,7 +309,11 @@ func (gc *GitHubClient) GetGitHubOrganization(org string) (info *github.Organiza
 }
 
 func (gc *GitHubClient) GetGitHubUser(user string) (info *github.User, found bool, err error) {
-	result, res, err := gc.client.Users.Get(context.Background(), user)
+	var result *github.User
+	res, err := gc.withRetry("get user "+user, func() (res *github.Response, err error) {
+		result, res, err = gc.client.Users.Get(context.Background(), user)
+		return res, err
+	})
 	if err != nil {
 		if res != nil && res.StatusCode == 404 {
 			return nil, false, nil
@@ -322,7 +325,11 @@ func (gc *GitHubClient) GetGitHubUser(user string) (info *github.User, found boo
 }
 
 func (gc *GitHubClient) GetGitHubRepo(owner string, repoName string) (info *github.Repository, found bool, err error) {
-	result, res, err := gc.client.Repositories.Get(context.Background(), owner, repoName)
+	var result *github.Repository
+	res, err := gc.withRetry("get repo "+owner+"/"+repoName, func() (res *github.Response, err error) {
+		result, res, err = gc.client.Repositories.Get(context.Background(), owner, repoName)
+		return res, err
+	})
 	if err != nil {
 		if res != nil && res.StatusCode == 404 {
 			return nil, false, nil
@@ -342,7 +349,11 @@ func (gc *GitHubClient) ListAllReposForOrg(org string) ([]*github.Repository, er
 
 	repos := make([]*github.Repository, 0)
 	for {
-		resultRepos, res, err := gc.client.Repositories.ListByOrg(context.Background(), org, options)
+		var resultRepos []*github.Repository
+		res, err := gc.withRetry("list repos of org "+org, func() (res *github.Response, err error) {
+			resultRepos, res, err = gc.client.Repositories.ListByOrg(context.Background(), org, options)
+			return res, err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("unable to list GitHub repos for org '%s': %w", org, err)
 		}
@@ -373,7 +384,11 @@ func (gc *GitHubClient) ListAllReposForUser(user string) ([]*github.Repository,
 
 	repos := make([]*github.Repository, 0)
 	for {
-		resultRepos, res, err := gc.client.Repositories.ListByUser(context.Background(), user, options)
+		var resultRepos []*github.Repository
+		res, err := gc.withRetry("list repos of user "+user, func() (res *github.Response, err error) {
+			resultRepos, res, err = gc.client.Repositories.ListByUser(context.Background(), user, options)
+			return res, err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("unable to list GitHub repos for user '%s': %w", user, err)
 		}
@@ -405,13 +420,3 @@ func isGitHubURL(url string) error {
 func getLocalRepoPath(localBasePath string, owner, repoName string) (string, error) {
 	return filepath.Join(localBasePath, owner, repoName), nil
 }
-
-func configureAuth(token *string) transport.AuthMethod {
-	if token != nil {
-		return &http.BasicAuth{
-			Username: "token",
-			Password: *token,
-		}
-	}
-	return nil
-}
@@ -0,0 +1,101 @@
+package gitkit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// RetryPolicy controls how GitHubClient retries API requests that are rate limited or fail with a server error.
+// The wait is taken from the Retry-After and X-RateLimit-Reset headers when present, otherwise it backs off
+// exponentially from InitialBackoff.
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	// MaxWait is the longest the client sleeps before a single retry, the request fails if the rate limit
+	// resets later than that.
+	MaxWait time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     5,
+	InitialBackoff: 2 * time.Second,
+	MaxWait:        15 * time.Minute,
+}
+
+// secondaryRateLimitBackoff is the minimum wait for a secondary rate limit without a Retry-After header,
+// as recommended by GitHub.
+const secondaryRateLimitBackoff = time.Minute
+
+func (gc *GitHubClient) SetRetryPolicy(policy RetryPolicy) {
+	gc.retry = policy
+}
+
+// withRetry calls the API until it succeeds, fails with an error that is not worth retrying, or the
+// retries are used up.
+func (gc *GitHubClient) withRetry(request string, call func() (*github.Response, error)) (*github.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := call()
+		if err == nil {
+			return res, nil
+		}
+
+		wait, retryable := gc.retryDelay(res, err, attempt)
+		if !retryable || attempt >= gc.retry.MaxRetries {
+			return res, err
+		}
+
+		if wait > gc.retry.MaxWait {
+			return res, fmt.Errorf("%w (not retrying, the wait of %s is longer than %s)", err, wait.Round(time.Second), gc.retry.MaxWait)
+		}
+
+		slog.Info("GitHub API request failed, retrying", "request", request, "wait", wait.Round(time.Second), "attempt", attempt+1, "error", err)
+		gc.sleep(wait)
+	}
+}
+
+func (gc *GitHubClient) retryDelay(res *github.Response, err error, attempt int) (time.Duration, bool) {
+	backoff := gc.retry.InitialBackoff << attempt
+
+	var rateLimitErr *github.RateLimitError
+	var abuseRateLimitErr *github.AbuseRateLimitError
+	switch {
+	case errors.As(err, &abuseRateLimitErr):
+		if abuseRateLimitErr.RetryAfter != nil {
+			return max(*abuseRateLimitErr.RetryAfter, 0), true
+		}
+		return max(backoff, secondaryRateLimitBackoff), true
+	case errors.As(err, &rateLimitErr):
+		return max(time.Until(rateLimitErr.Rate.Reset.Time), 0) + time.Second, true
+	case res != nil && res.StatusCode == http.StatusTooManyRequests:
+		wait, found := retryAfter(res.Response)
+		if found {
+			return wait, true
+		}
+		return backoff, true
+	case res != nil && res.StatusCode >= 500:
+		return backoff, true
+	default:
+		return 0, false
+	}
+}
+
+// retryAfter reads Retry-After (seconds) or X-RateLimit-Reset (Unix time).
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	seconds, err := strconv.ParseInt(res.Header.Get("Retry-After"), 10, 64)
+	if err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+
+	reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err == nil {
+		return max(time.Until(time.Unix(reset, 0)), 0) + time.Second, true
+	}
+
+	return 0, false
+}
@@ -1,7 +1,17 @@
 package gitkit
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-github/v61/github"
 )
 
 func TestExtractOwnerAndRepoName(t *testing.T) {
@@ -35,3 +45,164 @@ func TestExtractOwnerAndRepoName(t *testing.T) {
 		}
 	}
 }
+
+func newTestGitHubClient(t *testing.T, handler http.Handler) *GitHubClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = baseURL
+
+	return &GitHubClient{client: client}
+}
+
+func TestGetRepositoriesFilters(t *testing.T) {
+	pushed := func(date string) *github.Timestamp {
+		parsed, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &github.Timestamp{Time: parsed}
+	}
+
+	repos := []*github.Repository{
+		{Name: github.String("api"), FullName: github.String("foo/api"), Language: github.String("Go"), Visibility: github.String("public"), Topics: []string{"http"}, PushedAt: pushed("2024-06-01")},
+		{Name: github.String("api-fork"), FullName: github.String("foo/api-fork"), Fork: github.Bool(true), Language: github.String("Go"), Visibility: github.String("public"), PushedAt: pushed("2024-06-01")},
+		{Name: github.String("old"), FullName: github.String("foo/old"), Archived: github.Bool(true), Language: github.String("C"), Visibility: github.String("public"), PushedAt: pushed("2019-01-01")},
+		{Name: github.String("secret"), FullName: github.String("foo/secret"), Language: github.String("Rust"), Visibility: github.String("private"), Topics: []string{"crypto"}, PushedAt: pushed("2024-01-01")},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/foo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.Organization{Login: github.String("foo")})
+	})
+	mux.HandleFunc("/users/foo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.User{Login: github.String("foo")})
+	})
+	mux.HandleFunc("/orgs/foo/repos", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repos)
+	})
+	mux.HandleFunc("/repos/foo/old", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repos[2])
+	})
+
+	client := newTestGitHubClient(t, mux)
+
+	no := false
+	yes := true
+	tests := []struct {
+		name string
+		url  string
+		opts *GitHubOptions
+		want []string
+	}{
+		{"no filters", "https://github.com/foo", nil, []string{"api", "api-fork", "old", "secret"}},
+		{"exclude forks and archived", "https://github.com/foo", &GitHubOptions{Fork: &no, Archived: &no}, []string{"api", "secret"}},
+		{"only forks", "https://github.com/foo", &GitHubOptions{Fork: &yes}, []string{"api-fork"}},
+		{"visibility", "https://github.com/foo", &GitHubOptions{Visibility: "private"}, []string{"secret"}},
+		{"language", "https://github.com/foo", &GitHubOptions{Languages: []string{"go", "c"}}, []string{"api", "api-fork", "old"}},
+		{"topic", "https://github.com/foo", &GitHubOptions{Topics: []string{"CRYPTO"}}, []string{"secret"}},
+		{"pushed after", "https://github.com/foo", &GitHubOptions{PushedAfter: pushed("2024-03-01").Time}, []string{"api", "api-fork"}},
+		{"name", "https://github.com/foo", &GitHubOptions{NamePatterns: []string{"api*"}}, []string{"api", "api-fork"}},
+		{"named repo is not filtered", "https://github.com/foo/old", &GitHubOptions{Archived: &no}, []string{"old"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoURLs, err := client.GetRepositories(tt.url, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := make([]string, 0, len(tt.want))
+			for _, name := range tt.want {
+				want = append(want, "https://github.com/foo/"+name+".git")
+			}
+
+			if !slices.Equal(repoURLs, want) {
+				t.Errorf("got %v, want %v", repoURLs, want)
+			}
+		})
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	err := (&GitHubOptions{Visibility: "secret"}).ValidateFilters()
+	if err == nil {
+		t.Error("expected invalid visibility to fail")
+	}
+
+	err = (&GitHubOptions{NamePatterns: []string{"["}}).ValidateFilters()
+	if err == nil {
+		t.Error("expected invalid name pattern to fail")
+	}
+}
+
+func TestGitHubRetry(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/foo/bar", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			// go-github refuses to make requests until Retry-After has passed, and the test doesn't sleep
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "You have exceeded a secondary rate limit", "documentation_url": "https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits"}`))
+		case 2:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 3:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_ = json.NewEncoder(w).Encode(&github.Repository{Name: github.String("bar"), FullName: github.String("foo/bar")})
+		}
+	})
+	mux.HandleFunc("/repos/foo/limited", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+	})
+	mux.HandleFunc("/repos/foo/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	client := newTestGitHubClient(t, mux)
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 3, InitialBackoff: time.Second, MaxWait: time.Minute})
+
+	var waits []time.Duration
+	client.sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+
+	_, found, err := client.GetGitHubRepo("foo", "bar")
+	if err != nil || !found {
+		t.Fatalf("expected the request to succeed after retries, got found=%t err=%v", found, err)
+	}
+
+	// Retry-After for the secondary rate limit and 429, then exponential backoff for 502
+	want := []time.Duration{0, 3 * time.Second, 4 * time.Second}
+	if !slices.Equal(waits, want) {
+		t.Errorf("got waits %v, want %v", waits, want)
+	}
+
+	waits = nil
+	_, _, err = client.GetGitHubRepo("foo", "down")
+	if err == nil || len(waits) != 3 {
+		t.Errorf("expected failure after 3 retries, got err=%v waits=%v", err, waits)
+	}
+
+	// last, since go-github stops making requests until the reset
+	waits = nil
+	_, _, err = client.GetGitHubRepo("foo", "limited")
+	if err == nil || !strings.Contains(err.Error(), "not retrying") || len(waits) != 0 {
+		t.Errorf("expected a reset beyond MaxWait to fail without waiting, got err=%v waits=%v", err, waits)
+	}
+}
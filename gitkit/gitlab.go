@@ -0,0 +1,204 @@
+package gitkit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitLabClient uses the GitLab REST API (v4), on gitlab.com or a self-hosted instance.
+type GitLabClient struct {
+	host    string
+	baseURL string
+	token   string
+	auth    *gitAuth
+	client  *http.Client
+}
+
+// NewGitLabClient returns an unauthenticated client if token is empty.
+func NewGitLabClient(host string, token string) *GitLabClient {
+	return &GitLabClient{
+		host:    host,
+		baseURL: "https://" + host + "/api/v4",
+		token:   token,
+		auth:    newGitAuth(&Credentials{Token: token}, "oauth2"),
+		client:  http.DefaultClient,
+	}
+}
+
+// NewGitLabClientWithCredentials uses the token for the API and HTTPS, and SSH for cloning if enabled.
+func NewGitLabClientWithCredentials(host string, credentials *Credentials) (*GitLabClient, error) {
+	resolved, err := resolveCredentials(host, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewGitLabClient(host, resolved.Token)
+	client.auth = newGitAuth(resolved, "oauth2")
+	return client, nil
+}
+
+type gitLabProject struct {
+	ID                int       `json:"id"`
+	Path              string    `json:"path"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	HTTPURLToRepo     string    `json:"http_url_to_repo"`
+	DefaultBranch     string    `json:"default_branch"`
+	ForkedFromProject *struct{} `json:"forked_from_project"`
+	Archived          bool      `json:"archived"`
+	Visibility        string    `json:"visibility"`
+	Topics            []string  `json:"topics"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+}
+
+func (gl *GitLabClient) Host() string {
+	return gl.host
+}
+
+// ListRepositories returns the project at path if there is one, otherwise the projects of the group at path,
+// including those of all subgroups, or the projects of the user at path.
+func (gl *GitLabClient) ListRepositories(path string, opts *ForgeOptions) ([]*RepositoryInfo, error) {
+	if strings.Contains(path, "/") {
+		info, found, err := gl.GetRepository(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			return []*RepositoryInfo{info}, nil
+		}
+	}
+
+	projects, found, err := gl.listProjects("/groups/" + url.PathEscape(path) + "/projects?include_subgroups=true")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list GitLab projects for group '%s': %w", path, err)
+	}
+
+	if !found && !strings.Contains(path, "/") {
+		projects, found, err = gl.listProjects("/users/" + url.PathEscape(path) + "/projects?")
+		if err != nil {
+			return nil, fmt.Errorf("unable to list GitLab projects for user '%s': %w", path, err)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no project, group or user named '%s' on %s", path, gl.host)
+	}
+
+	// the languages are not part of the project, only look them up for projects that pass the other filters
+	var withoutLanguages *ForgeOptions
+	if opts != nil && len(opts.Languages) > 0 {
+		copied := *opts
+		copied.Languages = nil
+		withoutLanguages = &copied
+	}
+
+	infos := make([]*RepositoryInfo, 0, len(projects))
+	for _, project := range projects {
+		info := project.repositoryInfo()
+		if withoutLanguages != nil {
+			if !withoutLanguages.Matches(info) {
+				continue
+			}
+
+			info.Language, err = gl.primaryLanguage(project)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if opts.Matches(info) {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos, nil
+}
+
+func (gl *GitLabClient) GetRepository(fullName string) (*RepositoryInfo, bool, error) {
+	project := &gitLabProject{}
+	_, found, err := getJSON(gl.client, gl.baseURL+"/projects/"+url.PathEscape(fullName), gl.header(), project)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to get GitLab project '%s': %w", fullName, err)
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+
+	return project.repositoryInfo(), true, nil
+}
+
+func (gl *GitLabClient) CloneOrFetchRepo(url string, localBasePath string, progressWriter *io.Writer, opts *ForgeOptions) (*ForgeResult, error) {
+	fullName, err := fullNameFromURL(url, gl.host)
+	if err != nil {
+		return nil, err
+	}
+
+	return mirrorRepo(url, fullName, LocalRepoPath(localBasePath, fullName), gl.auth, progressWriter, opts)
+}
+
+// listProjects follows the X-Next-Page header until all pages are read, found is false if the
+// group or user does not exist.
+func (gl *GitLabClient) listProjects(endpoint string) ([]*gitLabProject, bool, error) {
+	projects := make([]*gitLabProject, 0)
+
+	page := "1"
+	for page != "" {
+		var result []*gitLabProject
+		res, found, err := getJSON(gl.client, gl.baseURL+endpoint+"&per_page=100&page="+page, gl.header(), &result)
+		if err != nil || !found {
+			return nil, found, err
+		}
+
+		projects = append(projects, result...)
+		page = res.Header.Get("X-Next-Page")
+	}
+
+	return projects, true, nil
+}
+
+// primaryLanguage returns the language with the largest share of the project, or an empty string if
+// GitLab did not detect any.
+func (gl *GitLabClient) primaryLanguage(project *gitLabProject) (string, error) {
+	languages := make(map[string]float64)
+	_, _, err := getJSON(gl.client, gl.baseURL+"/projects/"+strconv.Itoa(project.ID)+"/languages", gl.header(), &languages)
+	if err != nil {
+		return "", fmt.Errorf("unable to get languages of GitLab project '%s': %w", project.PathWithNamespace, err)
+	}
+
+	primary := ""
+	for language, share := range languages {
+		if primary == "" || share > languages[primary] || (share == languages[primary] && language < primary) {
+			primary = language
+		}
+	}
+
+	return primary, nil
+}
+
+func (gl *GitLabClient) header() http.Header {
+	header := http.Header{}
+	if gl.token != "" {
+		header.Set("PRIVATE-TOKEN", gl.token)
+	}
+	return header
+}
+
+func (p *gitLabProject) repositoryInfo() *RepositoryInfo {
+	return &RepositoryInfo{
+		FullName:      p.PathWithNamespace,
+		Name:          p.Path,
+		CloneURL:      p.HTTPURLToRepo,
+		DefaultBranch: p.DefaultBranch,
+		Fork:          p.ForkedFromProject != nil,
+		Archived:      p.Archived,
+		Visibility:    p.Visibility,
+		Topics:        p.Topics,
+		PushedAt:      p.LastActivityAt,
+	}
+}
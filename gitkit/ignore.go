@@ -0,0 +1,306 @@
+package gitkit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/supply-chain-tools/go-sandbox/hashset"
+)
+
+const (
+	gitignoreFile     = ".gitignore"
+	gitattributesFile = ".gitattributes"
+)
+
+// fileFilter skips the files that are ignored by .gitignore or .git/info/exclude, or that have the
+// linguist-generated or linguist-vendored attribute in .gitattributes. Like git, the ignore files only apply to
+// files that are not tracked, so in history only the attributes are used.
+type fileFilter struct {
+	ignorePatterns []gitignore.Pattern
+	attributes     []gitattributes.MatchAttribute
+	// ignoreMatcher is nil if the ignore files are not used
+	ignoreMatcher gitignore.Matcher
+	// key identifies the patterns, so that trees are only cached for the same patterns
+	key string
+}
+
+// newAttributesFilter returns the filter for the root of a tree in history, which only uses .gitattributes,
+// since every file in a commit is tracked.
+func newAttributesFilter() *fileFilter {
+	return &fileFilter{
+		key: "/",
+	}
+}
+
+// newFileFilter returns the filter for the root of a worktree, with the patterns of .git/info/exclude in gitDir.
+func newFileFilter(gitDir string) *fileFilter {
+	patterns := make([]gitignore.Pattern, 0)
+	if gitDir != "" {
+		data, err := os.ReadFile(filepath.Join(gitDir, "info", "exclude"))
+		if err != nil && !os.IsNotExist(err) {
+			slog.Debug("unable to read info/exclude", "path", gitDir, "error", err)
+		}
+		patterns = parseIgnorePatterns(data, nil)
+	}
+
+	return &fileFilter{
+		ignorePatterns: patterns,
+		ignoreMatcher:  gitignore.NewMatcher(patterns),
+		key:            "/",
+	}
+}
+
+// withFiles adds the patterns of the .gitignore and .gitattributes in the directory at path, which take
+// precedence over the patterns of the parent directories. Either can be nil if there is no such file.
+func (f *fileFilter) withFiles(path []string, ignoreData []byte, attributesData []byte, key string) *fileFilter {
+	if ignoreData == nil && attributesData == nil {
+		return f
+	}
+
+	ignorePatterns := f.ignorePatterns
+	ignoreMatcher := f.ignoreMatcher
+	if f.ignoreMatcher != nil && ignoreData != nil {
+		ignorePatterns = append(f.ignorePatterns[:len(f.ignorePatterns):len(f.ignorePatterns)], parseIgnorePatterns(ignoreData, path)...)
+		ignoreMatcher = gitignore.NewMatcher(ignorePatterns)
+	}
+
+	attributes := f.attributes[:len(f.attributes):len(f.attributes)]
+	if attributesData != nil {
+		parsed, err := gitattributes.ReadAttributes(bytes.NewReader(attributesData), path, len(path) == 0)
+		if err != nil {
+			slog.Debug("unable to parse .gitattributes", "path", strings.Join(path, "/"), "error", err)
+		}
+		attributes = append(attributes, parsed...)
+	}
+
+	return &fileFilter{
+		ignorePatterns: ignorePatterns,
+		attributes:     attributes,
+		ignoreMatcher:  ignoreMatcher,
+		key:            f.key + "|" + strings.Join(path, "/") + ":" + key,
+	}
+}
+
+// skip returns true if the file or directory at path should not be searched.
+func (f *fileFilter) skip(path []string, isDir bool) bool {
+	if f.ignored(path, isDir) {
+		return true
+	}
+
+	return !isDir && f.generatedOrVendored(path)
+}
+
+// ignored returns true if the file or directory matches the ignore files, it is always false in history.
+func (f *fileFilter) ignored(path []string, isDir bool) bool {
+	return f.ignoreMatcher != nil && f.ignoreMatcher.Match(path, isDir)
+}
+
+func (f *fileFilter) generatedOrVendored(path []string) bool {
+	return f.attributeSet(path, "linguist-generated") || f.attributeSet(path, "linguist-vendored")
+}
+
+// attributeSet returns true if the attribute is set, or set to 'true', by the last matching pattern that
+// mentions it.
+func (f *fileFilter) attributeSet(path []string, name string) bool {
+	for i := len(f.attributes) - 1; i >= 0; i-- {
+		attribute := f.attributes[i]
+		if attribute.Pattern == nil || !attribute.Pattern.Match(path) {
+			continue
+		}
+
+		for _, a := range attribute.Attributes {
+			if a.Name() == name {
+				return a.IsSet() || (a.IsValueSet() && a.Value() == "true")
+			}
+		}
+	}
+
+	return false
+}
+
+func parseIgnorePatterns(data []byte, domain []string) []gitignore.Pattern {
+	patterns := make([]gitignore.Pattern, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns
+}
+
+// withTree adds the .gitattributes of a tree in history modes, path is the path of the tree. A file that can't
+// be read is logged and treated as missing.
+func (f *fileFilter) withTree(objects RepoObjects, tree *object.Tree, path []string) *fileFilter {
+	for _, entry := range tree.Entries {
+		isFile := entry.Mode == filemode.Regular || entry.Mode == filemode.Executable || entry.Mode == filemode.Deprecated
+		if !isFile || entry.Name != gitattributesFile {
+			continue
+		}
+
+		data, err := readBlob(objects, entry.Hash)
+		if err != nil {
+			slog.Debug("unable to read blob (ignoring)", "path", strings.Join(path, "/")+"/"+entry.Name, "error", err)
+			return f
+		}
+
+		return f.withFiles(path, nil, data, entry.Hash.String())
+	}
+
+	return f
+}
+
+// pathComponents splits a slash separated path relative to the root of the repository.
+func pathComponents(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func readBlob(objects RepoObjects, hash plumbing.Hash) ([]byte, error) {
+	blob, err := objects.Blob(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// gitDir returns the git directory of a repository on disk, or an empty string for in-memory repositories.
+func gitDir(repo *git.Repository) string {
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return ""
+	}
+	return storage.Filesystem().Root()
+}
+
+// localFileFilters caches the filters of the directories in a worktree in ModeAllFiles.
+type localFileFilters struct {
+	root    string
+	filters map[string]*fileFilter
+	// tracked is the files in the git index and the directories that contain them, which are searched even
+	// if they match an ignore file
+	tracked hashset.Set[string]
+}
+
+func newLocalFileFilters(root string) *localFileFilters {
+	root = filepath.Clean(root)
+	filter := newFileFilter(filepath.Join(root, ".git")).withFiles([]string{},
+		readOptional(filepath.Join(root, gitignoreFile)), readOptional(filepath.Join(root, gitattributesFile)), "")
+
+	return &localFileFilters{
+		root:    root,
+		filters: map[string]*fileFilter{root: filter},
+		tracked: trackedPaths(filepath.Join(root, ".git")),
+	}
+}
+
+// trackedPaths reads the paths in the index of gitDir, and their parent directories. A missing or unreadable
+// index is logged and treated as empty, so that all files are untracked.
+func trackedPaths(gitDir string) hashset.Set[string] {
+	tracked := hashset.New[string]()
+
+	f, err := os.Open(filepath.Join(gitDir, "index"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Debug("unable to read the index", "path", gitDir, "error", err)
+		}
+		return tracked
+	}
+	defer f.Close()
+
+	idx := &index.Index{}
+	err = index.NewDecoder(f).Decode(idx)
+	if err != nil {
+		slog.Debug("unable to decode the index", "path", gitDir, "error", err)
+		return tracked
+	}
+
+	for _, entry := range idx.Entries {
+		for path := entry.Name; path != "." && !tracked.Contains(path); path = filepath.ToSlash(filepath.Dir(path)) {
+			tracked.Add(path)
+		}
+	}
+
+	return tracked
+}
+
+// skip returns true if the file or directory at path, which is below the root, should not be searched.
+// The ignore files only apply if it is not tracked.
+func (lf *localFileFilters) skip(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	if path == lf.root {
+		return false
+	}
+
+	components := lf.components(path)
+	filter := lf.filter(filepath.Dir(path))
+	if !lf.tracked.Contains(strings.Join(components, "/")) && filter.ignored(components, isDir) {
+		return true
+	}
+
+	return !isDir && filter.generatedOrVendored(components)
+}
+
+// filter returns the filter of the directory, which includes the files of all the directories up to the root.
+func (lf *localFileFilters) filter(dir string) *fileFilter {
+	filter, found := lf.filters[dir]
+	if found {
+		return filter
+	}
+
+	if !strings.HasPrefix(dir, lf.root+string(filepath.Separator)) {
+		return lf.filters[lf.root]
+	}
+
+	filter = lf.filter(filepath.Dir(dir)).withFiles(lf.components(dir),
+		readOptional(filepath.Join(dir, gitignoreFile)), readOptional(filepath.Join(dir, gitattributesFile)), "")
+	lf.filters[dir] = filter
+	return filter
+}
+
+func (lf *localFileFilters) components(path string) []string {
+	relativePath, err := filepath.Rel(lf.root, path)
+	if err != nil || relativePath == "." {
+		return []string{}
+	}
+
+	return strings.Split(filepath.ToSlash(relativePath), "/")
+}
+
+// readOptional returns nil if the file does not exist.
+func readOptional(path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Debug("unable to read file", "path", path, "error", err)
+		}
+		return nil
+	}
+
+	return data
+}
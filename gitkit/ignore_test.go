@@ -0,0 +1,183 @@
+package gitkit
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestFileFilter(t *testing.T) {
+	filter := newFileFilter("").
+		withFiles([]string{}, []byte("*.log\n!keep.log\n/build/\n# comment\n\n"),
+			[]byte("gen/** linguist-generated\nvendor/** linguist-vendored\nvendor/own.txt -linguist-vendored\n"), "root").
+		withFiles([]string{"sub"}, []byte("local.txt\n"), []byte("*.txt linguist-generated=true\nmain.txt linguist-generated=false\n"), "sub")
+
+	tests := []struct {
+		path  string
+		isDir bool
+		skip  bool
+	}{
+		{"a.txt", false, false},
+		{"a.log", false, true},
+		{"dir/a.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"dir/build", true, false},
+		{"gen/a.go", false, true},
+		{"gen", true, false},
+		{"vendor/lib.txt", false, true},
+		{"vendor/own.txt", false, false},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"sub/other.txt", false, true},
+		{"sub/main.txt", false, false},
+		{"other/other.txt", false, false},
+	}
+
+	for _, test := range tests {
+		if skip := filter.skip(pathComponents(test.path), test.isDir); skip != test.skip {
+			t.Errorf("skip(%s) = %v, want %v", test.path, skip, test.skip)
+		}
+	}
+
+	// in history every file is tracked, so only the attributes apply
+	attributesFilter := newAttributesFilter().
+		withFiles([]string{}, []byte("*.log\n"), []byte("gen/** linguist-generated\n"), "root")
+	if attributesFilter.skip(pathComponents("a.log"), false) || !attributesFilter.skip(pathComponents("gen/a.go"), false) {
+		t.Error("expected only the attributes to apply in history")
+	}
+}
+
+func TestProcessAllFilesSkipIgnored(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "repo")
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(root, ".gitignore"), "node_modules/\n*.log\n")
+	writeFile(t, filepath.Join(root, ".gitattributes"), "dist/** linguist-generated\n")
+	writeFile(t, filepath.Join(root, ".git", "info", "exclude"), "scratch.txt\n")
+	writeFile(t, filepath.Join(root, "sub", ".gitignore"), "local.txt\n")
+
+	for _, path := range []string{"a.txt", "debug.log", "node_modules/lib/index.js", "dist/bundle.js",
+		"scratch.txt", "sub/local.txt", "sub/b.txt", "tracked.log", "node_modules/tracked/index.js"} {
+		writeFile(t, filepath.Join(root, filepath.FromSlash(path)), "secret\n")
+	}
+
+	// files that were added before they were ignored, or with 'git add -f', are still searched
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"tracked.log", "node_modules/tracked/index.js"} {
+		_, err = worktree.Add(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	subPath := "sub"
+	for _, test := range []struct {
+		subPath  *string
+		expected []string
+	}{
+		{nil, []string{"a.txt", "node_modules/tracked/index.js", "sub/b.txt", "tracked.log"}},
+		{&subPath, []string{"sub/b.txt"}},
+	} {
+		repo := NewRepo("", "repo", root, test.subPath)
+
+		results, _, err := ProcessAllFiles[lineResult](&lineSearcher{}, repo, &SearchOptions{SkipIgnored: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if paths := resultPaths(results); !equalStrings(paths, test.expected) {
+			t.Errorf("got %v, want %v", paths, test.expected)
+		}
+	}
+
+	results, _, err := ProcessAllFiles[lineResult](&lineSearcher{}, NewRepo("", "repo", root, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 9 {
+		t.Errorf("ignored files should only be skipped if enabled, got %v", resultPaths(results))
+	}
+}
+
+func TestProcessAllCommitsSkipIgnored(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "repo")
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the files are committed before the attributes are set, so those only apply from the second commit, and
+	// .gitignore never applies since committed files are tracked
+	files := map[string]string{
+		"a.txt":                  "secret a\n",
+		"vendor/lib.txt":         "secret vendored\n",
+		"dir/build/output.txt":   "secret output\n",
+		"dir/generated/model.go": "secret generated\n",
+	}
+	first := storeCommit(t, repo, "2023-11-01", files)
+
+	files[".gitignore"] = "build/\n"
+	files[".gitattributes"] = "vendor/** linguist-vendored\n"
+	files["dir/.gitattributes"] = "generated/* linguist-generated\n"
+	files["a.txt"] = "secret a\nsecret again\n"
+	second := storeCommit(t, repo, "2023-11-02", files, first)
+
+	err = repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/main", second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		mode     Mode
+		expected []string
+	}{
+		{ModeAllBranches, []string{"a.txt", "dir/build/output.txt"}},
+		{ModeAllHistory, []string{"a.txt", "dir/build/output.txt", "dir/generated/model.go", "vendor/lib.txt"}},
+		{ModeDiff, []string{"a.txt", "dir/build/output.txt", "dir/generated/model.go", "vendor/lib.txt"}},
+	} {
+		results, _, err := ProcessAllCommits[lineResult](root, repo, &lineSearcher{}, test.mode, &SearchOptions{SkipIgnored: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if paths := resultPaths(results); !equalStrings(paths, test.expected) {
+			t.Errorf("%s: got %v, want %v", test.mode, paths, test.expected)
+		}
+
+		if test.mode != ModeAllHistory {
+			continue
+		}
+
+		// the generated and vendored files are only found in the first commit
+		for _, result := range results {
+			if result.Path == "a.txt" || result.Path == "dir/build/output.txt" || len(result.Results) == 0 {
+				continue
+			}
+
+			branches := result.Matches[result.Results[0]]
+			if len(branches) != 1 || branches[0].OnTip() {
+				t.Errorf("%s should not be found at the tip, got %v", result.Path, branches)
+			}
+		}
+	}
+
+	results, _, err := ProcessAllCommits[lineResult](root, repo, &lineSearcher{}, ModeAllBranches, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths := resultPaths(results); len(paths) != 4 {
+		t.Errorf("ignored files should only be skipped if enabled, got %v", paths)
+	}
+}
@@ -0,0 +1,192 @@
+package gitkit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+type RepoStatus string
+
+const (
+	RepoStatusActive   RepoStatus = "active"
+	RepoStatusArchived RepoStatus = "archived"
+	// RepoStatusRenamed is also used for repositories that were transferred to another owner.
+	RepoStatusRenamed RepoStatus = "renamed"
+	RepoStatusDeleted RepoStatus = "deleted"
+)
+
+// Manifest records the repositories fetched by repofetch sync, keyed by '<host>/<owner>/<repo>'. Manifests
+// written before other forges were supported are keyed by '<owner>/<repo>' and migrated to github.com when loaded.
+type Manifest struct {
+	UpdatedAt    time.Time                 `json:"updatedAt"`
+	Repositories map[string]*ManifestEntry `json:"repositories"`
+}
+
+// ManifestEntry is the state of a fetched repository. Head is the commit of the default branch and
+// RefsDigest the digest of all refs (see RefsDigest) the last time it was fetched, and verified if
+// verification is enabled. Path is relative to the directory the repositories are fetched into, and
+// empty if the repository has never been fetched.
+type ManifestEntry struct {
+	Url           string     `json:"url"`
+	Path          string     `json:"path"`
+	DefaultBranch string     `json:"defaultBranch,omitempty"`
+	Head          string     `json:"head,omitempty"`
+	RefsDigest    string     `json:"refsDigest,omitempty"`
+	Status        RepoStatus `json:"status"`
+	RenamedTo     string     `json:"renamedTo,omitempty"`
+	FetchedAt     *time.Time `json:"fetchedAt,omitempty"`
+	VerifiedAt    *time.Time `json:"verifiedAt,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+func NewManifest() *Manifest {
+	return &Manifest{
+		Repositories: make(map[string]*ManifestEntry),
+	}
+}
+
+// LoadManifest reads the manifest, an empty manifest is returned if the file does not exist. Keys without
+// a host are migrated to 'github.com/<owner>/<repo>'.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return NewManifest(), nil
+		}
+		return nil, err
+	}
+
+	manifest := NewManifest()
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	if manifest.Repositories == nil {
+		manifest.Repositories = make(map[string]*ManifestEntry)
+	}
+
+	for name, entry := range manifest.Repositories {
+		if entry.Path != "" && !filepath.IsLocal(entry.Path) {
+			return nil, fmt.Errorf("path '%s' of '%s' in manifest %s must be relative", entry.Path, name, path)
+		}
+	}
+
+	err = migrateManifestKeys(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate manifest %s: %w", path, err)
+	}
+
+	return manifest, nil
+}
+
+// migrateManifestKeys adds the host to '<owner>/<repo>' keys, which were only used for GitHub.
+func migrateManifestKeys(manifest *Manifest) error {
+	for name, entry := range manifest.Repositories {
+		if strings.Count(name, "/") != 1 {
+			continue
+		}
+
+		migrated := "github.com/" + name
+		_, found := manifest.Repositories[migrated]
+		if found {
+			return fmt.Errorf("both '%s' and '%s' are in the manifest, remove one of them", name, migrated)
+		}
+
+		delete(manifest.Repositories, name)
+		manifest.Repositories[migrated] = entry
+	}
+
+	return nil
+}
+
+// Save writes the manifest to a temporary file and renames it, so that an interrupted sync
+// never leaves a partial manifest.
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(data, '\n'))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// UpstreamStatus classifies a repository that was fetched as fullName based on the metadata returned by
+// the forge for that name. Forges redirect renamed and transferred repositories, so the metadata has a
+// different full name. info is nil if the repository was not found.
+func UpstreamStatus(fullName string, info *RepositoryInfo) (status RepoStatus, renamedTo string) {
+	if info == nil {
+		return RepoStatusDeleted, ""
+	}
+
+	if !strings.EqualFold(info.FullName, fullName) {
+		return RepoStatusRenamed, info.FullName
+	}
+
+	if info.Archived {
+		return RepoStatusArchived, ""
+	}
+
+	return RepoStatusActive, ""
+}
+
+// RefsDigest returns the SHA-256 of the sorted '<name> <hash>' lines of all refs that point to an object,
+// or an empty string if the repository has no such refs. Symbolic refs are skipped, the refs they point
+// to are already included.
+func RefsDigest(repo *git.Repository) (string, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			lines = append(lines, ref.Name().String()+" "+ref.Hash().String()+"\n")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	sort.Strings(lines)
+	digest := sha256.New()
+	for _, line := range lines {
+		digest.Write([]byte(line))
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
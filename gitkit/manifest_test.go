@@ -0,0 +1,180 @@
+package gitkit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+
+	manifest, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Repositories) != 0 {
+		t.Fatalf("expected empty manifest, got %v", manifest.Repositories)
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	manifest.UpdatedAt = now
+	manifest.Repositories["github.com/foo/bar"] = &ManifestEntry{
+		Url:        "https://github.com/foo/bar.git",
+		Path:       "foo/bar",
+		Head:       "0123456789012345678901234567890123456789",
+		Status:     RepoStatusActive,
+		VerifiedAt: &now,
+	}
+
+	err = manifest.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, found := loaded.Repositories["github.com/foo/bar"]
+	if !found || entry.Head != manifest.Repositories["github.com/foo/bar"].Head || !entry.VerifiedAt.Equal(now) || !loaded.UpdatedAt.Equal(now) {
+		t.Errorf("got %+v, want %+v", entry, manifest.Repositories["github.com/foo/bar"])
+	}
+
+	// the first clone failed
+	manifest.Repositories["github.com/foo/baz"] = &ManifestEntry{
+		Url:    "https://github.com/foo/baz.git",
+		Status: RepoStatusActive,
+		Error:  "clone failed",
+	}
+	err = manifest.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadManifest(path)
+	if err != nil {
+		t.Errorf("expected an entry that was never fetched to be accepted, got %v", err)
+	}
+
+	manifest.Repositories["github.com/foo/bar"].Path = "../bar"
+	err = manifest.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadManifest(path)
+	if err == nil {
+		t.Error("expected path outside of the directory to be rejected")
+	}
+}
+
+func TestLoadManifestMigratesKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+
+	manifest := NewManifest()
+	manifest.Repositories["foo/bar"] = &ManifestEntry{Url: "https://github.com/foo/bar.git", Path: "foo/bar", Status: RepoStatusActive}
+	manifest.Repositories["gitlab.com/group/sub/repo"] = &ManifestEntry{Url: "https://gitlab.com/group/sub/repo.git", Status: RepoStatusActive}
+	err := manifest.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, found := loaded.Repositories["github.com/foo/bar"]
+	if !found || entry.Path != "foo/bar" || len(loaded.Repositories) != 2 {
+		t.Errorf("expected 'foo/bar' to be migrated to github.com, got %v", loaded.Repositories)
+	}
+
+	manifest.Repositories["github.com/foo/bar"] = &ManifestEntry{Url: "https://github.com/foo/bar.git", Status: RepoStatusActive}
+	err = manifest.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadManifest(path)
+	if err == nil {
+		t.Error("expected both the old and the migrated key to be rejected")
+	}
+}
+
+func TestUpstreamStatus(t *testing.T) {
+	tests := []struct {
+		info      *RepositoryInfo
+		status    RepoStatus
+		renamedTo string
+	}{
+		{nil, RepoStatusDeleted, ""},
+		{&RepositoryInfo{FullName: "Foo/Bar"}, RepoStatusActive, ""},
+		{&RepositoryInfo{FullName: "foo/bar", Archived: true}, RepoStatusArchived, ""},
+		{&RepositoryInfo{FullName: "baz/bar"}, RepoStatusRenamed, "baz/bar"},
+	}
+
+	for _, test := range tests {
+		status, renamedTo := UpstreamStatus("foo/bar", test.info)
+		if status != test.status || renamedTo != test.renamedTo {
+			t.Errorf("got %s %s, want %s %s", status, renamedTo, test.status, test.renamedTo)
+		}
+	}
+}
+
+func TestRefsDigest(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := RefsDigest(repo)
+	if err != nil || digest != "" {
+		t.Fatalf("expected no digest for an empty repository, got %q, %v", digest, err)
+	}
+
+	setRef := func(name string, hash string) string {
+		err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), plumbing.NewHash(hash)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		digest, err := RefsDigest(repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return digest
+	}
+
+	branch := setRef("refs/remotes/origin/main", "1111111111111111111111111111111111111111")
+	if branch == "" {
+		t.Fatal("expected a digest")
+	}
+
+	// a tag or another branch changes the digest even though the default branch did not move
+	tag := setRef("refs/tags/v1", "2222222222222222222222222222222222222222")
+	if tag == branch {
+		t.Error("expected a new tag to change the digest")
+	}
+
+	moved := setRef("refs/tags/v1", "3333333333333333333333333333333333333333")
+	if moved == tag {
+		t.Error("expected a moved tag to change the digest")
+	}
+
+	// symbolic refs are not part of the digest
+	err = repo.Storer.SetReference(plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", "refs/remotes/origin/main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err = RefsDigest(repo)
+	if err != nil || digest != moved {
+		t.Errorf("expected symbolic refs to be skipped, got %q, %v", digest, err)
+	}
+}
@@ -0,0 +1,227 @@
+package gitkit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// MetadataSearcher is optionally implemented by a Searcher to handle metadata differently from files,
+// e.g. to not apply path filters. Otherwise Process is called with the pseudo-path and a nil hash.
+type MetadataSearcher[T SearchResult] interface {
+	ProcessMetadata(loadData func() []byte, path string) []T
+}
+
+// MetadataPath returns the pseudo-path of a metadata field, e.g. 'commit:<hash>:message', 'tag:<hash>:tagger' or
+// 'note:<annotated object hash>:<notes ref>' where the notes ref is without 'refs/notes/'.
+func MetadataPath(kind string, hash plumbing.Hash, field string) string {
+	return kind + ":" + hash.String() + ":" + field
+}
+
+type metadataState[T SearchResult] struct {
+	searcher Searcher[T]
+	results  []RepoResult[T]
+
+	size         uint64
+	numFiles     uint64
+	dataLoadTime int64
+	queryTime    int64
+}
+
+// processMetadata searches the commit and tag metadata of the whole repository, including dangling objects.
+// The committer is only searched if it is different from the author. If commitFilter is not nil, only the
+// commits it selects are searched, tags and notes are not filtered.
+func processMetadata[T SearchResult](path string,
+	repo *git.Repository,
+	searcher Searcher[T],
+	commitFilter *commitFilter) ([]RepoResult[T], Stats, error) {
+	start := time.Now()
+	objects, err := NewLazyRepoState(repo, DefaultObjectCacheSize)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+
+	commitHashes, err := commitFilter.selectCommits(repo, objects)
+	if err != nil {
+		return nil, Stats{}, fmt.Errorf("failed to filter commits in '%s': %w", path, err)
+	}
+	listFilesTime := time.Since(start).Nanoseconds()
+
+	state := &metadataState[T]{
+		searcher: searcher,
+		results:  make([]RepoResult[T], 0),
+	}
+
+	for _, hash := range sortedHashes(commitHashes) {
+		commit, err := objects.Commit(hash)
+		if err != nil {
+			return nil, Stats{}, fmt.Errorf("failed to read commit %s in '%s': %w", hash, path, err)
+		}
+
+		state.search(MetadataPath("commit", hash, "message"), commit.Message)
+		state.search(MetadataPath("commit", hash, "author"), signatureText(commit.Author))
+		if commit.Committer.Name != commit.Author.Name || commit.Committer.Email != commit.Author.Email {
+			state.search(MetadataPath("commit", hash, "committer"), signatureText(commit.Committer))
+		}
+	}
+
+	for _, hash := range sortedHashes(objects.TagHashes()) {
+		tag, err := objects.Tag(hash)
+		if err != nil {
+			return nil, Stats{}, fmt.Errorf("failed to read tag %s in '%s': %w", hash, path, err)
+		}
+
+		state.search(MetadataPath("tag", hash, "message"), tag.Message)
+		state.search(MetadataPath("tag", hash, "tagger"), signatureText(tag.Tagger))
+	}
+
+	err = state.searchNotes(repo, objects)
+	if err != nil {
+		return nil, Stats{}, fmt.Errorf("failed to search notes in '%s': %w", path, err)
+	}
+
+	return state.results, Stats{
+		queryTime:     state.queryTime,
+		dataLoadTime:  state.dataLoadTime,
+		listFilesTime: listFilesTime,
+		numFiles:      state.numFiles,
+		size:          state.size,
+	}, nil
+}
+
+// searchNotes searches the notes at the tip of each notes ref. Notes are stored in a tree where the path is the
+// hash of the annotated object, possibly split into directories (fanout).
+func (ms *metadataState[T]) searchNotes(repo *git.Repository, objects RepoObjects) error {
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	notesRefs := make([]*plumbing.Reference, 0)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && strings.HasPrefix(ref.Name().String(), "refs/notes/") {
+			notesRefs = append(notesRefs, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(notesRefs, func(i, j int) bool {
+		return notesRefs[i].Name().String() < notesRefs[j].Name().String()
+	})
+
+	for _, ref := range notesRefs {
+		commit, err := objects.Commit(ref.Hash())
+		if err != nil {
+			return fmt.Errorf("notes ref %s: %w", ref.Name(), err)
+		}
+
+		notesRef := strings.TrimPrefix(ref.Name().String(), "refs/notes/")
+		err = ms.searchNotesTree(objects, commit.TreeHash, "", notesRef)
+		if err != nil {
+			return fmt.Errorf("notes ref %s: %w", ref.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (ms *metadataState[T]) searchNotesTree(objects RepoObjects, treeHash plumbing.Hash, prefix string, notesRef string) error {
+	tree, err := objects.Tree(treeHash)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range tree.Entries {
+		if entry.Mode == filemode.Dir {
+			err = ms.searchNotesTree(objects, entry.Hash, prefix+entry.Name, notesRef)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		annotated := prefix + entry.Name
+		if len(annotated) != len(plumbing.ZeroHash.String()) {
+			// e.g. a '.gitattributes' in the notes tree
+			continue
+		}
+
+		start := time.Now()
+		blob, err := objects.Blob(entry.Hash)
+		if err != nil {
+			return err
+		}
+
+		r, err := blob.Reader()
+		if err != nil {
+			return err
+		}
+
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		ms.dataLoadTime += time.Since(start).Nanoseconds()
+
+		ms.search(MetadataPath("note", plumbing.NewHash(annotated), notesRef), string(data))
+	}
+
+	return nil
+}
+
+func (ms *metadataState[T]) search(path string, text string) {
+	if text == "" {
+		return
+	}
+
+	data := []byte(text)
+	ms.numFiles += 1
+	ms.size += uint64(len(data))
+
+	loadData := func() []byte {
+		return data
+	}
+
+	start := time.Now()
+	var results []T
+	metadataSearcher, ok := ms.searcher.(MetadataSearcher[T])
+	if ok {
+		results = metadataSearcher.ProcessMetadata(loadData, path)
+	} else {
+		results = ms.searcher.Process(nil, loadData, path)
+	}
+	ms.queryTime += time.Since(start).Nanoseconds()
+
+	if len(results) > 0 {
+		ms.results = append(ms.results, RepoResult[T]{
+			Path:    path,
+			Results: results,
+		})
+	}
+}
+
+func signatureText(signature object.Signature) string {
+	if signature.Name == "" && signature.Email == "" {
+		return ""
+	}
+
+	return signature.Name + " <" + signature.Email + ">"
+}
+
+func sortedHashes(hashes []plumbing.Hash) []plumbing.Hash {
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].String() < hashes[j].String()
+	})
+	return hashes
+}
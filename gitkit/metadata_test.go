@@ -0,0 +1,147 @@
+package gitkit
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestProcessMetadata(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := worktree.Filesystem.Create("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = worktree.Add("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	author := &object.Signature{Name: "Alice", Email: "alice@secret.example.com", When: time.Unix(1700000000, 0)}
+	committer := &object.Signature{Name: "Bob", Email: "bob@example.com", When: time.Unix(1700000000, 0)}
+	commitHash, err := worktree.Commit("add file\n\ntoken: secret123\n", &git.CommitOptions{Author: author, Committer: committer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tagRef, err := repo.CreateTag("v1", commitHash, &git.CreateTagOptions{Tagger: committer, Message: "release with secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notesCommit := createNote(t, repo, commitHash, "reviewed, secret approved\n", committer)
+	err = repo.Storer.SetReference(plumbing.NewHashReference("refs/notes/review", notesCommit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, _, err := processMetadata[lineResult]("test", repo, &lineSearcher{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make([]string, 0)
+	for _, result := range results {
+		paths = append(paths, result.Path)
+	}
+	sort.Strings(paths)
+
+	expected := []string{
+		MetadataPath("commit", commitHash, "author"),
+		MetadataPath("commit", commitHash, "message"),
+		MetadataPath("note", commitHash, "review"),
+		MetadataPath("tag", tagRef.Hash(), "message"),
+	}
+	sort.Strings(expected)
+
+	if len(paths) != len(expected) {
+		t.Fatalf("got %v, want %v", paths, expected)
+	}
+
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Errorf("got %s, want %s", paths[i], expected[i])
+		}
+	}
+
+	results, _, err = ProcessAllCommits[lineResult]("test", repo, &lineSearcher{}, ModeDiff, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range results {
+		if strings.HasPrefix(result.Path, "commit:") || strings.HasPrefix(result.Path, "tag:") {
+			t.Errorf("metadata should only be searched if enabled, got %s", result.Path)
+		}
+	}
+}
+
+// createNote stores a notes commit with a fanout path, like git does for large notes trees.
+func createNote(t *testing.T, repo *git.Repository, annotated plumbing.Hash, note string, signature *object.Signature) plumbing.Hash {
+	blob := repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte(note))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	blobHash, err := repo.Storer.SetEncodedObject(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := annotated.String()
+	fanout := storeTree(t, repo, &object.Tree{Entries: []object.TreeEntry{{Name: name[2:], Mode: filemode.Regular, Hash: blobHash}}})
+	root := storeTree(t, repo, &object.Tree{Entries: []object.TreeEntry{{Name: name[:2], Mode: filemode.Dir, Hash: fanout}}})
+
+	commit := &object.Commit{Author: *signature, Committer: *signature, Message: "Notes added by 'git notes add'", TreeHash: root}
+	obj := repo.Storer.NewEncodedObject()
+	err = commit.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func storeTree(t *testing.T, repo *git.Repository, tree *object.Tree) plumbing.Hash {
+	obj := repo.Storer.NewEncodedObject()
+	err := tree.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
@@ -0,0 +1,875 @@
+package gitkit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
+)
+
+const (
+	objectIndexVersion  = 3
+	objectIndexDir      = "gitkit"
+	objectIndexFileName = "object-index.json"
+	objectIndexKeySize  = 32
+)
+
+// ObjectIndex is an on-disk index of the commit graph and tag targets of a repository, stored in
+// <git dir>/gitkit/object-index.json. Packs are indexed once, keyed by their checksum, and only new
+// packs and loose objects are read when the index is opened again. The index also stores hashes of
+// commits and tags computed with other algorithms, e.g. SHA-256 or SHA-512, so they can be reused
+// across runs and tools. Hashes of trees and blobs are not stored.
+//
+// The index is trusted as follows:
+//   - the index is authenticated with an HMAC-SHA-256 key that is kept outside the repository, see
+//     ObjectIndexKeyPath. An index that does not match the key is discarded and rebuilt.
+//   - every indexed .pack and .idx file and every loose object file is hashed with SHA-512 when the
+//     index is opened, and entries for files that have changed are discarded and read again. This reads
+//     the files, but does not decode or hash the objects in them.
+//   - the stored hashes are discarded when any indexed object is removed or changed, or when a new pack
+//     or loose object has the same hash as an object that is already indexed, since a hash depends on
+//     all the objects it references.
+type ObjectIndex struct {
+	fs    billy.Filesystem
+	dir   *dotgit.DotGit
+	key   []byte
+	mutex sync.RWMutex
+	data  objectIndexData
+	dirty bool
+
+	commits map[plumbing.Hash]commitEntry
+	tags    map[plumbing.Hash]plumbing.Hash
+}
+
+type commitEntry struct {
+	tree    plumbing.Hash
+	parents []plumbing.Hash
+}
+
+// objectIndexFile is the file on disk, the MAC is computed over the raw Data.
+type objectIndexFile struct {
+	Version int             `json:"version"`
+	MAC     string          `json:"mac"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type objectIndexData struct {
+	Packs   map[string]*packSegment      `json:"packs"`
+	Loose   *looseSegment                `json:"loose"`
+	Digests map[string]map[string]string `json:"digests"`
+}
+
+type indexSegment struct {
+	Commits map[string]indexedCommit `json:"commits"`
+	Tags    map[string]indexedTag    `json:"tags"`
+}
+
+// packSegment has the SHA-512 of the .pack and .idx files at the time they were indexed.
+type packSegment struct {
+	indexSegment
+	PackSHA512 string `json:"packSha512"`
+	IdxSHA512  string `json:"idxSha512"`
+}
+
+// looseSegment records every loose object, including trees and blobs so they are not read again,
+// with the SHA-512 of the object file.
+type looseSegment struct {
+	indexSegment
+	Objects map[string]string `json:"objects"`
+}
+
+type indexedCommit struct {
+	Tree    string   `json:"tree"`
+	Parents []string `json:"parents"`
+}
+
+type indexedTag struct {
+	Target string `json:"target"`
+}
+
+func newIndexSegment() indexSegment {
+	return indexSegment{
+		Commits: make(map[string]indexedCommit),
+		Tags:    make(map[string]indexedTag),
+	}
+}
+
+// ObjectIndexKeyPath is where the key that authenticates object indexes is kept, ~/.config/gitkit/object-index.key.
+func ObjectIndexKeyPath() (string, error) {
+	homeDirectory, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDirectory, ".config", "gitkit", "object-index.key"), nil
+}
+
+// loadOrCreateObjectIndexKey reads the key at ObjectIndexKeyPath, a new random key is created if there is none.
+func loadOrCreateObjectIndexKey() ([]byte, error) {
+	keyPath, err := ObjectIndexKeyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != objectIndexKeySize {
+			return nil, fmt.Errorf("invalid object index key in %s", keyPath)
+		}
+		return key, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, objectIndexKeySize)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(keyPath), 0o700)
+	if err != nil {
+		return nil, err
+	}
+
+	// O_EXCL so that concurrent runs agree on the key
+	f, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return loadOrCreateObjectIndexKey()
+		}
+		return nil, err
+	}
+
+	_, err = f.WriteString(hex.EncodeToString(key) + "\n")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return key, f.Close()
+}
+
+// OpenObjectIndex reads the index of the repository and updates it with packs and loose objects
+// that are not indexed yet. Packs that no longer exist are removed. The updated index is saved.
+// The index is authenticated with the key at ObjectIndexKeyPath, which is created if needed.
+func OpenObjectIndex(repo *git.Repository) (*ObjectIndex, error) {
+	key, err := loadOrCreateObjectIndexKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load object index key: %w", err)
+	}
+
+	return OpenObjectIndexWithKey(repo, key)
+}
+
+// OpenExistingObjectIndex is like OpenObjectIndex, but returns nil if the repository has no index yet,
+// e.g. so that a tool can reuse the index created by another tool without creating one itself.
+func OpenExistingObjectIndex(repo *git.Repository) (*ObjectIndex, error) {
+	fsStorage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil, nil
+	}
+
+	_, err := fsStorage.Filesystem().Stat(path.Join(objectIndexDir, objectIndexFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return OpenObjectIndex(repo)
+}
+
+// OpenObjectIndexWithKey is like OpenObjectIndex, but authenticates the index with the key.
+func OpenObjectIndexWithKey(repo *git.Repository, key []byte) (*ObjectIndex, error) {
+	fsStorage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil, fmt.Errorf("the object index requires a repository on disk")
+	}
+
+	if len(key) == 0 {
+		return nil, fmt.Errorf("the object index requires a key")
+	}
+
+	index := &ObjectIndex{
+		fs:  fsStorage.Filesystem(),
+		dir: dotgit.New(fsStorage.Filesystem()),
+		key: key,
+	}
+
+	err := index.load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = index.update(fsStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	err = index.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func (idx *ObjectIndex) reset() {
+	idx.data = objectIndexData{
+		Packs:   make(map[string]*packSegment),
+		Loose:   &looseSegment{indexSegment: newIndexSegment(), Objects: make(map[string]string)},
+		Digests: make(map[string]map[string]string),
+	}
+	idx.dirty = true
+}
+
+func (idx *ObjectIndex) load() error {
+	idx.reset()
+
+	f, err := idx.fs.Open(path.Join(objectIndexDir, objectIndexFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	raw, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	var file objectIndexFile
+	err = json.Unmarshal(raw, &file)
+	if err != nil || file.Version != objectIndexVersion {
+		// rebuild rather than migrate, the index can always be recomputed
+		return nil
+	}
+
+	mac, err := hex.DecodeString(file.MAC)
+	if err != nil || !hmac.Equal(mac, idx.mac(file.Data)) {
+		slog.Warn("object index is not authenticated by the local key, rebuilding it", "path", path.Join(objectIndexDir, objectIndexFileName))
+		return nil
+	}
+
+	var data objectIndexData
+	err = json.Unmarshal(file.Data, &data)
+	if err != nil {
+		return fmt.Errorf("failed to parse object index: %w", err)
+	}
+
+	if data.Packs != nil {
+		idx.data.Packs = data.Packs
+	}
+
+	if data.Loose != nil && data.Loose.Objects != nil {
+		idx.data.Loose = data.Loose
+	}
+
+	if data.Digests != nil {
+		idx.data.Digests = data.Digests
+	}
+
+	idx.dirty = false
+	return nil
+}
+
+func (idx *ObjectIndex) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, idx.key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (idx *ObjectIndex) update(fsStorage *filesystem.Storage) error {
+	packs, err := fsStorage.ObjectPacks()
+	if err != nil {
+		return err
+	}
+
+	// existing are the indexed objects that are unchanged, used to find new objects with the same hash
+	existing := &knownObjects{dir: idx.dir, loose: make(map[string]string)}
+	invalidate := false
+
+	current := make(map[string]bool)
+	newPacks := make([]plumbing.Hash, 0)
+	for _, pack := range packs {
+		current[pack.String()] = true
+
+		segment, found := idx.data.Packs[pack.String()]
+		if !found {
+			newPacks = append(newPacks, pack)
+			continue
+		}
+
+		packSHA512, idxSHA512, err := idx.packDigests(pack)
+		if err != nil {
+			return err
+		}
+
+		if packSHA512 != segment.PackSHA512 || idxSHA512 != segment.IdxSHA512 {
+			slog.Warn("indexed pack has changed, indexing it again", "pack", pack.String())
+			delete(idx.data.Packs, pack.String())
+			newPacks = append(newPacks, pack)
+			invalidate = true
+			continue
+		}
+
+		existing.packs = append(existing.packs, pack)
+	}
+
+	for checksum := range idx.data.Packs {
+		if !current[checksum] {
+			delete(idx.data.Packs, checksum)
+			idx.dirty = true
+			invalidate = true
+		}
+	}
+
+	newObjects, changed, err := idx.verifyLoose(fsStorage, existing)
+	if err != nil {
+		return err
+	}
+	invalidate = invalidate || changed
+
+	for _, pack := range newPacks {
+		segment, duplicate, err := idx.indexPack(pack, existing)
+		if err != nil {
+			return fmt.Errorf("failed to index pack %s: %w", pack, err)
+		}
+
+		idx.data.Packs[pack.String()] = segment
+		idx.dirty = true
+		invalidate = invalidate || duplicate
+	}
+
+	duplicate, err := idx.addLoose(fsStorage, newObjects, existing)
+	if err != nil {
+		return err
+	}
+	invalidate = invalidate || duplicate
+
+	if invalidate && len(idx.data.Digests) > 0 {
+		idx.data.Digests = make(map[string]map[string]string)
+		idx.dirty = true
+	}
+
+	return idx.buildLookups()
+}
+
+// verifyLoose verifies the indexed loose objects and forgets the ones that are gone, typically because they
+// have been packed. It returns the loose objects that are not indexed yet and true if an indexed object was
+// removed or changed.
+func (idx *ObjectIndex) verifyLoose(fsStorage *filesystem.Storage, existing *knownObjects) ([]plumbing.Hash, bool, error) {
+	loose := idx.data.Loose
+	changed := false
+
+	current := make(map[string]bool)
+	newObjects := make([]plumbing.Hash, 0)
+	err := fsStorage.ForEachObjectHash(func(hash plumbing.Hash) error {
+		current[hash.String()] = true
+
+		indexedSHA512, found := loose.Objects[hash.String()]
+		if !found {
+			newObjects = append(newObjects, hash)
+			return nil
+		}
+
+		fileSHA512, err := idx.looseDigest(hash)
+		if err != nil {
+			return err
+		}
+
+		if fileSHA512 != indexedSHA512 {
+			slog.Warn("indexed loose object has changed, reading it again", "object", hash.String())
+			removeFromSegment(&loose.indexSegment, hash.String())
+			delete(loose.Objects, hash.String())
+			newObjects = append(newObjects, hash)
+			changed = true
+			return nil
+		}
+
+		existing.loose[hash.String()] = fileSHA512
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	for hash := range loose.Objects {
+		if !current[hash] {
+			removeFromSegment(&loose.indexSegment, hash)
+			delete(loose.Objects, hash)
+			idx.dirty = true
+			changed = true
+		}
+	}
+
+	return newObjects, changed, nil
+}
+
+// addLoose reads the new loose objects. It returns true if one of them has the same hash as an object that
+// is already indexed.
+func (idx *ObjectIndex) addLoose(fsStorage *filesystem.Storage, newObjects []plumbing.Hash, existing *knownObjects) (bool, error) {
+	loose := idx.data.Loose
+	duplicate := false
+
+	for _, hash := range newObjects {
+		found, err := existing.contains(hash)
+		if err != nil {
+			return false, err
+		}
+		duplicate = duplicate || found
+
+		fileSHA512, err := idx.looseDigest(hash)
+		if err != nil {
+			return false, err
+		}
+
+		obj, err := fsStorage.EncodedObject(plumbing.AnyObject, hash)
+		if err != nil {
+			return false, err
+		}
+
+		err = addToSegment(&loose.indexSegment, obj)
+		if err != nil {
+			return false, err
+		}
+
+		loose.Objects[hash.String()] = fileSHA512
+		idx.dirty = true
+	}
+
+	return duplicate, nil
+}
+
+// indexPack reads the commits and tags of the pack. It also returns true if the pack has an object that is
+// already indexed.
+func (idx *ObjectIndex) indexPack(pack plumbing.Hash, existing *knownObjects) (*packSegment, bool, error) {
+	packSHA512, idxSHA512, err := idx.packDigests(pack)
+	if err != nil {
+		return nil, false, err
+	}
+
+	memoryIndex, err := readPackIdx(idx.dir, pack)
+	if err != nil {
+		return nil, false, err
+	}
+
+	duplicate := false
+	entries, err := memoryIndex.Entries()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for {
+		entry, err := entries.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		found, err := existing.contains(entry.Hash)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if found {
+			duplicate = true
+			break
+		}
+	}
+	entries.Close()
+
+	packFile, err := idx.dir.ObjectPack(pack)
+	if err != nil {
+		return nil, false, err
+	}
+
+	p := packfile.NewPackfile(memoryIndex, idx.fs, packFile, 0)
+	defer p.Close()
+
+	segment := &packSegment{
+		indexSegment: newIndexSegment(),
+		PackSHA512:   packSHA512,
+		IdxSHA512:    idxSHA512,
+	}
+
+	for _, objectType := range []plumbing.ObjectType{plumbing.CommitObject, plumbing.TagObject} {
+		var iter storer.EncodedObjectIter
+		iter, err = p.GetByType(objectType)
+		if err != nil {
+			return nil, false, err
+		}
+
+		err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+			return addToSegment(&segment.indexSegment, obj)
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return segment, duplicate, nil
+}
+
+func (idx *ObjectIndex) packDigests(pack plumbing.Hash) (string, string, error) {
+	packFile, err := idx.dir.ObjectPack(pack)
+	if err != nil {
+		return "", "", err
+	}
+
+	packSHA512, err := fileSHA512(packFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	idxFile, err := idx.dir.ObjectPackIdx(pack)
+	if err != nil {
+		return "", "", err
+	}
+
+	idxSHA512, err := fileSHA512(idxFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	return packSHA512, idxSHA512, nil
+}
+
+func (idx *ObjectIndex) looseDigest(hash plumbing.Hash) (string, error) {
+	f, err := idx.dir.Object(hash)
+	if err != nil {
+		return "", err
+	}
+
+	return fileSHA512(f)
+}
+
+// fileSHA512 hashes and closes the file.
+func fileSHA512(f billy.File) (string, error) {
+	defer f.Close()
+
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha512.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readPackIdx(dir *dotgit.DotGit, pack plumbing.Hash) (*idxfile.MemoryIndex, error) {
+	idxFile, err := dir.ObjectPackIdx(pack)
+	if err != nil {
+		return nil, err
+	}
+	defer idxFile.Close()
+
+	memoryIndex := idxfile.NewMemoryIndex()
+	err = idxfile.NewDecoder(idxFile).Decode(memoryIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return memoryIndex, nil
+}
+
+// knownObjects answers whether an object is in one of the unchanged indexed packs or loose objects.
+// The .idx files of the packs are only read when needed.
+type knownObjects struct {
+	dir      *dotgit.DotGit
+	packs    []plumbing.Hash
+	loose    map[string]string
+	packIdxs []*idxfile.MemoryIndex
+}
+
+func (k *knownObjects) contains(hash plumbing.Hash) (bool, error) {
+	_, found := k.loose[hash.String()]
+	if found {
+		return true, nil
+	}
+
+	if k.packIdxs == nil {
+		k.packIdxs = make([]*idxfile.MemoryIndex, 0, len(k.packs))
+		for _, pack := range k.packs {
+			memoryIndex, err := readPackIdx(k.dir, pack)
+			if err != nil {
+				return false, err
+			}
+			k.packIdxs = append(k.packIdxs, memoryIndex)
+		}
+	}
+
+	for _, memoryIndex := range k.packIdxs {
+		found, err := memoryIndex.Contains(hash)
+		if err != nil {
+			return false, err
+		}
+
+		if found {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func addToSegment(segment *indexSegment, obj plumbing.EncodedObject) error {
+	switch obj.Type() {
+	case plumbing.CommitObject:
+		commit := &object.Commit{}
+		err := commit.Decode(obj)
+		if err != nil {
+			return fmt.Errorf("failed to decode commit %s: %w", obj.Hash(), err)
+		}
+
+		parents := make([]string, 0, len(commit.ParentHashes))
+		for _, parent := range commit.ParentHashes {
+			parents = append(parents, parent.String())
+		}
+
+		segment.Commits[obj.Hash().String()] = indexedCommit{
+			Tree:    commit.TreeHash.String(),
+			Parents: parents,
+		}
+	case plumbing.TagObject:
+		tag := &object.Tag{}
+		err := tag.Decode(obj)
+		if err != nil {
+			return fmt.Errorf("failed to decode tag %s: %w", obj.Hash(), err)
+		}
+
+		segment.Tags[obj.Hash().String()] = indexedTag{
+			Target: tag.Target.String(),
+		}
+	}
+
+	return nil
+}
+
+func removeFromSegment(segment *indexSegment, hash string) {
+	delete(segment.Commits, hash)
+	delete(segment.Tags, hash)
+}
+
+// buildLookups merges the segments, an object that is in more than one segment must be the same in all of them.
+func (idx *ObjectIndex) buildLookups() error {
+	idx.commits = make(map[plumbing.Hash]commitEntry)
+	idx.tags = make(map[plumbing.Hash]plumbing.Hash)
+
+	segments := []*indexSegment{&idx.data.Loose.indexSegment}
+	for _, segment := range idx.data.Packs {
+		segments = append(segments, &segment.indexSegment)
+	}
+
+	for _, segment := range segments {
+		for hash, c := range segment.Commits {
+			parents := make([]plumbing.Hash, 0, len(c.Parents))
+			for _, parent := range c.Parents {
+				parents = append(parents, plumbing.NewHash(parent))
+			}
+
+			entry := commitEntry{tree: plumbing.NewHash(c.Tree), parents: parents}
+			previous, found := idx.commits[plumbing.NewHash(hash)]
+			if found && !sameCommitEntry(previous, entry) {
+				return fmt.Errorf("commit %s differs between objects with the same hash", hash)
+			}
+
+			idx.commits[plumbing.NewHash(hash)] = entry
+		}
+
+		for hash, t := range segment.Tags {
+			target := plumbing.NewHash(t.Target)
+			previous, found := idx.tags[plumbing.NewHash(hash)]
+			if found && previous != target {
+				return fmt.Errorf("tag %s differs between objects with the same hash", hash)
+			}
+
+			idx.tags[plumbing.NewHash(hash)] = target
+		}
+	}
+
+	return nil
+}
+
+func sameCommitEntry(a commitEntry, b commitEntry) bool {
+	if a.tree != b.tree || len(a.parents) != len(b.parents) {
+		return false
+	}
+
+	for i := range a.parents {
+		if a.parents[i] != b.parents[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Save writes the index if it has changed since it was read. It is a no-op on a nil index.
+func (idx *ObjectIndex) Save() error {
+	if idx == nil {
+		return nil
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	if !idx.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(idx.data)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(objectIndexFile{
+		Version: objectIndexVersion,
+		MAC:     hex.EncodeToString(idx.mac(data)),
+		Data:    data,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = idx.fs.MkdirAll(objectIndexDir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename, so concurrent readers never see a partial index
+	tmp, err := idx.fs.TempFile(objectIndexDir, objectIndexFileName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(raw)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = idx.fs.Rename(tmp.Name(), path.Join(objectIndexDir, objectIndexFileName))
+	if err != nil {
+		return err
+	}
+
+	idx.dirty = false
+	return nil
+}
+
+func (idx *ObjectIndex) CommitHashes() []plumbing.Hash {
+	result := make([]plumbing.Hash, 0, len(idx.commits))
+	for hash := range idx.commits {
+		result = append(result, hash)
+	}
+
+	return result
+}
+
+func (idx *ObjectIndex) TagHashes() []plumbing.Hash {
+	result := make([]plumbing.Hash, 0, len(idx.tags))
+	for hash := range idx.tags {
+		result = append(result, hash)
+	}
+
+	return result
+}
+
+// Parents returns the parents of the commit without reading it from the repository.
+func (idx *ObjectIndex) Parents(commit plumbing.Hash) ([]plumbing.Hash, bool) {
+	entry, found := idx.commits[commit]
+	return entry.parents, found
+}
+
+// Tree returns the tree of the commit without reading it from the repository.
+func (idx *ObjectIndex) Tree(commit plumbing.Hash) (plumbing.Hash, bool) {
+	entry, found := idx.commits[commit]
+	return entry.tree, found
+}
+
+// TagTarget returns the object the annotated tag points to without reading it from the repository.
+func (idx *ObjectIndex) TagTarget(tag plumbing.Hash) (plumbing.Hash, bool) {
+	target, found := idx.tags[tag]
+	return target, found
+}
+
+// Digest returns the hash of the commit or tag computed with the algorithm, e.g. "sha512", if it has been added.
+func (idx *ObjectIndex) Digest(algorithm string, hash plumbing.Hash) ([]byte, bool) {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	digest, found := idx.data.Digests[algorithm][hash.String()]
+	if !found {
+		return nil, false
+	}
+
+	result, err := hex.DecodeString(digest)
+	if err != nil {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// AddDigest stores the hash of a commit or tag computed with the algorithm. Digests of other objects are
+// ignored, since they would make the index as large as the repository. Call Save to persist it.
+func (idx *ObjectIndex) AddDigest(algorithm string, hash plumbing.Hash, digest []byte) {
+	_, isCommit := idx.commits[hash]
+	_, isTag := idx.tags[hash]
+	if !isCommit && !isTag {
+		return
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	digests, found := idx.data.Digests[algorithm]
+	if !found {
+		digests = make(map[string]string)
+		idx.data.Digests[algorithm] = digests
+	}
+
+	digests[hash.String()] = hex.EncodeToString(digest)
+	idx.dirty = true
+}
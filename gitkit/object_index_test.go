@@ -0,0 +1,199 @@
+package gitkit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestObjectIndex(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := &object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Unix(1700000000, 0)}
+	commit := func(message string) plumbing.Hash {
+		t.Helper()
+
+		hash, err := worktree.Commit(message, &git.CommitOptions{Author: signature, AllowEmptyCommits: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	first := commit("first")
+	second := commit("second")
+
+	tagRef, err := repo.CreateTag("v1", second, &git.CreateTagOptions{Tagger: signature, Message: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := bytes.Repeat([]byte{1}, objectIndexKeySize)
+	index, err := OpenObjectIndexWithKey(repo, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalHashes(index.CommitHashes(), []plumbing.Hash{first, second}) {
+		t.Errorf("got commits %v, want %v", index.CommitHashes(), []plumbing.Hash{first, second})
+	}
+
+	if !equalHashes(index.TagHashes(), []plumbing.Hash{tagRef.Hash()}) {
+		t.Errorf("got tags %v, want %s", index.TagHashes(), tagRef.Hash())
+	}
+
+	parents, found := index.Parents(second)
+	if !found || !equalHashes(parents, []plumbing.Hash{first}) {
+		t.Errorf("got parents %v for second commit, want %s", parents, first)
+	}
+
+	target, found := index.TagTarget(tagRef.Hash())
+	if !found || target != second {
+		t.Errorf("got tag target %s, want %s", target, second)
+	}
+
+	digest := bytes.Repeat([]byte{0xab}, 64)
+	index.AddDigest("sha512", first, digest)
+	err = index.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, ".git", "gitkit", "object-index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err = OpenObjectIndexWithKey(repo, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, found := index.Digest("sha512", first)
+	if !found || !bytes.Equal(saved, digest) {
+		t.Errorf("expected the digest to be persisted, got %x", saved)
+	}
+
+	// an index written with another key is not trusted
+	index, err = OpenObjectIndexWithKey(repo, bytes.Repeat([]byte{2}, objectIndexKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found = index.Digest("sha512", first)
+	if found {
+		t.Errorf("expected the digest to be discarded with another key")
+	}
+
+	if !equalHashes(index.CommitHashes(), []plumbing.Hash{first, second}) {
+		t.Errorf("got commits %v after rebuilding, want %v", index.CommitHashes(), []plumbing.Hash{first, second})
+	}
+
+	index.AddDigest("sha512", first, digest)
+	err = index.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.RepackObjects(&git.RepackConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	third := commit("third")
+
+	index, err = OpenObjectIndexWithKey(repo, bytes.Repeat([]byte{2}, objectIndexKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.data.Packs) != 1 {
+		t.Errorf("expected one indexed pack, got %d", len(index.data.Packs))
+	}
+
+	if !equalHashes(index.CommitHashes(), []plumbing.Hash{first, second, third}) {
+		t.Errorf("got commits %v, want %v", index.CommitHashes(), []plumbing.Hash{first, second, third})
+	}
+
+	if len(index.data.Loose.Commits) != 1 {
+		t.Errorf("expected only the third commit to be loose, got %v", index.data.Loose.Commits)
+	}
+
+	// the objects were moved into a pack, so the digests are computed again
+	_, found = index.Digest("sha512", first)
+	if found {
+		t.Errorf("expected the digest to be discarded after repacking")
+	}
+
+	state, err := NewLazyRepoStateFromIndex(repo, index, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := state.Commit(third)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Message != "third" {
+		t.Errorf("got message '%s', want 'third'", c.Message)
+	}
+
+	tags := state.TagsForTarget(second)
+	if len(tags) != 1 || tags[0].Name != "v1" {
+		t.Errorf("got tags %v for target, want v1", tags)
+	}
+}
+
+func TestObjectIndexKey(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := OpenExistingObjectIndex(repo)
+	if err != nil || index != nil {
+		t.Fatalf("expected no index, got %v, %v", index, err)
+	}
+
+	_, err = OpenObjectIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath, err := ObjectIndexKeyPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the key to only be readable by the user, got %s", info.Mode().Perm())
+	}
+
+	index, err = OpenExistingObjectIndex(repo)
+	if err != nil || index == nil {
+		t.Fatalf("expected the index to be reused, got %v, %v", index, err)
+	}
+}
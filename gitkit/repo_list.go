@@ -0,0 +1,179 @@
+package gitkit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// RepoPin is an entry in a repo list. Ref and Commit are optional, if Commit is set the ref, or the default
+// branch if Ref is empty, is expected to point to a commit with that full SHA-1 hash. Abbreviated hashes are not
+// accepted, since it is feasible to create a commit that matches a short prefix. SHA-256 hashes are rejected until
+// SHA-256 repositories can be fetched, go-git only supports SHA-1.
+type RepoPin struct {
+	Url    string `json:"url"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit,omitempty"`
+}
+
+type repoList struct {
+	Repositories []RepoPin `json:"repositories"`
+}
+
+func LoadRepoList(path string) ([]RepoPin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pins, err := ParseRepoList(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repo list %s: %w", path, err)
+	}
+
+	return pins, nil
+}
+
+// ParseRepoList accepts JSON, either {"repositories": [...]} or a plain array of RepoPin, or one repo per
+// line as '<url> [ref] [commit]'. Empty lines and lines starting with '#' are ignored.
+func ParseRepoList(data []byte) ([]RepoPin, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	var pins []RepoPin
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		list := &repoList{}
+		err := json.Unmarshal(trimmed, list)
+		if err != nil {
+			return nil, err
+		}
+		pins = list.Repositories
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		err := json.Unmarshal(trimmed, &pins)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		lineNumber := 0
+		for scanner.Scan() {
+			lineNumber++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			fields := strings.Fields(line)
+			if len(fields) > 3 {
+				return nil, fmt.Errorf("line %d: expected '<url> [ref] [commit]', got '%s'", lineNumber, line)
+			}
+
+			pin := RepoPin{Url: fields[0]}
+			if len(fields) > 1 {
+				pin.Ref = fields[1]
+			}
+			if len(fields) > 2 {
+				pin.Commit = fields[2]
+			}
+			pins = append(pins, pin)
+		}
+
+		err := scanner.Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range pins {
+		pins[i].Commit = strings.ToLower(pins[i].Commit)
+
+		if pins[i].Url == "" {
+			return nil, fmt.Errorf("entry %d has no url", i+1)
+		}
+
+		if pins[i].Commit != "" {
+			_, err := hex.DecodeString(pins[i].Commit)
+			if err == nil && len(pins[i].Commit) == 64 {
+				return nil, fmt.Errorf("commit '%s' of '%s' is a SHA-256 hash, only SHA-1 repositories are supported", pins[i].Commit, pins[i].Url)
+			}
+			if err != nil || len(pins[i].Commit) != 40 {
+				return nil, fmt.Errorf("commit '%s' of '%s' must be a full SHA-1 hash of 40 hex characters, got %d", pins[i].Commit, pins[i].Url, len(pins[i].Commit))
+			}
+		}
+	}
+
+	return pins, nil
+}
+
+// Check resolves the pinned ref in a fetched repo and compares it to the expected commit. matched is true
+// if no commit is pinned.
+func (p *RepoPin) Check(repo *git.Repository) (commit plumbing.Hash, matched bool, err error) {
+	commit, err = ResolveFetchedRef(repo, p.Ref)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	if p.Commit == "" {
+		return commit, true, nil
+	}
+
+	return commit, commit.String() == p.Commit, nil
+}
+
+// ResolveFetchedRef returns the commit that ref points to after a clone or fetch. A short name is looked up as a
+// remote branch, a tag and a local branch (bare repos), in that order. Empty or 'HEAD' is the default branch,
+// i.e. the branch checked out by the clone. Annotated tags are peeled.
+func ResolveFetchedRef(repo *git.Repository, ref string) (plumbing.Hash, error) {
+	var candidates []plumbing.ReferenceName
+	switch {
+	case ref == "" || ref == "HEAD":
+		// a fetch doesn't move the local branch, so prefer the remote branch that HEAD is named after
+		candidates = []plumbing.ReferenceName{plumbing.NewRemoteHEADReferenceName("origin")}
+		head, err := repo.Reference(plumbing.HEAD, false)
+		if err == nil && head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+			candidates = append(candidates, plumbing.NewRemoteReferenceName("origin", head.Target().Short()))
+		}
+		candidates = append(candidates, plumbing.HEAD)
+	case strings.HasPrefix(ref, "refs/heads/"):
+		candidates = []plumbing.ReferenceName{plumbing.NewRemoteReferenceName("origin", strings.TrimPrefix(ref, "refs/heads/")), plumbing.ReferenceName(ref)}
+	case strings.HasPrefix(ref, "refs/"):
+		candidates = []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	default:
+		candidates = []plumbing.ReferenceName{plumbing.NewRemoteReferenceName("origin", ref), plumbing.NewTagReferenceName(ref), plumbing.NewBranchReferenceName(ref)}
+	}
+
+	for _, name := range candidates {
+		resolved, err := repo.Reference(name, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		tag, err := repo.TagObject(resolved.Hash())
+		if err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return plumbing.ZeroHash, fmt.Errorf("tag '%s' does not point to a commit: %w", name, err)
+			}
+			return commit.Hash, nil
+		}
+
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return plumbing.ZeroHash, err
+		}
+
+		return resolved.Hash(), nil
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("ref '%s' not found", ref)
+}
@@ -0,0 +1,102 @@
+package gitkit
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestParseRepoList(t *testing.T) {
+	want := []RepoPin{
+		{Url: "github.com/foo/bar"},
+		{Url: "gitlab.com/group/sub/repo", Ref: "v1.0.0", Commit: "0123456789abcdef0123456789abcdef01234567"},
+		{Url: "codeberg.org/foo/baz", Ref: "main"},
+	}
+
+	lines := `
+# audit scope
+github.com/foo/bar
+gitlab.com/group/sub/repo v1.0.0 0123456789ABCDEF0123456789ABCDEF01234567
+codeberg.org/foo/baz main
+`
+	jsonObject := `{"repositories": [
+  {"url": "github.com/foo/bar"},
+  {"url": "gitlab.com/group/sub/repo", "ref": "v1.0.0", "commit": "0123456789abcdef0123456789abcdef01234567"},
+  {"url": "codeberg.org/foo/baz", "ref": "main"}
+]}`
+	jsonArray := `[{"url": "github.com/foo/bar"}, {"url": "gitlab.com/group/sub/repo", "ref": "v1.0.0", "commit": "0123456789abcdef0123456789abcdef01234567"}, {"url": "codeberg.org/foo/baz", "ref": "main"}]`
+
+	for _, input := range []string{lines, jsonObject, jsonArray} {
+		pins, err := ParseRepoList([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(pins, want) {
+			t.Errorf("got %v, want %v", pins, want)
+		}
+	}
+
+	for _, invalid := range []string{"github.com/foo/bar main abc", "github.com/foo/bar main 0123456 extra", "github.com/foo/bar main 0123456", `[{"ref": "main"}]`,
+		"github.com/foo/bar main 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"} {
+		_, err := ParseRepoList([]byte(invalid))
+		if err == nil {
+			t.Errorf("expected '%s' to fail", invalid)
+		}
+	}
+}
+
+func TestRepoPinCheck(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := &object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Unix(1700000000, 0)}
+	first, err := worktree.Commit("first", &git.CommitOptions{Author: signature, AllowEmptyCommits: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.CreateTag("v1", first, &git.CreateTagOptions{Tagger: signature, Message: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := worktree.Commit("second", &git.CommitOptions{Author: signature, AllowEmptyCommits: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pin     RepoPin
+		want    string
+		matched bool
+		wantErr bool
+	}{
+		{RepoPin{Ref: "v1", Commit: first.String()}, first.String(), true, false},
+		{RepoPin{Ref: "refs/tags/v1", Commit: second.String()}, first.String(), false, false},
+		{RepoPin{Ref: "master"}, second.String(), true, false},
+		{RepoPin{Commit: second.String()}, second.String(), true, false},
+		{RepoPin{Ref: "missing"}, "", false, true},
+	}
+
+	for _, tt := range tests {
+		commit, matched, err := tt.pin.Check(repo)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Check(%+v) error = %v, wantErr %v", tt.pin, err, tt.wantErr)
+		}
+
+		if err == nil && (commit.String() != tt.want || matched != tt.matched) {
+			t.Errorf("Check(%+v) = %s %t, want %s %t", tt.pin, commit, matched, tt.want, tt.matched)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package gitkit
+
+import (
+	"container/list"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/supply-chain-tools/go-sandbox/hashset"
+)
+
+// DefaultObjectCacheSize is the number of decoded commits and trees kept in memory by a LazyRepoState.
+const DefaultObjectCacheSize = 65536
+
+// RepoObjects gives access to the objects of a repository. Lookups return plumbing.ErrObjectNotFound
+// if the object does not exist or is of a different type. Implementations are safe for concurrent use.
+type RepoObjects interface {
+	Commit(hash plumbing.Hash) (*object.Commit, error)
+	Tag(hash plumbing.Hash) (*object.Tag, error)
+	Tree(hash plumbing.Hash) (*object.Tree, error)
+	Blob(hash plumbing.Hash) (*object.Blob, error)
+
+	// TagsForTarget returns the annotated tags pointing to the hash.
+	TagsForTarget(hash plumbing.Hash) []*object.Tag
+
+	CommitHashes() []plumbing.Hash
+	TagHashes() []plumbing.Hash
+	// BlobHashes might need to read the object storage, unlike the other hashes that are indexed up front.
+	BlobHashes() ([]plumbing.Hash, error)
+}
+
+// RepoState holds every object of the repository in memory.
+type RepoState struct {
+	BlobMap        map[plumbing.Hash]*object.Blob
+	TreeMap        map[plumbing.Hash]*object.Tree
+	CommitMap      map[plumbing.Hash]*object.Commit
+	TagMap         map[plumbing.Hash]*object.Tag
+	TargetToTagMap map[plumbing.Hash][]*object.Tag
+}
+
+func newRepoState() *RepoState {
+	blobMap := make(map[plumbing.Hash]*object.Blob)
+	treeMap := make(map[plumbing.Hash]*object.Tree)
+	commitMap := make(map[plumbing.Hash]*object.Commit)
+	tagMap := make(map[plumbing.Hash]*object.Tag)
+	targetToTagMap := make(map[plumbing.Hash][]*object.Tag)
+
+	return &RepoState{
+		BlobMap:        blobMap,
+		TreeMap:        treeMap,
+		CommitMap:      commitMap,
+		TagMap:         tagMap,
+		TargetToTagMap: targetToTagMap,
+	}
+}
+
+// LoadRepoState decodes every object in the repository. Use NewLazyRepoState for large repositories.
+func LoadRepoState(repo *git.Repository) (*RepoState, error) {
+	iter, err := repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil, err
+	}
+
+	repoState := newRepoState()
+
+	processedObject := hashset.New[plumbing.Hash]()
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		if processedObject.Contains(obj.Hash()) {
+			slog.Debug("skipping object", "type", obj.Type(), "hash", obj.Hash().String())
+			return nil
+		}
+
+		switch obj.Type() {
+		case plumbing.BlobObject:
+			// process and store result rather than data
+			blob := &object.Blob{}
+			err := blob.Decode(obj)
+			if err != nil {
+				return fmt.Errorf("failed to decode blob %s: %w", obj.Hash(), err)
+			}
+			repoState.BlobMap[obj.Hash()] = blob
+		case plumbing.TreeObject:
+			tree := &object.Tree{}
+			err := tree.Decode(obj)
+			if err != nil {
+				return fmt.Errorf("failed to decode tree %s: %w", obj.Hash(), err)
+			}
+			repoState.TreeMap[obj.Hash()] = tree
+		case plumbing.CommitObject:
+			commit := &object.Commit{}
+			err := commit.Decode(obj)
+			if err != nil {
+				return fmt.Errorf("failed to decode commit %s: %w", obj.Hash(), err)
+			}
+			repoState.CommitMap[obj.Hash()] = commit
+		case plumbing.TagObject:
+			tag := &object.Tag{}
+			err := tag.Decode(obj)
+			if err != nil {
+				return fmt.Errorf("failed to decode tag %s: %w", obj.Hash(), err)
+			}
+			repoState.TagMap[obj.Hash()] = tag
+		default:
+			return fmt.Errorf("unknown object type %s for %s", obj.Type(), obj.Hash())
+		}
+
+		slog.Debug("Processed object", "type", obj.Type(), "hash", obj.Hash())
+		processedObject.Add(obj.Hash())
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range repoState.TagMap {
+		repoState.TargetToTagMap[v.Target] = append(repoState.TargetToTagMap[v.Target], v)
+	}
+
+	return repoState, nil
+}
+
+func (rs *RepoState) Commit(hash plumbing.Hash) (*object.Commit, error) {
+	return lookup(rs.CommitMap, hash)
+}
+
+func (rs *RepoState) Tag(hash plumbing.Hash) (*object.Tag, error) {
+	return lookup(rs.TagMap, hash)
+}
+
+func (rs *RepoState) Tree(hash plumbing.Hash) (*object.Tree, error) {
+	return lookup(rs.TreeMap, hash)
+}
+
+func (rs *RepoState) Blob(hash plumbing.Hash) (*object.Blob, error) {
+	return lookup(rs.BlobMap, hash)
+}
+
+func (rs *RepoState) TagsForTarget(hash plumbing.Hash) []*object.Tag {
+	return rs.TargetToTagMap[hash]
+}
+
+func (rs *RepoState) CommitHashes() []plumbing.Hash {
+	return keys(rs.CommitMap)
+}
+
+func (rs *RepoState) TagHashes() []plumbing.Hash {
+	return keys(rs.TagMap)
+}
+
+func (rs *RepoState) BlobHashes() ([]plumbing.Hash, error) {
+	return keys(rs.BlobMap), nil
+}
+
+func lookup[T any](m map[plumbing.Hash]*T, hash plumbing.Hash) (*T, error) {
+	v, found := m[hash]
+	if !found {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return v, nil
+}
+
+func keys[T any](m map[plumbing.Hash]T) []plumbing.Hash {
+	result := make([]plumbing.Hash, 0, len(m))
+	for hash := range m {
+		result = append(result, hash)
+	}
+
+	return result
+}
+
+// LazyRepoState decodes objects on demand from the object storage of the repository. Only the commit
+// hashes and the annotated tags are indexed up front, other decoded objects are kept in a bounded
+// cache, so memory use does not grow with the size of the trees and blobs in the repository.
+type LazyRepoState struct {
+	storer         storer.EncodedObjectStorer
+	commits        hashset.Set[plumbing.Hash]
+	tags           map[plumbing.Hash]*object.Tag
+	targetToTagMap map[plumbing.Hash][]*object.Tag
+	cache          *objectCache
+}
+
+// NewLazyRepoState indexes the commits and tags of the repository. cacheSize is the maximum number of
+// decoded commits and trees that are kept in memory, DefaultObjectCacheSize is used if it is not positive.
+func NewLazyRepoState(repo *git.Repository, cacheSize int) (*LazyRepoState, error) {
+	rs := newLazyRepoState(repo, cacheSize)
+
+	iter, err := rs.storer.IterEncodedObjects(plumbing.CommitObject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		rs.commits.Add(obj.Hash())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err = rs.storer.IterEncodedObjects(plumbing.TagObject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = iter.ForEach(rs.addTag)
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// NewLazyRepoStateFromIndex is like NewLazyRepoState, but takes the commit hashes and tags from the
+// index rather than scanning the object storage.
+func NewLazyRepoStateFromIndex(repo *git.Repository, index *ObjectIndex, cacheSize int) (*LazyRepoState, error) {
+	rs := newLazyRepoState(repo, cacheSize)
+
+	for _, hash := range index.CommitHashes() {
+		rs.commits.Add(hash)
+	}
+
+	for _, hash := range index.TagHashes() {
+		obj, err := rs.storer.EncodedObject(plumbing.TagObject, hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read indexed tag %s: %w", hash, err)
+		}
+
+		err = rs.addTag(obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rs, nil
+}
+
+func newLazyRepoState(repo *git.Repository, cacheSize int) *LazyRepoState {
+	if cacheSize <= 0 {
+		cacheSize = DefaultObjectCacheSize
+	}
+
+	return &LazyRepoState{
+		storer:         repo.Storer,
+		commits:        hashset.New[plumbing.Hash](),
+		tags:           make(map[plumbing.Hash]*object.Tag),
+		targetToTagMap: make(map[plumbing.Hash][]*object.Tag),
+		cache:          newObjectCache(cacheSize),
+	}
+}
+
+func (rs *LazyRepoState) addTag(obj plumbing.EncodedObject) error {
+	if _, found := rs.tags[obj.Hash()]; found {
+		return nil
+	}
+
+	tag := &object.Tag{}
+	err := tag.Decode(obj)
+	if err != nil {
+		return fmt.Errorf("failed to decode tag %s: %w", obj.Hash(), err)
+	}
+
+	rs.tags[obj.Hash()] = tag
+	rs.targetToTagMap[tag.Target] = append(rs.targetToTagMap[tag.Target], tag)
+	return nil
+}
+
+func (rs *LazyRepoState) Commit(hash plumbing.Hash) (*object.Commit, error) {
+	if !rs.commits.Contains(hash) {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	cached, found := rs.cache.get(hash)
+	if found {
+		return cached.(*object.Commit), nil
+	}
+
+	obj, err := rs.storer.EncodedObject(plumbing.CommitObject, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	commit := &object.Commit{}
+	err = commit.Decode(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode commit %s: %w", hash, err)
+	}
+
+	rs.cache.add(hash, commit)
+	return commit, nil
+}
+
+func (rs *LazyRepoState) Tag(hash plumbing.Hash) (*object.Tag, error) {
+	return lookup(rs.tags, hash)
+}
+
+func (rs *LazyRepoState) Tree(hash plumbing.Hash) (*object.Tree, error) {
+	cached, found := rs.cache.get(hash)
+	if found {
+		tree, isTree := cached.(*object.Tree)
+		if !isTree {
+			return nil, plumbing.ErrObjectNotFound
+		}
+		return tree, nil
+	}
+
+	obj, err := rs.storer.EncodedObject(plumbing.TreeObject, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &object.Tree{}
+	err = tree.Decode(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tree %s: %w", hash, err)
+	}
+
+	rs.cache.add(hash, tree)
+	return tree, nil
+}
+
+// Blob is not cached, since the content of the blob is typically only read once.
+func (rs *LazyRepoState) Blob(hash plumbing.Hash) (*object.Blob, error) {
+	obj, err := rs.storer.EncodedObject(plumbing.BlobObject, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := &object.Blob{}
+	err = blob.Decode(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode blob %s: %w", hash, err)
+	}
+
+	return blob, nil
+}
+
+func (rs *LazyRepoState) TagsForTarget(hash plumbing.Hash) []*object.Tag {
+	return rs.targetToTagMap[hash]
+}
+
+func (rs *LazyRepoState) CommitHashes() []plumbing.Hash {
+	return rs.commits.Values()
+}
+
+func (rs *LazyRepoState) TagHashes() []plumbing.Hash {
+	return keys(rs.tags)
+}
+
+func (rs *LazyRepoState) BlobHashes() ([]plumbing.Hash, error) {
+	iter, err := rs.storer.IterEncodedObjects(plumbing.BlobObject)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := hashset.New[plumbing.Hash]()
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		seen.Add(obj.Hash())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return seen.Values(), nil
+}
+
+// objectCache is a least recently used cache of decoded objects.
+type objectCache struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[plumbing.Hash]*list.Element
+}
+
+type cacheEntry struct {
+	hash  plumbing.Hash
+	value any
+}
+
+func newObjectCache(capacity int) *objectCache {
+	return &objectCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[plumbing.Hash]*list.Element),
+	}
+}
+
+func (c *objectCache) get(hash plumbing.Hash) (any, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, found := c.entries[hash]
+	if !found {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).value, true
+}
+
+func (c *objectCache) add(hash plumbing.Hash, value any) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, found := c.entries[hash]
+	if found {
+		element.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[hash] = c.order.PushFront(&cacheEntry{hash: hash, value: value})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).hash)
+	}
+}
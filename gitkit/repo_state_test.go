@@ -0,0 +1,151 @@
+package gitkit
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestLazyRepoState(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := &object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Unix(1700000000, 0)}
+	commits := make([]plumbing.Hash, 0)
+	for _, content := range []string{"first", "second"} {
+		f, err := worktree.Filesystem.Create("file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = worktree.Add("file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hash, err := worktree.Commit(content, &git.CommitOptions{Author: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, hash)
+	}
+
+	tagRef, err := repo.CreateTag("v1", commits[1], &git.CreateTagOptions{Tagger: signature, Message: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eager, err := LoadRepoState(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := NewLazyRepoState(repo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range []RepoObjects{eager, lazy} {
+		if !equalHashes(state.CommitHashes(), commits) {
+			t.Errorf("got commits %v, want %v", state.CommitHashes(), commits)
+		}
+
+		if !equalHashes(state.TagHashes(), []plumbing.Hash{tagRef.Hash()}) {
+			t.Errorf("got tags %v, want %v", state.TagHashes(), tagRef.Hash())
+		}
+
+		blobs, err := state.BlobHashes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(blobs) != 2 {
+			t.Errorf("got %d blobs, want 2", len(blobs))
+		}
+
+		tags := state.TagsForTarget(commits[1])
+		if len(tags) != 1 || tags[0].Name != "v1" {
+			t.Errorf("got tags %v for target, want v1", tags)
+		}
+
+		for _, hash := range commits {
+			commit, err := state.Commit(hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tree, err := state.Tree(commit.TreeHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			blob, err := state.Blob(tree.Entries[0].Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if blob.Size != int64(len(commit.Message)) {
+				t.Errorf("got blob size %d for commit '%s'", blob.Size, commit.Message)
+			}
+
+			_, err = state.Tree(hash)
+			if !errors.Is(err, plumbing.ErrObjectNotFound) {
+				t.Errorf("expected commit %s not to be found as a tree, got %v", hash, err)
+			}
+
+			_, err = state.Commit(commit.TreeHash)
+			if !errors.Is(err, plumbing.ErrObjectNotFound) {
+				t.Errorf("expected tree %s not to be found as a commit, got %v", commit.TreeHash, err)
+			}
+		}
+	}
+
+	if lazy.cache.order.Len() != 1 {
+		t.Errorf("expected the cache to be bounded to 1 object, got %d", lazy.cache.order.Len())
+	}
+}
+
+func equalHashes(a []plumbing.Hash, b []plumbing.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortHashes := func(hashes []plumbing.Hash) []string {
+		result := make([]string, 0, len(hashes))
+		for _, hash := range hashes {
+			result = append(result, hash.String())
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	sa := sortHashes(a)
+	sb := sortHashes(b)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,165 @@
+package gitkit
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// submodules resolves the gitlink entries of a repository to local clones of the submodules, so that the pinned
+// commits can be searched. A submodule is searched in its checkout in the worktree, or in '.git/modules/<name>'
+// if it is not checked out. Submodules without a local clone, or where the pinned commit has not been fetched,
+// are skipped.
+type submodules[T SearchResult] struct {
+	gitDir   string
+	workTree string
+	// prefix is the path of the repository in the top-level repository, empty for the top level
+	prefix string
+	// names maps submodule paths to names from .gitmodules, the name is the path if it is not listed
+	names  map[string]string
+	states map[string]*submoduleState[T]
+	parent *ProcessedState[T]
+}
+
+type submoduleState[T SearchResult] struct {
+	repoState      *RepoState
+	processedState *ProcessedState[T]
+}
+
+func newSubmodules[T SearchResult](repo *git.Repository, prefix string, parent *ProcessedState[T]) *submodules[T] {
+	s := &submodules[T]{
+		prefix: prefix,
+		names:  make(map[string]string),
+		states: make(map[string]*submoduleState[T]),
+		parent: parent,
+	}
+
+	s.gitDir = gitDir(repo)
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		// bare repositories don't have submodule checkouts, and usually not '.git/modules' either
+		return s
+	}
+	s.workTree = worktree.Filesystem.Root()
+
+	modules, err := worktree.Submodules()
+	if err != nil {
+		slog.Debug("unable to read .gitmodules", "path", s.workTree, "error", err)
+		return s
+	}
+
+	for _, module := range modules {
+		s.names[module.Config().Path] = module.Config().Name
+	}
+
+	return s
+}
+
+// process searches the tree of the commit pinned by the gitlink at path, which includes the prefix. The results
+// have paths prefixed with the path of the submodule.
+func (s *submodules[T]) process(path string, commitHash plumbing.Hash, searcher Searcher[T]) []*TreeSearchResult[T] {
+	state := s.state(path)
+	if state == nil {
+		return nil
+	}
+
+	commit, found := state.repoState.CommitMap[commitHash]
+	if !found {
+		slog.Debug("submodule commit not fetched (skipping)", "path", path, "commit", commitHash)
+		return nil
+	}
+
+	return processTreeRecursively(commit, state.repoState, state.processedState, searcher, commit.TreeHash, path+"/",
+		state.processedState.filter)
+}
+
+// state opens the submodule at path the first time it is seen, nil is returned if there is no local clone.
+func (s *submodules[T]) state(path string) *submoduleState[T] {
+	state, found := s.states[path]
+	if found {
+		return state
+	}
+	s.states[path] = nil
+
+	relativePath := strings.TrimPrefix(path, s.prefix)
+	name, found := s.names[relativePath]
+	if !found {
+		name = relativePath
+	}
+
+	candidates := make([]string, 0)
+	if s.workTree != "" {
+		candidates = append(candidates, filepath.Join(s.workTree, filepath.FromSlash(relativePath)))
+	}
+	if s.gitDir != "" {
+		candidates = append(candidates, filepath.Join(s.gitDir, "modules", filepath.FromSlash(name)))
+	}
+
+	for _, candidate := range candidates {
+		repo, err := git.PlainOpen(candidate)
+		if err != nil {
+			continue
+		}
+
+		repoState, err := LoadRepoState(repo)
+		if err != nil {
+			slog.Debug("unable to load submodule (skipping)", "path", candidate, "error", err)
+			return nil
+		}
+
+		// the results are shared with the top-level repository, since the paths are prefixed, but trees are
+		// cached per submodule as the same tree gives different paths in different repositories
+		processedState := NewProcessedState[T]()
+		processedState.results = s.parent.results
+		processedState.submodules = newSubmodules(repo, path+"/", processedState)
+		processedState.prefix = path + "/"
+		processedState.blobCache = s.parent.blobCache
+		if s.parent.filter != nil {
+			processedState.filter = newAttributesFilter()
+		}
+
+		state = &submoduleState[T]{
+			repoState:      repoState,
+			processedState: processedState,
+		}
+		s.states[path] = state
+		return state
+	}
+
+	slog.Debug("submodule not cloned (skipping)", "path", path)
+	return nil
+}
+
+// stats returns the stats of all the submodules, including nested ones.
+func (s *submodules[T]) stats() Stats {
+	stats := Stats{}
+	for _, state := range s.states {
+		if state == nil {
+			continue
+		}
+
+		stats.add(state.processedState.stats())
+	}
+	return stats
+}
+
+func (ps *ProcessedState[T]) stats() Stats {
+	stats := Stats{
+		queryTime:    ps.queryTime,
+		dataLoadTime: ps.dataLoadTime,
+		numFiles:     ps.numFiles,
+		size:         ps.size,
+		skippedSize:  ps.skippedSize,
+		skippedFiles: ps.skippedFiles,
+	}
+
+	if ps.submodules != nil {
+		stats.add(ps.submodules.stats())
+	}
+
+	return stats
+}
@@ -13,6 +13,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -27,6 +28,8 @@ type RepoResult[T SearchResult] struct {
 	Matches         map[T][]BranchResult
 	DanglingCommits map[T]*object.Commit
 	Tags            map[T][]*object.Tag
+	// Commits is only set in ModeDiff and ModeDiffWithRemoved.
+	Commits map[T][]DiffResult
 }
 
 type TreeSearchResult[T SearchResult] struct {
@@ -82,6 +85,8 @@ type Stats struct {
 	size             uint64
 	numFiles         uint64
 	numberOfBranches uint64
+	skippedSize      uint64
+	skippedFiles     uint64
 }
 
 func (s *Stats) ListFilesTime() int64 {
@@ -108,28 +113,108 @@ func (s *Stats) NumberOfBranches() uint64 {
 	return s.numFiles
 }
 
+// SkippedSize is the size of the blobs that were not searched, since the results were already shared by a worker
+// of Search.
+func (s *Stats) SkippedSize() uint64 {
+	return s.skippedSize
+}
+
+func (s *Stats) SkippedFiles() uint64 {
+	return s.skippedFiles
+}
+
+func (s *Stats) add(other Stats) {
+	s.listFilesTime += other.listFilesTime
+	s.queryTime += other.queryTime
+	s.dataLoadTime += other.dataLoadTime
+	s.size += other.size
+	s.numFiles += other.numFiles
+	s.numberOfBranches += other.numberOfBranches
+	s.skippedSize += other.skippedSize
+	s.skippedFiles += other.skippedFiles
+}
+
+// ProcessAllCommits searches the history of the repository according to mode, and the metadata if enabled in
+// opts, which can be nil.
 func ProcessAllCommits[T SearchResult](path string,
 	repo *git.Repository,
 	searcher Searcher[T],
-	mode Mode) ([]RepoResult[T], Stats, error) {
+	mode Mode,
+	opts *SearchOptions) ([]RepoResult[T], Stats, error) {
+
+	return processAllCommits(path, repo, searcher, mode, opts, nil)
+}
+
+// processAllCommits shares the results of blobs with other repositories through cache, if it is not nil.
+func processAllCommits[T SearchResult](path string,
+	repo *git.Repository,
+	searcher Searcher[T],
+	mode Mode,
+	opts *SearchOptions,
+	cache *blobCache[T]) ([]RepoResult[T], Stats, error) {
+
+	commitFilter, err := newCommitFilter(repo, opts)
+	if err != nil {
+		return nil, Stats{}, fmt.Errorf("failed to filter commits in '%s': %w", path, err)
+	}
+
+	var results []RepoResult[T]
+	var stats Stats
+	if mode == ModeDiff || mode == ModeDiffWithRemoved {
+		results, stats, err = processAllDiffs(path, repo, searcher, mode == ModeDiffWithRemoved, opts != nil && opts.SkipIgnored, commitFilter)
+	} else {
+		results, stats, err = processAllTrees(path, repo, searcher, mode, opts != nil && opts.Submodules, opts != nil && opts.SkipIgnored, cache, commitFilter)
+	}
+	if err != nil {
+		return nil, Stats{}, err
+	}
+
+	if opts != nil && opts.Metadata {
+		metadataResults, metadataStats, err := processMetadata(path, repo, searcher, commitFilter)
+		if err != nil {
+			return nil, Stats{}, err
+		}
+
+		results = append(results, metadataResults...)
+		stats.add(metadataStats)
+	}
+
+	return results, stats, nil
+}
+
+func processAllTrees[T SearchResult](path string,
+	repo *git.Repository,
+	searcher Searcher[T],
+	mode Mode,
+	searchSubmodules bool,
+	skipIgnored bool,
+	cache *blobCache[T],
+	commitFilter *commitFilter) ([]RepoResult[T], Stats, error) {
 
 	start := time.Now()
-	repoState := LoadRepoState(repo)
+	repoState, err := LoadRepoState(repo)
+	if err != nil {
+		return nil, Stats{}, err
+	}
 	listFilesTime := time.Since(start).Nanoseconds()
 	var numberOfBranches uint64 = 0
 
-	remotes, err := ListRemoteReferences(repo)
+	tips, err := commitFilter.tips(repo)
 	if err != nil {
 		return nil, Stats{}, err
 	}
 
 	processedState := NewProcessedState[T]()
+	processedState.blobCache = cache
+	processedState.commitFilter = commitFilter
+	if skipIgnored {
+		processedState.filter = newAttributesFilter()
+	}
+	if searchSubmodules {
+		processedState.submodules = newSubmodules(repo, "", processedState)
+	}
 
-	for _, remote := range remotes {
-		if remote.Name() == "refs/remotes/origin/HEAD" {
-			continue
-		}
-
+	for _, remote := range tips {
 		numberOfBranches++
 		branchName := remote.Name().String()
 		commitHash := remote.Hash()
@@ -153,7 +238,8 @@ func ProcessAllCommits[T SearchResult](path string,
 		}
 	}
 
-	if mode == ModeAllHistory {
+	// dangling commits are not reachable from the refs that are searched
+	if mode == ModeAllHistory && !commitFilter.limitsRefs() {
 		danglingCommits := make([]*plumbing.Hash, 0)
 		for commitHash := range repoState.CommitMap {
 			_, found := processedState.commitResults[commitHash]
@@ -189,11 +275,7 @@ func ProcessAllCommits[T SearchResult](path string,
 		for _, resultMap := range matchMap {
 			for _, result := range resultMap {
 				br := make([]BranchResult, 0)
-				for _, remote := range remotes {
-					if remote.Name() == "refs/remotes/origin/HEAD" {
-						continue
-					}
-
+				for _, remote := range tips {
 					head := remote.Hash().String()
 
 					branchName := remote.Name().String()
@@ -236,14 +318,11 @@ func ProcessAllCommits[T SearchResult](path string,
 		})
 	}
 
-	return repoResults, Stats{
-		queryTime:        processedState.queryTime,
-		dataLoadTime:     processedState.dataLoadTime,
-		listFilesTime:    listFilesTime,
-		numFiles:         processedState.numFiles,
-		size:             processedState.size,
-		numberOfBranches: numberOfBranches,
-	}, nil
+	stats := processedState.stats()
+	stats.listFilesTime = listFilesTime
+	stats.numberOfBranches = numberOfBranches
+
+	return repoResults, stats, nil
 }
 
 func processCommitRecursively[T SearchResult](path string, commitHash *plumbing.Hash,
@@ -264,6 +343,11 @@ func processCommitRecursively[T SearchResult](path string, commitHash *plumbing.
 		log.Fatalf("did not find commit '%s' for repo '%s'", hash, path)
 	}
 
+	if processedState.commitFilter != nil && processedState.commitFilter.prune(commit) {
+		processedState.commitResults[hash] = make([]*WrappedSearchResult[T], 0)
+		return
+	}
+
 	if recurse {
 		for _, parent := range commit.ParentHashes {
 			processCommitRecursively(path, &parent, repoState, processedState, searcher, branchName, recurse)
@@ -281,7 +365,11 @@ func processCommit[T SearchResult](commit *object.Commit,
 	searcher Searcher[T],
 	recurse bool) {
 
-	results := processTreeRecursively(commit, repoState, processedState, searcher, commit.TreeHash, "")
+	// a skipped commit still passes on the results of its parents
+	results := make([]*TreeSearchResult[T], 0)
+	if processedState.commitFilter == nil || !processedState.commitFilter.skip(commit) {
+		results = processTreeRecursively(commit, repoState, processedState, searcher, commit.TreeHash, "", processedState.filter)
+	}
 
 	commitResultsMap := make(map[*TreeSearchResult[T]]*WrappedSearchResult[T])
 
@@ -355,9 +443,16 @@ func processTreeRecursively[T SearchResult](commit *object.Commit,
 	processedState *ProcessedState[T],
 	searcher Searcher[T],
 	treeHash plumbing.Hash,
-	path string) []*TreeSearchResult[T] {
+	path string,
+	filter *fileFilter) []*TreeSearchResult[T] {
+
+	// with a filter the same tree can have different results depending on its path and the .gitattributes above it
+	key := treeKey{hash: treeHash}
+	if filter != nil {
+		key.filter = filter.key + "@" + path
+	}
 
-	existingTreeResults, found := processedState.treeResults[treeHash]
+	existingTreeResults, found := processedState.treeResults[key]
 	if found {
 		return existingTreeResults
 	}
@@ -367,18 +462,35 @@ func processTreeRecursively[T SearchResult](commit *object.Commit,
 		log.Fatalf("did not find tree hash '%s'", treeHash)
 	}
 
+	// the patterns are relative to the root of the repository, which is not the top level for submodules
+	relativePath := strings.TrimPrefix(path, processedState.prefix)
+	if filter != nil {
+		filter = filter.withTree(repoState, tree, pathComponents(relativePath))
+	}
+
+	skip := func(entry object.TreeEntry, isDir bool) bool {
+		return filter != nil && filter.skip(pathComponents(relativePath+entry.Name), isDir)
+	}
+
 	treeResults := hashset.New[*TreeSearchResult[T]]()
 	for _, entry := range tree.Entries {
-		if entry.Mode == filemode.Dir {
-			recursiveResults := processTreeRecursively(commit, repoState, processedState, searcher, entry.Hash, path+entry.Name+"/")
+		if entry.Mode == filemode.Dir && !skip(entry, true) {
+			recursiveResults := processTreeRecursively(commit, repoState, processedState, searcher, entry.Hash, path+entry.Name+"/", filter)
 			for _, recursiveResult := range recursiveResults {
 				treeResults.Add(recursiveResult)
 			}
 		}
+
+		if entry.Mode == filemode.Submodule && processedState.submodules != nil && !skip(entry, true) {
+			for _, submoduleResult := range processedState.submodules.process(path+entry.Name, entry.Hash, searcher) {
+				treeResults.Add(submoduleResult)
+			}
+		}
 	}
 
 	for _, entry := range tree.Entries {
-		if entry.Mode == filemode.Regular || entry.Mode == filemode.Executable || entry.Mode == filemode.Deprecated {
+		isFile := entry.Mode == filemode.Regular || entry.Mode == filemode.Executable || entry.Mode == filemode.Deprecated
+		if isFile && !skip(entry, false) {
 			currentPath := path + entry.Name
 
 			var dataTime int64 = 0
@@ -407,8 +519,14 @@ func processTreeRecursively[T SearchResult](commit *object.Commit,
 				return bytes
 			}
 
+			var size int64 = 0
+			blob, blobFound := repoState.BlobMap[entry.Hash]
+			if blobFound {
+				size = blob.Size
+			}
+
 			start := time.Now()
-			results := searcher.Process(&entry.Hash, dataLoader, currentPath)
+			results := processedState.searchBlob(searcher, entry.Hash, size, dataLoader, currentPath)
 			elapsed := time.Since(start).Nanoseconds()
 			processedState.queryTime += elapsed - dataTime
 
@@ -445,125 +563,48 @@ func processTreeRecursively[T SearchResult](commit *object.Commit,
 		results = append(results, treeResult)
 	}
 
-	processedState.treeResults[treeHash] = results
+	processedState.treeResults[key] = results
 
 	return results
 }
 
-func LoadRepoState(repo *git.Repository) *RepoState {
-	iter, err := repo.Storer.IterEncodedObjects(plumbing.AnyObject)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	repoState := newRepoState()
-
-	processedObject := hashset.New[plumbing.Hash]()
-	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
-		if processedObject.Contains(obj.Hash()) {
-			slog.Debug("skipping object", "type", obj.Type(), "hash", obj.Hash().String())
-			return nil
-		}
-
-		obj.Hash()
-		switch obj.Type() {
-		case plumbing.BlobObject:
-			// process and store result rather than data
-			blob := &object.Blob{}
-			err := blob.Decode(obj)
-			if err != nil {
-				log.Fatal(err)
-			}
-			repoState.BlobMap[obj.Hash()] = blob
-		case plumbing.TreeObject:
-			tree := &object.Tree{}
-			err := tree.Decode(obj)
-			if err != nil {
-				log.Fatal(err)
-			}
-			repoState.TreeMap[obj.Hash()] = tree
-		case plumbing.CommitObject:
-			commit := &object.Commit{}
-			err := commit.Decode(obj)
-			if err != nil {
-				log.Fatal(err)
-			}
-			repoState.CommitMap[obj.Hash()] = commit
-		case plumbing.TagObject:
-			tag := &object.Tag{}
-			err := tag.Decode(obj)
-			if err != nil {
-				log.Fatal(err)
-			}
-			repoState.TagMap[obj.Hash()] = tag
-		default:
-			log.Fatal("unknown object type")
-		}
-
-		slog.Debug("Processed object", "type", obj.Type(), "hash", obj.Hash())
-		processedObject.Add(obj.Hash())
-
-		return nil
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	for _, v := range repoState.TagMap {
-		existing, found := repoState.TargetToTagMap[v.Target]
-		if found {
-			repoState.TargetToTagMap[v.Target] = append(existing, v)
-		} else {
-			repoState.TargetToTagMap[v.Target] = []*object.Tag{v}
-		}
-	}
-
-	return repoState
-}
-
-type RepoState struct {
-	BlobMap        map[plumbing.Hash]*object.Blob
-	TreeMap        map[plumbing.Hash]*object.Tree
-	CommitMap      map[plumbing.Hash]*object.Commit
-	TagMap         map[plumbing.Hash]*object.Tag
-	TargetToTagMap map[plumbing.Hash][]*object.Tag
-}
-
-func newRepoState() *RepoState {
-	blobMap := make(map[plumbing.Hash]*object.Blob)
-	treeMap := make(map[plumbing.Hash]*object.Tree)
-	commitMap := make(map[plumbing.Hash]*object.Commit)
-	tagMap := make(map[plumbing.Hash]*object.Tag)
-	targetToTagMap := make(map[plumbing.Hash][]*object.Tag)
-
-	return &RepoState{
-		BlobMap:        blobMap,
-		TreeMap:        treeMap,
-		CommitMap:      commitMap,
-		TagMap:         tagMap,
-		TargetToTagMap: targetToTagMap,
-	}
+// treeKey identifies the results of a tree, filter is empty unless ignored files are skipped.
+type treeKey struct {
+	hash   plumbing.Hash
+	filter string
 }
 
 type ProcessedState[T SearchResult] struct {
 	// path, match, searchTerm
 	results map[string]map[string]map[string]*TreeSearchResult[T]
 
-	treeResults map[plumbing.Hash][]*TreeSearchResult[T]
+	treeResults map[treeKey][]*TreeSearchResult[T]
 
 	// branch name
 	branchResults map[string]map[*TreeSearchResult[T]]*WrappedSearchResult[T]
 	commitResults map[plumbing.Hash][]*WrappedSearchResult[T]
 	tagResults    map[*TreeSearchResult[T]][]*object.Tag
-	size          uint64
-	numFiles      uint64
-	dataLoadTime  int64
-	queryTime     int64
+	// submodules is nil unless submodules are searched
+	submodules *submodules[T]
+	// filter is the filter of the root tree, nil unless ignored files are skipped
+	filter *fileFilter
+	// prefix is the path of the repository in the top-level repository, empty for the top level
+	prefix string
+	// commitFilter is nil if all commits are searched
+	commitFilter *commitFilter
+	// blobCache is shared with the other workers of Search, nil otherwise
+	blobCache    *blobCache[T]
+	skippedSize  uint64
+	skippedFiles uint64
+	size         uint64
+	numFiles     uint64
+	dataLoadTime int64
+	queryTime    int64
 }
 
 func NewProcessedState[T SearchResult]() *ProcessedState[T] {
 	results := make(map[string]map[string]map[string]*TreeSearchResult[T])
-	treeResults := make(map[plumbing.Hash][]*TreeSearchResult[T])
+	treeResults := make(map[treeKey][]*TreeSearchResult[T])
 	branchResults := make(map[string]map[*TreeSearchResult[T]]*WrappedSearchResult[T])
 	commitResults := make(map[plumbing.Hash][]*WrappedSearchResult[T])
 	tagResults := make(map[*TreeSearchResult[T]][]*object.Tag)
@@ -581,7 +622,8 @@ func NewProcessedState[T SearchResult]() *ProcessedState[T] {
 	}
 }
 
-func ProcessAllFiles[T SearchResult](searcher Searcher[T], repo Repository) ([]RepoResult[T], Stats, error) {
+// ProcessAllFiles searches the files in the worktree, opts can be nil.
+func ProcessAllFiles[T SearchResult](searcher Searcher[T], repo Repository, opts *SearchOptions) ([]RepoResult[T], Stats, error) {
 	repoPath := repo.LocalRootPath()
 	if !strings.HasSuffix(repoPath, "/") {
 		repoPath += "/"
@@ -593,7 +635,7 @@ func ProcessAllFiles[T SearchResult](searcher Searcher[T], repo Repository) ([]R
 	}
 
 	start := time.Now()
-	files, err := listAllFilesInLocalPath(searchPath)
+	files, err := listAllFilesInLocalPath(searchPath, repoPath, opts != nil && opts.FollowSymlinks, opts != nil && opts.SkipIgnored)
 	if err != nil {
 		return nil, Stats{}, err
 	}
@@ -649,9 +691,42 @@ func ProcessAllFiles[T SearchResult](searcher Searcher[T], repo Repository) ([]R
 	}, nil
 }
 
-func listAllFilesInLocalPath(path string) ([]string, error) {
-	paths := make([]string, 0)
-	err := filepath.WalkDir(path, func(path string, info fs.DirEntry, err error) error {
+// listAllFilesInLocalPath skips symlinks unless followSymlinks is set, then the files and directories they point to
+// are listed under the path of the symlink if they are inside root. Ignored files are skipped if skipIgnored is set.
+func listAllFilesInLocalPath(path string, root string, followSymlinks bool, skipIgnored bool) ([]string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve path '%s': %w", root, err)
+	}
+
+	walker := &fileWalker{
+		realRoot:       realRoot,
+		followSymlinks: followSymlinks,
+		paths:          make([]string, 0),
+	}
+	if skipIgnored {
+		walker.filters = newLocalFileFilters(root)
+	}
+
+	err = walker.walk(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get all local files from path '%s': %w", path, err)
+	}
+
+	return walker.paths, nil
+}
+
+type fileWalker struct {
+	realRoot       string
+	followSymlinks bool
+	// filters is nil unless ignored files are skipped
+	filters *localFileFilters
+	paths   []string
+}
+
+// walk keeps the resolved directories of the symlinks being walked in visiting, to not follow cycles.
+func (fw *fileWalker) walk(root string, visiting []string) error {
+	return filepath.WalkDir(root, func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -660,15 +735,70 @@ func listAllFilesInLocalPath(path string) ([]string, error) {
 			return filepath.SkipDir
 		}
 
-		if !info.IsDir() && info.Type() != os.ModeSymlink {
-			paths = append(paths, path)
+		if info.Type() == os.ModeSymlink {
+			if !fw.followSymlinks {
+				return nil
+			}
+
+			return fw.walkSymlink(path, visiting)
+		}
+
+		if path != root && fw.skip(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.IsDir() {
+			fw.paths = append(fw.paths, path)
 		}
 
 		return nil
 	})
+}
+
+func (fw *fileWalker) walkSymlink(path string, visiting []string) error {
+	target, err := filepath.EvalSymlinks(path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get all local files from path '%s': %w", path, err)
+		slog.Debug("Skipping broken symlink", "path", path, "error", err)
+		return nil
+	}
+
+	if target != fw.realRoot && !strings.HasPrefix(target, fw.realRoot+string(filepath.Separator)) {
+		slog.Debug("Skipping symlink outside of the repo", "path", path, "target", target)
+		return nil
 	}
 
-	return paths, nil
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	if fw.skip(path, info.IsDir()) {
+		return nil
+	}
+
+	if !info.IsDir() {
+		fw.paths = append(fw.paths, path)
+		return nil
+	}
+
+	// a symlink to one of its parent directories is a cycle as well
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(visiting, target) || realParent == target || strings.HasPrefix(realParent, target+string(filepath.Separator)) {
+		slog.Debug("Skipping symlink cycle", "path", path, "target", target)
+		return nil
+	}
+
+	// the symlink is walked as a root, so that the paths are under the path of the symlink
+	return fw.walk(path+string(filepath.Separator), append(visiting, target))
+}
+
+func (fw *fileWalker) skip(path string, isDir bool) bool {
+	return fw.filters != nil && fw.filters.skip(path, isDir)
 }
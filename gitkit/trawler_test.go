@@ -0,0 +1,251 @@
+package gitkit
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestProcessAllFilesFollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "repo")
+	outside := filepath.Join(dir, "outside.txt")
+
+	writeFile(t, filepath.Join(root, "a.txt"), "secret a\n")
+	writeFile(t, filepath.Join(root, "dir", "b.txt"), "secret b\n")
+	writeFile(t, outside, "secret outside\n")
+
+	for link, target := range map[string]string{
+		"link-a.txt":   "a.txt",
+		"link-dir":     "dir",
+		"dir/loop":     "..",
+		"outside.txt":  outside,
+		"broken.txt":   "missing.txt",
+		"dir/self-dir": ".",
+	} {
+		err := os.Symlink(target, filepath.Join(root, link))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := NewRepo("", "repo", root, nil)
+
+	results, _, err := ProcessAllFiles[lineResult](&lineSearcher{}, repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a.txt", "dir/b.txt"}
+	if paths := resultPaths(results); !equalStrings(paths, expected) {
+		t.Errorf("got %v without following symlinks, want %v", paths, expected)
+	}
+
+	results, _, err = ProcessAllFiles[lineResult](&lineSearcher{}, repo, &SearchOptions{FollowSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []string{"a.txt", "dir/b.txt", "link-a.txt", "link-dir/b.txt"}
+	if paths := resultPaths(results); !equalStrings(paths, expected) {
+		t.Errorf("got %v following symlinks, want %v", paths, expected)
+	}
+}
+
+func TestProcessAllCommitsSubmodules(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "parent")
+	signature := &object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Unix(1700000000, 0)}
+
+	// the submodule is checked out in the worktree of the parent
+	writeFile(t, filepath.Join(root, "lib", "lib.txt"), "secret in submodule\n")
+	subRepo, err := git.PlainInit(filepath.Join(root, "lib"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subWorktree, err := subRepo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = subWorktree.Add("lib.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subCommit, err := subWorktree.Commit("add lib", &git.CommitOptions{Author: signature})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := storeTree(t, repo, &object.Tree{Entries: []object.TreeEntry{
+		{Name: "lib", Mode: filemode.Submodule, Hash: subCommit},
+	}})
+
+	commit := &object.Commit{Author: *signature, Committer: *signature, Message: "add submodule", TreeHash: tree}
+	obj := repo.Storer.NewEncodedObject()
+	err = commit.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitHash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/main", commitHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, _, err := ProcessAllCommits[lineResult](root, repo, &lineSearcher{}, ModeAllBranches, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 0 {
+		t.Errorf("submodules should only be searched if enabled, got %v", resultPaths(results))
+	}
+
+	results, _, err = ProcessAllCommits[lineResult](root, repo, &lineSearcher{}, ModeAllBranches, &SearchOptions{Submodules: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths := resultPaths(results); !equalStrings(paths, []string{"lib/lib.txt"}) {
+		t.Fatalf("got %v, want [lib/lib.txt]", paths)
+	}
+
+	branches := results[0].Matches[results[0].Results[0]]
+	if len(branches) != 1 || branches[0].LastCommit().Hash != commitHash {
+		t.Errorf("expected the match to be attributed to the commit of the parent, got %v", branches)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func resultPaths[T SearchResult](results []RepoResult[T]) []string {
+	paths := make([]string, 0)
+	for _, result := range results {
+		if len(result.Results) > 0 {
+			paths = append(paths, result.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// storeCommit stores a commit by Alice at noon UTC on the day. The files are the complete tree by slash separated
+// path, not the changes compared to the parents.
+func storeCommit(t *testing.T, repo *git.Repository, day string, files map[string]string, parents ...plumbing.Hash) plumbing.Hash {
+	t.Helper()
+
+	signature := object.Signature{Name: "Alice", Email: "alice@example.com", When: date(t, day).Add(12 * time.Hour)}
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      "commit " + day,
+		TreeHash:     storeFiles(t, repo, files),
+		ParentHashes: parents,
+	}
+
+	obj := repo.Storer.NewEncodedObject()
+	err := commit.Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// storeFiles stores the files as a tree with a subtree for each directory.
+func storeFiles(t *testing.T, repo *git.Repository, files map[string]string) plumbing.Hash {
+	tree := &object.Tree{}
+	dirs := make(map[string]map[string]string)
+	for path, content := range files {
+		dir, rest, found := strings.Cut(path, "/")
+		if found {
+			if dirs[dir] == nil {
+				dirs[dir] = make(map[string]string)
+			}
+			dirs[dir][rest] = content
+			continue
+		}
+
+		blob := repo.Storer.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		w, err := blob.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+
+		blobHash, err := repo.Storer.SetEncodedObject(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: path, Mode: filemode.Regular, Hash: blobHash})
+	}
+
+	for dir, dirFiles := range dirs {
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: storeFiles(t, repo, dirFiles)})
+	}
+
+	// git sorts directories as if their names end with '/'
+	sortName := func(entry object.TreeEntry) string {
+		if entry.Mode == filemode.Dir {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortName(tree.Entries[i]) < sortName(tree.Entries[j])
+	})
+
+	return storeTree(t, repo, tree)
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Mode string
@@ -12,13 +13,56 @@ const (
 	ModeAllHistory  Mode = "all-history"
 	ModeAllBranches Mode = "all-branches"
 	ModeAllFiles    Mode = "all-files"
+	// ModeDiff searches the lines each commit added compared to its first parent.
+	ModeDiff Mode = "diff"
+	// ModeDiffWithRemoved also searches the lines each commit removed.
+	ModeDiffWithRemoved Mode = "diff-with-removed"
 )
 
+// SearchOptions configures what is searched besides, or instead of, the files of each mode. A nil value is the
+// default.
+type SearchOptions struct {
+	// Metadata searches the messages, author, committer and tagger of every commit and annotated tag, and the
+	// notes in refs/notes/*. Results have pseudo-paths, see MetadataPath.
+	Metadata bool
+	// Submodules searches the commit pinned by each gitlink in a local clone of the submodule, with the path of
+	// the submodule as prefix. It is not supported in ModeDiff and ModeDiffWithRemoved. In ModeAllFiles
+	// checked out submodules are part of the files anyway.
+	Submodules bool
+	// FollowSymlinks searches the files that symlinks inside the repository point to, under the path of the
+	// symlink. It only applies to ModeAllFiles, in history modes the target is searched at its own path.
+	FollowSymlinks bool
+	// SkipIgnored skips the files with the linguist-generated or linguist-vendored attribute in .gitattributes,
+	// and untracked files ignored by .gitignore or .git/info/exclude. In history modes every file is tracked, so
+	// only the .gitattributes files of each tree are used.
+	SkipIgnored bool
+	// Since and Until limit history modes to the commits with a committer date in the range, a zero time is
+	// unbounded. The parents of commits before Since are not searched.
+	Since time.Time
+	Until time.Time
+	// Refs limits history modes to the remote branches matching any of the glob patterns, matched against e.g.
+	// 'refs/remotes/origin/release/1.0', 'origin/release/1.0' and 'release/1.0'. Dangling commits are not searched.
+	Refs []string
+	// CommitRange limits history modes to the commits reachable from B but not from A in 'A..B', like 'git log'.
+	// The results are attributed to a branch named after the range. Dangling commits are not searched.
+	CommitRange string
+}
+
 func Search[T SearchResult](repos []Repository,
 	newSearcher func(Repository) Searcher[T],
 	mode Mode,
 	concurrency int) (chan WorkerResult[T], *WorkerStats) {
 
+	return SearchWithOptions(repos, newSearcher, mode, concurrency, nil)
+}
+
+// SearchWithOptions is Search with the options, a nil value is the same as Search.
+func SearchWithOptions[T SearchResult](repos []Repository,
+	newSearcher func(Repository) Searcher[T],
+	mode Mode,
+	concurrency int,
+	opts *SearchOptions) (chan WorkerResult[T], *WorkerStats) {
+
 	tasksChannel := make(chan Repository, len(repos))
 	for _, repo := range repos {
 		tasksChannel <- repo
@@ -32,6 +76,9 @@ func Search[T SearchResult](repos []Repository,
 	dataLoadTime := atomic.Int64{}
 	listFilesTime := atomic.Int64{}
 	numberOfBranches := atomic.Uint64{}
+	skippedFileSize := atomic.Uint64{}
+	numberOfSkippedFiles := atomic.Uint64{}
+	cache := newBlobCache[T]()
 
 	waitGroup := &sync.WaitGroup{}
 	waitGroup.Add(concurrency)
@@ -47,7 +94,11 @@ func Search[T SearchResult](repos []Repository,
 			queryTimer:       &queryTime,
 			dataLoadTimer:    &dataLoadTime,
 			listFilesTimer:   &listFilesTime,
+			skippedFileSize:  &skippedFileSize,
+			numberOfSkipped:  &numberOfSkippedFiles,
+			blobCache:        cache,
 			mode:             mode,
+			opts:             opts,
 			newSearcher:      newSearcher,
 		}
 		worker.start()
@@ -67,6 +118,8 @@ func Search[T SearchResult](repos []Repository,
 		listFilesTime:    &listFilesTime,
 		numberOfRepos:    len(repos),
 		numberOfBranches: &numberOfBranches,
+		skippedFileSize:  &skippedFileSize,
+		numberOfSkipped:  &numberOfSkippedFiles,
 	}
 }
 
@@ -86,7 +139,11 @@ type worker[T SearchResult] struct {
 	queryTimer       *atomic.Int64
 	dataLoadTimer    *atomic.Int64
 	listFilesTimer   *atomic.Int64
+	skippedFileSize  *atomic.Uint64
+	numberOfSkipped  *atomic.Uint64
+	blobCache        *blobCache[T]
 	mode             Mode
+	opts             *SearchOptions
 	newSearcher      func(Repository) Searcher[T]
 }
 
@@ -97,21 +154,21 @@ func (w *worker[T]) start() {
 			var stats Stats
 
 			searcher := w.newSearcher(task)
-			if w.mode == ModeAllHistory || w.mode == ModeAllBranches {
+			if w.mode == ModeAllHistory || w.mode == ModeAllBranches || w.mode == ModeDiff || w.mode == ModeDiffWithRemoved {
 				repo, err := OpenRepoInLocalPath(task.LocalRootPath())
 				if err != nil {
 					log.Printf("Error opening repo %s (skipping): %v", task.LocalRootPath(), err)
 					continue
 				}
 
-				result, stats, err = ProcessAllCommits[T](task.LocalRootPath(), repo, searcher, w.mode)
+				result, stats, err = processAllCommits[T](task.LocalRootPath(), repo, searcher, w.mode, w.opts, w.blobCache)
 				if err != nil {
 					log.Printf("Error processing repo %s (skipping): %v", task.LocalRootPath(), err)
 					continue
 				}
 			} else {
 				var err error
-				result, stats, err = ProcessAllFiles[T](searcher, task)
+				result, stats, err = ProcessAllFiles[T](searcher, task, w.opts)
 				if err != nil {
 					log.Printf("Error processing repo %s (skipping): %v", task.LocalRootPath(), err)
 					continue
@@ -124,6 +181,8 @@ func (w *worker[T]) start() {
 			w.queryTimer.Add(stats.QueryTime())
 			w.dataLoadTimer.Add(stats.DataLoadTime())
 			w.numberOfBranches.Add(stats.NumberOfBranches())
+			w.skippedFileSize.Add(stats.SkippedSize())
+			w.numberOfSkipped.Add(stats.SkippedFiles())
 
 			if len(result) > 0 {
 				// FIXME don't open repo again or at all when searching files
@@ -157,6 +216,8 @@ type WorkerStats struct {
 	listFilesTime    *atomic.Int64
 	numberOfRepos    int
 	numberOfBranches *atomic.Uint64
+	skippedFileSize  *atomic.Uint64
+	numberOfSkipped  *atomic.Uint64
 }
 
 func (ws *WorkerStats) NumberOfFiles() uint64 {
@@ -186,3 +247,13 @@ func (ws *WorkerStats) NumberOfRepos() int {
 func (ws *WorkerStats) NumberOfBranches() uint64 {
 	return ws.numberOfBranches.Load()
 }
+
+// SkippedFileSize is the size of the blobs that were not searched again, since the results of the blob were
+// already known from another repository or commit. It is only non-zero if the searcher is a BlobSearcher.
+func (ws *WorkerStats) SkippedFileSize() uint64 {
+	return ws.skippedFileSize.Load()
+}
+
+func (ws *WorkerStats) NumberOfSkippedFiles() uint64 {
+	return ws.numberOfSkipped.Load()
+}
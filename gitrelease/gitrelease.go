@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -126,12 +127,21 @@ type GoRelease struct {
 }
 
 func CreateTagLink(repo *git.Repository, commitHash plumbing.Hash, tag *object.Tag, repoUrl string, command []string, tagMetadata *TagMetadata) (*TagLink, error) {
-	gh := githash.NewGitHash(repo, sha256.New())
+	gh, index, err := newSHA256GitHash(repo)
+	if err != nil {
+		return nil, err
+	}
+
 	tagSHA256, err := gh.TagSum(tag.Hash)
 	if err != nil {
 		return nil, err
 	}
 
+	err = index.Save()
+	if err != nil {
+		return nil, err
+	}
+
 	timestamp := time.Now()
 	timestampString := timestamp.Format(time.RFC3339)
 
@@ -270,7 +280,11 @@ func PreAuthenticationEncoding(payload []byte) ([]byte, error) {
 }
 
 func CreatePreviousTagPayload(repo *git.Repository, tagName string, commitHash plumbing.Hash, previousTag *object.Tag, repoUrl string) (*TagMetadata, error) {
-	gh := githash.NewGitHash(repo, sha256.New())
+	gh, index, err := newSHA256GitHash(repo)
+	if err != nil {
+		return nil, err
+	}
+
 	h, err := gh.CommitSum(commitHash)
 	if err != nil {
 		return nil, err
@@ -328,7 +342,6 @@ func CreatePreviousTagPayload(repo *git.Repository, tagName string, commitHash p
 	}
 
 	if previousTag != nil {
-		gh := githash.NewGitHash(repo, sha256.New())
 		tagSHA256, err := gh.TagSum(previousTag.Hash)
 		if err != nil {
 			return nil, err
@@ -342,23 +355,67 @@ func CreatePreviousTagPayload(repo *git.Repository, tagName string, commitHash p
 		}
 	}
 
+	err = index.Save()
+	if err != nil {
+		return nil, err
+	}
+
 	return &tagMetadata, nil
 }
 
+// newSHA256GitHash reuses the object index of the repository if one has been created, e.g. with
+// 'gitverify --object-index'. The index is nil otherwise.
+func newSHA256GitHash(repo *git.Repository) (githash.GitHash, *gitkit.ObjectIndex, error) {
+	index, err := gitkit.OpenExistingObjectIndex(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if index == nil {
+		gh, err := githash.NewGitHash(repo, sha256.New())
+		return gh, nil, err
+	}
+
+	state, err := gitkit.NewLazyRepoStateFromIndex(repo, index, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return githash.NewGitHashFromIndex(state, index, "sha256", sha256.New), index, nil
+}
+
 func FindPreviousTag(repo *git.Repository) (*object.Tag, bool, error) {
 	tags, err := repo.Tags()
 	if err != nil {
 		return nil, false, err
 	}
 
-	state := gitkit.LoadRepoState(repo)
+	index, err := gitkit.OpenExistingObjectIndex(repo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var state *gitkit.LazyRepoState
+	if index != nil {
+		state, err = gitkit.NewLazyRepoStateFromIndex(repo, index, gitkit.DefaultObjectCacheSize)
+	} else {
+		state, err = gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
 	var previousTag *object.Tag
 
 	tagHashes := make(map[string]*object.Tag)
 	previousTagHashes := make(map[string]*TagMetadata)
 
 	err = tags.ForEach(func(tag *plumbing.Reference) error {
-		t, isAnnotatedTag := state.TagMap[tag.Hash()]
+		t, err := state.Tag(tag.Hash())
+		if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return err
+		}
+		isAnnotatedTag := err == nil
 
 		if isAnnotatedTag {
 			tagMetadata, err := decodeTagMetadata(t)
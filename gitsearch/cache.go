@@ -0,0 +1,197 @@
+package gitsearch
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/supply-chain-tools/go-sandbox/search"
+)
+
+// cacheVersion is part of the digest, it has to be changed when the format or the search results change
+const cacheVersion = 1
+
+// scanCache persists the results of each blob, including blobs without results, for one search config. Results
+// only depend on the content of the blob, so path filters are applied before the cache is used, and searches
+// with dorks are not cached since the search depends on the path.
+//
+// The cache is a file of JSON lines named after the digest of the config. New entries are appended when the cache
+// is closed, a truncated last line is skipped when loading.
+type scanCache struct {
+	path    string
+	mutex   sync.Mutex
+	results map[plumbing.Hash][]search.Result
+	added   []cacheEntry
+	hits    uint64
+}
+
+type cacheEntry struct {
+	Blob    string              `json:"blob"`
+	Results []search.ResultData `json:"results"`
+}
+
+// configDigest identifies the search terms and the parameters that change the results of a blob.
+func configDigest(config Config) string {
+	searchTerms := append([]string{}, config.SearchTerms()...)
+	sort.Strings(searchTerms)
+
+	parameters := config.SearchParameters()
+	data, err := json.Marshal(struct {
+		Version                int                 `json:"version"`
+		SearchTerms            []string            `json:"searchTerms"`
+		SearchType             search.Match        `json:"searchType"`
+		CharacterSet           search.CharacterSet `json:"characterSet"`
+		AnchorBeginning        bool                `json:"anchorBeginning"`
+		AnchorEnd              bool                `json:"anchorEnd"`
+		FailOnInvalidCharacter bool                `json:"failOnInvalidCharacter"`
+		IncludeContextInResult bool                `json:"includeContextInResult"`
+		LinesBeforeContext     int                 `json:"linesBeforeContext"`
+		LinesAfterContext      int                 `json:"linesAfterContext"`
+		ContextColumns         int                 `json:"contextColumns"`
+	}{
+		Version:                cacheVersion,
+		SearchTerms:            searchTerms,
+		SearchType:             parameters.SearchType(),
+		CharacterSet:           parameters.CharacterSet(),
+		AnchorBeginning:        parameters.AnchorBeginning(),
+		AnchorEnd:              parameters.AnchorEnd(),
+		FailOnInvalidCharacter: parameters.FailOnInvalidCharacter(),
+		IncludeContextInResult: parameters.IncludeContextInResult(),
+		LinesBeforeContext:     parameters.LinesBeforeContext(),
+		LinesAfterContext:      parameters.LinesAfterContext(),
+		ContextColumns:         parameters.ContextColumns(),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+func openScanCache(dir string, digest string) (*scanCache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache directory '%s': %w", dir, err)
+	}
+
+	cache := &scanCache{
+		path:    filepath.Join(dir, digest+".jsonl"),
+		results: make(map[plumbing.Hash][]search.Result),
+		added:   make([]cacheEntry, 0),
+	}
+
+	f, err := os.Open(cache.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cache '%s': %w", cache.path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry cacheEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			slog.Debug("skipping invalid cache entry", "path", cache.path, "error", err)
+			continue
+		}
+
+		results := make([]search.Result, 0, len(entry.Results))
+		for _, data := range entry.Results {
+			results = append(results, data.Result())
+		}
+		cache.results[plumbing.NewHash(entry.Blob)] = results
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cache '%s': %w", cache.path, err)
+	}
+
+	return cache, nil
+}
+
+// get is safe to call from multiple workers.
+func (sc *scanCache) get(hash plumbing.Hash) ([]search.Result, bool) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	results, found := sc.results[hash]
+	if found {
+		sc.hits++
+	}
+	return results, found
+}
+
+// put is safe to call from multiple workers.
+func (sc *scanCache) put(hash plumbing.Hash, results []search.Result) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	_, found := sc.results[hash]
+	if found {
+		return
+	}
+	sc.results[hash] = results
+
+	entry := cacheEntry{
+		Blob:    hash.String(),
+		Results: make([]search.ResultData, 0, len(results)),
+	}
+	for _, r := range results {
+		entry.Results = append(entry.Results, search.NewResultData(r))
+	}
+	sc.added = append(sc.added, entry)
+}
+
+// close appends the entries added in this run.
+func (sc *scanCache) close() error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if len(sc.added) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(sc.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open cache '%s': %w", sc.path, err)
+	}
+
+	w := bufio.NewWriter(f)
+	for _, entry := range sc.added {
+		data, err := json.Marshal(&entry)
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		_, err = w.Write(append(data, '\n'))
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("unable to write cache '%s': %w", sc.path, err)
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write cache '%s': %w", sc.path, err)
+	}
+	sc.added = sc.added[:0]
+
+	return f.Close()
+}
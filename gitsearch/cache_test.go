@@ -0,0 +1,191 @@
+package gitsearch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/supply-chain-tools/go-sandbox/search"
+)
+
+func TestScanCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	config := NewConfigWithOptions(&ConfigOptions{
+		SearchTerms:      []string{"testing"},
+		SearchParameters: search.NewParameters(search.MatchNormalized, search.CharacterSetPackage),
+		CacheDir:         dir,
+	})
+	digest := configDigest(config)
+
+	results, err := search.New(config.SearchTerms(), config.SearchParameters()).Match([]byte("first line\nsome testing here\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(results))
+	}
+
+	matching := plumbing.NewHash("1111111111111111111111111111111111111111")
+	empty := plumbing.NewHash("2222222222222222222222222222222222222222")
+
+	cache, err := openScanCache(dir, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found := cache.get(matching)
+	if found {
+		t.Fatal("expected an empty cache")
+	}
+
+	cache.put(matching, results)
+	cache.put(empty, []search.Result{})
+	err = cache.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replay the results in a new run
+	cache, err = openScanCache(dir, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached, found := cache.get(matching)
+	if !found || len(cached) != 1 {
+		t.Fatalf("expected the cached result, got %v %t", cached, found)
+	}
+	if search.NewResultData(cached[0]).MatchedText != search.NewResultData(results[0]).MatchedText || cached[0].LineNumber() != results[0].LineNumber() {
+		t.Errorf("got %+v, want %+v", search.NewResultData(cached[0]), search.NewResultData(results[0]))
+	}
+
+	cached, found = cache.get(empty)
+	if !found || len(cached) != 0 {
+		t.Errorf("expected a blob without results to be cached, got %v %t", cached, found)
+	}
+
+	if cache.hits != 2 {
+		t.Errorf("expected 2 hits, got %d", cache.hits)
+	}
+
+	// nothing was added, so the file is not written
+	err = cache.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readLines(t, filepath.Join(dir, digest+".jsonl"))
+	if len(lines) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(lines))
+	}
+}
+
+func TestScanCacheTruncated(t *testing.T) {
+	dir := t.TempDir()
+	digest := "digest"
+
+	cache, err := openScanCache(dir, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := plumbing.NewHash("1111111111111111111111111111111111111111")
+	second := plumbing.NewHash("2222222222222222222222222222222222222222")
+	cache.put(first, []search.Result{})
+	cache.put(second, []search.Result{})
+	err = cache.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a run that was interrupted while appending leaves a partial last line
+	path := filepath.Join(dir, digest+".jsonl")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, data[:len(data)-10], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = openScanCache(dir, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found := cache.get(first)
+	if !found {
+		t.Error("expected the complete entry to be loaded")
+	}
+
+	_, found = cache.get(second)
+	if found {
+		t.Error("expected the truncated entry to be skipped")
+	}
+}
+
+func TestConfigDigest(t *testing.T) {
+	parameters := search.NewParameters(search.MatchNormalized, search.CharacterSetPackage)
+	digest := configDigest(NewConfigWithOptions(&ConfigOptions{SearchTerms: []string{"foo", "bar"}, SearchParameters: parameters}))
+
+	// the order of the search terms and settings that don't change the results of a blob don't matter
+	same := configDigest(NewConfigWithOptions(&ConfigOptions{SearchTerms: []string{"bar", "foo"}, SearchParameters: parameters, IncludePaths: []string{"*.go"}, DisplayColor: true}))
+	if same != digest {
+		t.Error("expected the same digest")
+	}
+
+	for name, config := range map[string]Config{
+		"search terms":  NewConfigWithOptions(&ConfigOptions{SearchTerms: []string{"foo"}, SearchParameters: parameters}),
+		"search type":   NewConfigWithOptions(&ConfigOptions{SearchTerms: []string{"foo", "bar"}, SearchParameters: search.NewParameters(search.MatchExact, search.CharacterSetPackage)}),
+		"character set": NewConfigWithOptions(&ConfigOptions{SearchTerms: []string{"foo", "bar"}, SearchParameters: search.NewParameters(search.MatchNormalized, search.CharacterSetUrl)}),
+	} {
+		if configDigest(config) == digest {
+			t.Errorf("expected a change of %s to change the digest", name)
+		}
+	}
+
+	// a different digest is a different file, so the results of the old config are not used
+	dir := t.TempDir()
+	cache, err := openScanCache(dir, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := plumbing.NewHash("1111111111111111111111111111111111111111")
+	cache.put(hash, []search.Result{})
+	err = cache.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := configDigest(NewConfigWithOptions(&ConfigOptions{SearchTerms: []string{"foo"}, SearchParameters: parameters}))
+	cache, err = openScanCache(dir, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found := cache.get(hash)
+	if found {
+		t.Error("expected the results of another config not to be used")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != digest+".jsonl" {
+		t.Errorf("expected only the file of the first config, got %v", entries)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
@@ -1,6 +1,8 @@
 package gitsearch
 
 import (
+	"time"
+
 	"github.com/supply-chain-tools/go-sandbox/gitkit"
 	"github.com/supply-chain-tools/go-sandbox/search"
 )
@@ -31,6 +33,30 @@ type Config interface {
 	Dorks() []Dork
 }
 
+// ContentConfig is optionally implemented by a Config to search more than the files of each mode, or to skip
+// ignored files, see gitkit.SearchOptions. A Config that doesn't implement it only searches the files.
+type ContentConfig interface {
+	SearchMetadata() bool
+	SearchSubmodules() bool
+	FollowSymlinks() bool
+	SkipIgnored() bool
+}
+
+// HistoryConfig is optionally implemented by a Config to limit the commits searched in history modes, see
+// gitkit.SearchOptions. A Config that doesn't implement it searches all commits.
+type HistoryConfig interface {
+	Since() time.Time
+	Until() time.Time
+	Refs() []string
+	CommitRange() string
+}
+
+// CacheConfig is optionally implemented by a Config to cache results between runs. Results are not cached if
+// CacheDir is empty or the Config doesn't implement it.
+type CacheConfig interface {
+	CacheDir() string
+}
+
 type config struct {
 	searchTerms         []string
 	excludeWords        []string
@@ -53,6 +79,50 @@ type config struct {
 	onlyMatching        bool
 	outputSearchStrings bool
 	dorks               []Dork
+	searchMetadata      bool
+	searchSubmodules    bool
+	followSymlinks      bool
+	skipIgnored         bool
+	cacheDir            string
+	since               time.Time
+	until               time.Time
+	refs                []string
+	commitRange         string
+}
+
+// ConfigOptions are the settings of a search, see Config, ContentConfig, HistoryConfig and CacheConfig. A
+// Concurrency below 1 uses the default.
+type ConfigOptions struct {
+	SearchTerms         []string
+	ExcludeWords        []string
+	IncludeRepos        []string
+	ExcludeRepos        []string
+	IncludePaths        []string
+	ExcludePaths        []string
+	SearchParameters    search.Parameters
+	Repos               []gitkit.Repository
+	Mode                gitkit.Mode
+	DisplayStats        bool
+	Concurrency         int
+	DisplayHeading      bool
+	OutputJsonLines     bool
+	DisplayLineNumber   bool
+	DisplayColumn       bool
+	DisplayKeyword      bool
+	DisplayColor        bool
+	HideRepoAndFilename bool
+	OnlyMatching        bool
+	OutputSearchStrings bool
+	Dorks               []Dork
+	SearchMetadata      bool
+	SearchSubmodules    bool
+	FollowSymlinks      bool
+	SkipIgnored         bool
+	CacheDir            string
+	Since               time.Time
+	Until               time.Time
+	Refs                []string
+	CommitRange         string
 }
 
 func NewConfig(searchTerms []string,
@@ -77,32 +147,69 @@ func NewConfig(searchTerms []string,
 	outputJsonLines bool,
 	dorks []Dork) Config {
 
+	return NewConfigWithOptions(&ConfigOptions{
+		SearchTerms:         searchTerms,
+		SearchParameters:    searchParameters,
+		Mode:                mode,
+		Repos:               repos,
+		Concurrency:         concurrency,
+		ExcludeWords:        excludeWords,
+		IncludeRepos:        includeRepos,
+		ExcludeRepos:        excludeRepos,
+		IncludePaths:        includePaths,
+		ExcludePaths:        excludePaths,
+		DisplayStats:        displayStats,
+		DisplayHeading:      displayHeading,
+		DisplayLineNumber:   displayLineNumber,
+		DisplayColumn:       displayColumn,
+		DisplayKeyword:      displayKeyword,
+		DisplayColor:        displayColor,
+		HideRepoAndFilename: hideRepoAndFilename,
+		OnlyMatching:        onlyMatching,
+		OutputSearchStrings: outputSearchStrings,
+		OutputJsonLines:     outputJsonLines,
+		Dorks:               dorks,
+	})
+}
+
+// NewConfigWithOptions returns a Config that also implements ContentConfig, HistoryConfig and CacheConfig.
+func NewConfigWithOptions(opts *ConfigOptions) Config {
+	concurrency := opts.Concurrency
 	if concurrency < 1 {
 		concurrency = defaultConcurrency
 	}
 
 	return &config{
-		searchTerms:         searchTerms,
-		searchParameters:    searchParameters,
-		mode:                mode,
-		repos:               repos,
+		searchTerms:         opts.SearchTerms,
+		excludeWords:        opts.ExcludeWords,
+		includeRepos:        opts.IncludeRepos,
+		excludeRepos:        opts.ExcludeRepos,
+		includePaths:        opts.IncludePaths,
+		excludePaths:        opts.ExcludePaths,
+		searchParameters:    opts.SearchParameters,
+		repos:               opts.Repos,
+		mode:                opts.Mode,
+		displayStats:        opts.DisplayStats,
 		concurrency:         concurrency,
-		excludeWords:        excludeWords,
-		includeRepos:        includeRepos,
-		excludeRepos:        excludeRepos,
-		includePaths:        includePaths,
-		excludePaths:        excludePaths,
-		displayStats:        displayStats,
-		displayHeading:      displayHeading,
-		displayLineNumber:   displayLineNumber,
-		displayColumn:       displayColumn,
-		displayKeyword:      displayKeyword,
-		displayColor:        displayColor,
-		hideRepoAndFilename: hideRepoAndFilename,
-		onlyMatching:        onlyMatching,
-		outputSearchStrings: outputSearchStrings,
-		outputJsonLines:     outputJsonLines,
-		dorks:               dorks,
+		displayHeading:      opts.DisplayHeading,
+		outputJsonLines:     opts.OutputJsonLines,
+		displayLineNumber:   opts.DisplayLineNumber,
+		displayColumn:       opts.DisplayColumn,
+		displayKeyword:      opts.DisplayKeyword,
+		displayColor:        opts.DisplayColor,
+		hideRepoAndFilename: opts.HideRepoAndFilename,
+		onlyMatching:        opts.OnlyMatching,
+		outputSearchStrings: opts.OutputSearchStrings,
+		dorks:               opts.Dorks,
+		searchMetadata:      opts.SearchMetadata,
+		searchSubmodules:    opts.SearchSubmodules,
+		followSymlinks:      opts.FollowSymlinks,
+		skipIgnored:         opts.SkipIgnored,
+		cacheDir:            opts.CacheDir,
+		since:               opts.Since,
+		until:               opts.Until,
+		refs:                opts.Refs,
+		commitRange:         opts.CommitRange,
 	}
 }
 
@@ -190,6 +297,45 @@ func (c *config) Dorks() []Dork {
 	return c.dorks
 }
 
+func (c *config) SearchMetadata() bool {
+	return c.searchMetadata
+}
+
+func (c *config) SearchSubmodules() bool {
+	return c.searchSubmodules
+}
+
+func (c *config) FollowSymlinks() bool {
+	return c.followSymlinks
+}
+
+func (c *config) SkipIgnored() bool {
+	return c.skipIgnored
+}
+
+// CacheDir is empty unless results are cached between runs.
+func (c *config) CacheDir() string {
+	return c.cacheDir
+}
+
+// Since is the zero time unless history is limited.
+func (c *config) Since() time.Time {
+	return c.since
+}
+
+// Until is the zero time unless history is limited.
+func (c *config) Until() time.Time {
+	return c.until
+}
+
+func (c *config) Refs() []string {
+	return c.refs
+}
+
+func (c *config) CommitRange() string {
+	return c.commitRange
+}
+
 type Dork interface {
 	Path() string
 	PathType() PathType
@@ -0,0 +1,51 @@
+package gitsearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+	"github.com/supply-chain-tools/go-sandbox/search"
+)
+
+// baseConfig only implements Config, like implementations written before the optional interfaces were added
+type baseConfig struct {
+	Config
+}
+
+func TestSearchOptions(t *testing.T) {
+	parameters := search.NewParameters(search.MatchNormalized, search.CharacterSetPackage)
+	since := time.Unix(1700000000, 0)
+
+	config := NewConfigWithOptions(&ConfigOptions{
+		SearchTerms:      []string{"foo"},
+		SearchParameters: parameters,
+		SearchMetadata:   true,
+		SkipIgnored:      true,
+		Since:            since,
+		CommitRange:      "v1..v2",
+		CacheDir:         "cache",
+	})
+
+	opts := searchOptions(config)
+	if !opts.Metadata || !opts.SkipIgnored || !opts.Since.Equal(since) || opts.CommitRange != "v1..v2" {
+		t.Errorf("expected the options of the config, got %+v", opts)
+	}
+
+	_, found := config.(CacheConfig)
+	if !found {
+		t.Error("expected NewConfigWithOptions to implement CacheConfig")
+	}
+
+	base := baseConfig{config}
+	opts = searchOptions(base)
+	if opts.Metadata || opts.SkipIgnored || !opts.Since.IsZero() || opts.CommitRange != "" {
+		t.Errorf("expected the default options for a config without the optional interfaces, got %+v", opts)
+	}
+
+	positional := NewConfig([]string{"foo"}, parameters, gitkit.ModeAllFiles, nil, 0, nil, nil, nil, nil, nil,
+		false, false, false, false, false, false, false, false, false, false, nil)
+	if positional.Concurrency() != defaultConcurrency || positional.Mode() != gitkit.ModeAllFiles {
+		t.Errorf("got concurrency %d and mode %s", positional.Concurrency(), positional.Mode())
+	}
+}
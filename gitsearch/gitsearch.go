@@ -112,13 +112,27 @@ func SearchLocalRepos(config Config) {
 		excludeSet.Add(strings.ToLower(excludeKeyword))
 	}
 
+	var cache *scanCache
+	cacheConfig, hasCache := config.(CacheConfig)
+	if hasCache && cacheConfig.CacheDir() != "" && len(dorkSearches) == 0 {
+		var err error
+		cache, err = openScanCache(cacheConfig.CacheDir(), configDigest(config))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	newSearcher := func(repo gitkit.Repository) gitkit.Searcher[search.Result] {
-		return newGitSearchSearcher(repo, compiledSearch, dorkSearches, pathFilter)
+		searcher := newGitSearchSearcher(repo, compiledSearch, dorkSearches, pathFilter, cache)
+		if len(dorkSearches) == 0 {
+			return &blobSearcher{searcher}
+		}
+		return searcher
 	}
 
 	setupElapsed := time.Since(setupStart)
 	start := time.Now()
-	resultsChannel, workerStats := gitkit.Search(repos, newSearcher, config.Mode(), config.Concurrency())
+	resultsChannel, workerStats := gitkit.SearchWithOptions(repos, newSearcher, config.Mode(), config.Concurrency(), searchOptions(config))
 
 	filesMatched := 0
 	matches := 0
@@ -162,6 +176,16 @@ func SearchLocalRepos(config Config) {
 						Org:           workerResult.Repo.OrganizationName(),
 					}
 
+					for _, diffResult := range result.Commits[r] {
+						resultOutput.Commits = append(resultOutput.Commits, CommitOutput{
+							Hash:        diffResult.Commit().Hash.String(),
+							AuthorName:  diffResult.Commit().Author.Name,
+							AuthorEmail: diffResult.Commit().Author.Email,
+							Date:        diffResult.Commit().Author.When,
+							Removed:     diffResult.Removed(),
+						})
+					}
+
 					serializedOutput, err := json.Marshal(&resultOutput)
 					if err != nil {
 						log.Fatal(err)
@@ -270,6 +294,17 @@ func SearchLocalRepos(config Config) {
 							fmt.Printf("  tags %s%s%s\n", blue, strings.Join(names, ","), reset)
 						}
 					}
+
+					for _, diffResult := range result.Commits[r] {
+						change := "+"
+						if diffResult.Removed() {
+							change = "-"
+						}
+
+						commit := diffResult.Commit()
+						fmt.Printf("  %s%s[%s:%s] %s <%s>%s\n", blue, change, commit.Author.When.Format(time.DateOnly),
+							commit.Hash.String()[:6], commit.Author.Name, commit.Author.Email, reset)
+					}
 				}
 
 				matches++
@@ -278,6 +313,17 @@ func SearchLocalRepos(config Config) {
 	}
 	elapsed := time.Since(start)
 
+	var cacheHits uint64
+	var cacheAdded int
+	if cache != nil {
+		cacheHits = cache.hits
+		cacheAdded = len(cache.added)
+		err := cache.close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	totalSize := workerStats.TotalFileSize()
 	numFiles := workerStats.NumberOfFiles()
 
@@ -295,7 +341,35 @@ func SearchLocalRepos(config Config) {
 			float64(numFiles)/elapsed.Seconds(),
 			float64(len(repos))/elapsed.Seconds())
 		fmt.Printf("total: %.0f MB, %d files, %d repos\n", float64(totalSize)/1000000.0, numFiles, len(repos))
+		fmt.Printf("skipped: %.0f MB, %d files already searched in other repos or commits\n",
+			float64(workerStats.SkippedFileSize())/1000000.0, workerStats.NumberOfSkippedFiles())
 		fmt.Printf("trie: %d nodes, %.0f MB\n", numNodes, float64(trieSizeInBytes)/1000000.0)
+		if cache != nil {
+			fmt.Printf("cache: %d blobs replayed, %d blobs added\n", cacheHits, cacheAdded)
+		}
 		fmt.Printf("concurrency: %d\n", config.Concurrency())
 	}
 }
+
+// searchOptions returns the options of the optional interfaces the config implements.
+func searchOptions(config Config) *gitkit.SearchOptions {
+	opts := &gitkit.SearchOptions{}
+
+	contentConfig, found := config.(ContentConfig)
+	if found {
+		opts.Metadata = contentConfig.SearchMetadata()
+		opts.Submodules = contentConfig.SearchSubmodules()
+		opts.FollowSymlinks = contentConfig.FollowSymlinks()
+		opts.SkipIgnored = contentConfig.SkipIgnored()
+	}
+
+	historyConfig, found := config.(HistoryConfig)
+	if found {
+		opts.Since = historyConfig.Since()
+		opts.Until = historyConfig.Until()
+		opts.Refs = historyConfig.Refs()
+		opts.CommitRange = historyConfig.CommitRange()
+	}
+
+	return opts
+}
@@ -1,5 +1,7 @@
 package gitsearch
 
+import "time"
+
 type ResultOutput struct {
 	MatchedText   string `json:"matchedText"`
 	SearchTerm    string `json:"searchTerm"`
@@ -10,4 +12,14 @@ type ResultOutput struct {
 	Path          string `json:"path"`
 	Repo          string `json:"repo"`
 	Org           string `json:"org"`
+	// Commits is only set with --diff.
+	Commits []CommitOutput `json:"commits,omitempty"`
+}
+
+type CommitOutput struct {
+	Hash        string    `json:"hash"`
+	AuthorName  string    `json:"authorName"`
+	AuthorEmail string    `json:"authorEmail"`
+	Date        time.Time `json:"date"`
+	Removed     bool      `json:"removed,omitempty"`
 }
@@ -13,7 +13,8 @@ type gitSearchSearcher struct {
 	dorkSearches []*dorkSearch
 	pathFilter   *pathFilter
 	subPath      *string
-	blobResults  map[plumbing.Hash][]search.Result
+	// cache is nil unless results are cached between runs
+	cache *scanCache
 }
 
 type dorkSearch struct {
@@ -22,12 +23,13 @@ type dorkSearch struct {
 	pathType PathType
 }
 
-func newGitSearchSearcher(repo gitkit.Repository, search search.Search, dorkSearches []*dorkSearch, pathFilter *pathFilter) *gitSearchSearcher {
+func newGitSearchSearcher(repo gitkit.Repository, search search.Search, dorkSearches []*dorkSearch, pathFilter *pathFilter, cache *scanCache) *gitSearchSearcher {
 	return &gitSearchSearcher{
 		search:       search,
 		dorkSearches: dorkSearches,
 		pathFilter:   pathFilter,
 		subPath:      repo.SubPath(),
+		cache:        cache,
 	}
 }
 
@@ -37,23 +39,7 @@ func (gs *gitSearchSearcher) Process(hash *plumbing.Hash, loadData func() []byte
 	}
 
 	if len(gs.dorkSearches) == 0 {
-		found := false
-		var blobResults []search.Result
-
-		if hash != nil {
-			blobResults, found = gs.blobResults[*hash]
-		}
-
-		if found {
-			return blobResults
-		} else {
-			r, err := gs.search.Match(loadData())
-			if err != nil {
-				return []search.Result{}
-			} else {
-				return r
-			}
-		}
+		return gs.searchContent(hash, loadData)
 	} else {
 		searches := convertToSearch(path, gs.dorkSearches)
 		results := make([]search.Result, 0)
@@ -73,6 +59,70 @@ func (gs *gitSearchSearcher) Process(hash *plumbing.Hash, loadData func() []byte
 	}
 }
 
+func (gs *gitSearchSearcher) searchContent(hash *plumbing.Hash, loadData func() []byte) []search.Result {
+	found := false
+	var blobResults []search.Result
+
+	if hash != nil && gs.cache != nil {
+		blobResults, found = gs.cache.get(*hash)
+	}
+
+	if found {
+		return blobResults
+	} else {
+		r, err := gs.search.Match(loadData())
+		if err != nil {
+			return []search.Result{}
+		} else {
+			if hash != nil && gs.cache != nil {
+				gs.cache.put(*hash, r)
+			}
+			return r
+		}
+	}
+}
+
+// blobSearcher is used without dorks, then the results of a file only depend on its content, so gitkit.Search
+// can share the results of a blob between repositories.
+type blobSearcher struct {
+	*gitSearchSearcher
+}
+
+func (bs *blobSearcher) SearchPath(path string) bool {
+	return shouldProcess(path, bs.pathFilter, bs.subPath)
+}
+
+func (bs *blobSearcher) ProcessBlob(hash plumbing.Hash, loadData func() []byte) []search.Result {
+	return bs.searchContent(&hash, loadData)
+}
+
+// ProcessMetadata searches commit and tag metadata, which is not subject to the path filters. Dorks only
+// match metadata if they match any path.
+func (gs *gitSearchSearcher) ProcessMetadata(loadData func() []byte, path string) []search.Result {
+	if len(gs.dorkSearches) == 0 {
+		r, err := gs.search.Match(loadData())
+		if err != nil {
+			return []search.Result{}
+		}
+		return r
+	}
+
+	results := make([]search.Result, 0)
+	for _, dorkSearch := range gs.dorkSearches {
+		if dorkSearch.pathType != PathTypeAnything {
+			continue
+		}
+
+		r, err := dorkSearch.search.Match(loadData())
+		if err != nil {
+			continue
+		}
+		results = append(results, r...)
+	}
+
+	return results
+}
+
 func shouldProcess(localPath string, pathFilter *pathFilter, subPath *string) bool {
 	if subPath != nil && !strings.HasPrefix(localPath, *subPath) {
 		return false
@@ -12,12 +12,21 @@ import (
 )
 
 func AfterCandidates(repo *git.Repository, repoConfig *RepoConfig, useSHA512 bool) ([]After, error) {
-	state := gitkit.LoadRepoState(repo)
-	sha512Hash := githash.NewGitHashFromRepoState(state, sha512.New())
+	state, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	sha512Hash := githash.NewGitHashFromRepoStateFunc(state, sha512.New)
 
 	pointedTo := hashset.New[plumbing.Hash]()
 
-	for _, commit := range state.CommitMap {
+	for _, hash := range state.CommitHashes() {
+		commit, err := state.Commit(hash)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, parent := range commit.ParentHashes {
 			pointedTo.Add(parent)
 		}
@@ -68,18 +77,18 @@ func AfterCandidates(repo *git.Repository, repoConfig *RepoConfig, useSHA512 boo
 		return nil, err
 	}
 
-	for _, commit := range state.CommitMap {
-		if !pointedTo.Contains(commit.Hash) {
+	for _, hash := range sortedHashes(state.CommitHashes()) {
+		if !pointedTo.Contains(hash) {
 
-			sha1 := commit.Hash.String()
+			sha1 := hash.String()
 
-			if protectedHashes.Contains(commit.Hash) {
+			if protectedHashes.Contains(hash) {
 				continue
 			}
 
 			var hexSHA512 *string = nil
 			if useSHA512 {
-				sha2, err := sha512Hash.CommitSum(commit.Hash)
+				sha2, err := sha512Hash.CommitSum(hash)
 				if err != nil {
 					return nil, err
 				}
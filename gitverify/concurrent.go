@@ -0,0 +1,145 @@
+package gitverify
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/supply-chain-tools/go-sandbox/githash"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+	"github.com/supply-chain-tools/go-sandbox/hashset"
+)
+
+func defaultConcurrency() int {
+	return runtime.NumCPU()
+}
+
+// forEachConcurrently runs f on all items using up to concurrency workers. The returned
+// errors are in the same order as items, so the outcome does not depend on scheduling.
+// Items that have not been started when ctx is cancelled get the context error.
+func forEachConcurrently[T any](ctx context.Context, items []T, concurrency int, f func(T) error) []error {
+	errs := make([]error, len(items))
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	indexes := make(chan int, len(items))
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := ctx.Err()
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+
+				errs[i] = f(items[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedHashes(hashes []plumbing.Hash) []plumbing.Hash {
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+
+	return hashes
+}
+
+// precomputeHashes fills the caches of the GitHashes by hashing all blobs, and then all root trees of
+// commits, in parallel. Commits are left to be hashed on demand since each commit depends on its parents.
+func precomputeHashes(ctx context.Context, state gitkit.RepoObjects, concurrency int, progress *progressReporter, gitHashes ...githash.GitHash) error {
+	blobHashes, err := state.BlobHashes()
+	if err != nil {
+		return err
+	}
+
+	commitHashes := state.CommitHashes()
+	rootTrees := hashset.New[plumbing.Hash]()
+	for _, hash := range commitHashes {
+		commit, err := state.Commit(hash)
+		if err != nil {
+			return err
+		}
+		rootTrees.Add(commit.TreeHash)
+	}
+
+	progress.startHashing(len(blobHashes) + rootTrees.Size() + len(commitHashes))
+
+	errs := forEachConcurrently(ctx, sortedHashes(blobHashes), concurrency, func(hash plumbing.Hash) error {
+		for _, gitHash := range gitHashes {
+			_, err := gitHash.BlobSum(hash)
+			if err != nil {
+				return err
+			}
+		}
+		progress.hashed(1)
+		return nil
+	})
+
+	err = firstError(errs)
+	if err != nil {
+		return err
+	}
+
+	errs = forEachConcurrently(ctx, sortedHashes(rootTrees.Values()), concurrency, func(hash plumbing.Hash) error {
+		for _, gitHash := range gitHashes {
+			_, err := gitHash.TreeSum(hash)
+			if err != nil {
+				return err
+			}
+		}
+		progress.hashed(1)
+		return nil
+	})
+
+	return firstError(errs)
+}
+
+// validateCommitsConcurrently validates the signature of every commit. If more than one commit
+// fails, the error for the commit with the lowest hash is returned. Commits are read from the state
+// by the workers, so they do not all have to be in memory at once.
+func validateCommitsConcurrently(ctx context.Context, state gitkit.RepoObjects, commitMetadata map[plumbing.Hash]*CommitData, repoConfig *RepoConfig, gitDir string, concurrency int, progress *progressReporter) error {
+	hashes := sortedHashes(state.CommitHashes())
+
+	progress.startVerifying(len(hashes))
+
+	errs := forEachConcurrently(ctx, hashes, concurrency, func(hash plumbing.Hash) error {
+		commit, err := state.Commit(hash)
+		if err != nil {
+			return err
+		}
+
+		err = validateCommit(commit, commitMetadata, repoConfig, gitDir)
+		progress.verified(1)
+		return err
+	})
+
+	return firstError(errs)
+}
@@ -0,0 +1,141 @@
+package gitverify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/supply-chain-tools/go-sandbox/githash"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+func TestForEachConcurrently(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	for _, concurrency := range []int{0, 1, 4, 200} {
+		errs := forEachConcurrently(context.Background(), items, concurrency, func(i int) error {
+			if i%10 == 3 {
+				return fmt.Errorf("error %d", i)
+			}
+			return nil
+		})
+
+		if len(errs) != len(items) {
+			t.Fatalf("len(errs)=%d, want %d", len(errs), len(items))
+		}
+
+		for i, err := range errs {
+			if (err != nil) != (i%10 == 3) {
+				t.Errorf("concurrency %d: errs[%d]=%v", concurrency, i, err)
+			}
+		}
+
+		err := firstError(errs)
+		if err == nil || err.Error() != "error 3" {
+			t.Errorf("concurrency %d: firstError=%v, want %q", concurrency, err, "error 3")
+		}
+	}
+}
+
+func TestPrecomputeHashesConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 10; j++ {
+			path := filepath.Join(dir, fmt.Sprintf("dir%d", j%3), fmt.Sprintf("file%d.txt", j))
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = os.WriteFile(path, []byte(fmt.Sprintf("commit %d file %d\n", i, j)), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = worktree.AddGlob(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		commitEmpty(t, repo, fmt.Sprintf("commit %d", i))
+	}
+
+	state, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha1Hash := githash.NewGitHashFromRepoStateFunc(state, sha1.New)
+	sha512Hash := githash.NewGitHashFromRepoStateFunc(state, sha512.New)
+
+	err = precomputeHashes(context.Background(), state, 8, nil, sha1Hash, sha512Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitHashes := state.CommitHashes()
+	if len(commitHashes) != 5 {
+		t.Fatalf("expected 5 commits, got %d", len(commitHashes))
+	}
+
+	errs := forEachConcurrently(context.Background(), commitHashes, 8, func(hash plumbing.Hash) error {
+		sum, err := sha1Hash.CommitSum(hash)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(sum, hash[:]) {
+			return fmt.Errorf("SHA-1 of commit %s is %x", hash, sum)
+		}
+		return nil
+	})
+
+	err = firstError(errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialSHA512 := githash.NewGitHashFromRepoState(state, sha512.New())
+	errs = forEachConcurrently(context.Background(), commitHashes, 8, func(hash plumbing.Hash) error {
+		sum, err := sha512Hash.CommitSum(hash)
+		if err != nil {
+			return err
+		}
+
+		expected, err := serialSHA512.CommitSum(hash)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(sum, expected) {
+			return fmt.Errorf("SHA-512 of commit %s is %x, want %x", hash, sum, expected)
+		}
+		return nil
+	})
+
+	err = firstError(errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
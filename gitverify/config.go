@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -28,12 +29,14 @@ type Config struct {
 }
 
 type Identity struct {
-	Email            string   `json:"email"`
-	AdditionalEmails []string `json:"additionalEmails"`
-	GPGPublicKeys    []string `json:"gpgPublicKeys"`
-	SSHPublicKeys    []string `json:"sshPublicKeys"`
-	ForgeUsername    *string  `json:"forgeUsername"`
-	ForgeUserId      *string  `json:"forgeUserId"`
+	Email            string            `json:"email"`
+	AdditionalEmails []string          `json:"additionalEmails"`
+	GPGPublicKeys    []string          `json:"gpgPublicKeys"`
+	SSHPublicKeys    []string          `json:"sshPublicKeys"`
+	ForgeUsername    *string           `json:"forgeUsername"`
+	ForgeUserId      *string           `json:"forgeUserId"`
+	ValidFrom        *string           `json:"validFrom"`
+	KeyValidFrom     map[string]string `json:"keyValidFrom"`
 }
 
 type ForgeRules struct {
@@ -52,6 +55,45 @@ type Rules struct {
 	RequireSignedTags   *bool `json:"RequireSignedTags"`
 	RequireMergeCommits *bool `json:"requireMergeCommits"`
 	RequireUpToDate     *bool `json:"requireUpToDate"`
+
+	MaxTimestampSkew           *string `json:"maxTimestampSkew"`
+	RejectFutureTimestamps     *bool   `json:"rejectFutureTimestamps"`
+	RequireTagsNewerThanTarget *bool   `json:"requireTagsNewerThanTarget"`
+
+	RequiredTrailers  []TrailerRule     `json:"requiredTrailers"`
+	ForbiddenTrailers []string          `json:"forbiddenTrailers"`
+	TrailerScope      *TrailerScope     `json:"trailerScope"`
+	MessagePatterns   map[string]string `json:"messagePatterns"`
+
+	BranchStrategies map[string]BranchStrategy `json:"branchStrategies"`
+}
+
+// TrailerScope is the set of commits the trailer rules apply to.
+type TrailerScope string
+
+const (
+	// TrailerScopeProtectedBranches applies the trailer rules to commits reachable from a protected branch.
+	TrailerScopeProtectedBranches TrailerScope = "protectedBranches"
+	// TrailerScopeAll applies the trailer rules to every commit after 'after', e.g. to check feature branches before they are merged.
+	TrailerScopeAll TrailerScope = "all"
+)
+
+// BranchStrategy is the allowed shape of the first-parent history of a protected branch.
+type BranchStrategy string
+
+const (
+	// BranchStrategyMerge requires every commit to be a merge commit by a maintainer, without content changes.
+	BranchStrategyMerge BranchStrategy = "merge"
+	// BranchStrategyLinear requires every commit to have a single parent and be signed by a maintainer, e.g. rebase and fast-forward.
+	BranchStrategyLinear BranchStrategy = "linear"
+	// BranchStrategySquash requires every commit to have a single parent and be signed by a maintainer or the forge.
+	BranchStrategySquash BranchStrategy = "squash"
+)
+
+type TrailerRule struct {
+	Key         string  `json:"key"`
+	Pattern     *string `json:"pattern"`
+	MatchAuthor bool    `json:"matchAuthor"`
 }
 
 type Repository struct {
@@ -110,6 +152,23 @@ type ParsedRules struct {
 	RequireSignedTags   bool
 	RequireMergeCommits bool
 	RequireUpToDate     bool
+
+	MaxTimestampSkew           *time.Duration
+	RejectFutureTimestamps     bool
+	RequireTagsNewerThanTarget bool
+
+	RequiredTrailers  []ParsedTrailerRule
+	ForbiddenTrailers []string
+	TrailerScope      TrailerScope
+	MessagePatterns   map[string]*regexp.Regexp
+
+	BranchStrategies map[string]BranchStrategy
+}
+
+type ParsedTrailerRule struct {
+	Key         string
+	Pattern     *regexp.Regexp
+	MatchAuthor bool
 }
 
 func GetConfigPath(forge string, org string) (string, error) {
@@ -222,6 +281,7 @@ func parseConfig(config *Config) (*ParsedConfig, error) {
 			RequireSignedTags:      true,
 			RequireMergeCommits:    true,
 			RequireUpToDate:        true,
+			TrailerScope:           TrailerScopeProtectedBranches,
 		}
 
 		if rules != nil {
@@ -256,6 +316,68 @@ func parseConfig(config *Config) (*ParsedConfig, error) {
 			if rules.RequireUpToDate != nil {
 				parsedRules.RequireUpToDate = *rules.RequireUpToDate
 			}
+
+			if rules.MaxTimestampSkew != nil {
+				skew, err := time.ParseDuration(*rules.MaxTimestampSkew)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse rules.maxTimestampSkew: %w", err)
+				}
+
+				if skew < 0 {
+					return nil, fmt.Errorf("rules.maxTimestampSkew must not be negative, got %s", *rules.MaxTimestampSkew)
+				}
+
+				parsedRules.MaxTimestampSkew = &skew
+			}
+
+			if rules.RejectFutureTimestamps != nil {
+				parsedRules.RejectFutureTimestamps = *rules.RejectFutureTimestamps
+			}
+
+			if rules.RequireTagsNewerThanTarget != nil {
+				parsedRules.RequireTagsNewerThanTarget = *rules.RequireTagsNewerThanTarget
+			}
+
+			parsedRules.RequiredTrailers, err = parseTrailerRules(rules.RequiredTrailers)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, key := range rules.ForbiddenTrailers {
+				if !trailerKeyRegex.MatchString(key) {
+					return nil, fmt.Errorf("invalid trailer key '%s' in rules.forbiddenTrailers", key)
+				}
+			}
+			parsedRules.ForbiddenTrailers = rules.ForbiddenTrailers
+
+			if rules.TrailerScope != nil {
+				switch *rules.TrailerScope {
+				case TrailerScopeProtectedBranches, TrailerScopeAll:
+					parsedRules.TrailerScope = *rules.TrailerScope
+				default:
+					return nil, fmt.Errorf("unknown rules.trailerScope '%s', expected protectedBranches or all", *rules.TrailerScope)
+				}
+			}
+
+			for branch, strategy := range rules.BranchStrategies {
+				switch strategy {
+				case BranchStrategyMerge, BranchStrategyLinear, BranchStrategySquash:
+				default:
+					return nil, fmt.Errorf("unknown rules.branchStrategies '%s' for '%s', expected merge, linear or squash", strategy, branch)
+				}
+			}
+			parsedRules.BranchStrategies = rules.BranchStrategies
+
+			if len(rules.MessagePatterns) > 0 {
+				parsedRules.MessagePatterns = make(map[string]*regexp.Regexp)
+				for branch, pattern := range rules.MessagePatterns {
+					r, err := regexp.Compile(pattern)
+					if err != nil {
+						return nil, fmt.Errorf("failed to compile rules.messagePatterns for '%s': %w", branch, err)
+					}
+					parsedRules.MessagePatterns[branch] = r
+				}
+			}
 		}
 
 		forgeRules, err := combineForgeRules(config.ForgeRules, repo.ForgeRules)
@@ -413,6 +535,32 @@ func combineContributors(global []string, local []string) ([]string, error) {
 	}
 }
 
+func parseTrailerRules(rules []TrailerRule) ([]ParsedTrailerRule, error) {
+	parsed := make([]ParsedTrailerRule, 0, len(rules))
+	for _, rule := range rules {
+		if !trailerKeyRegex.MatchString(rule.Key) {
+			return nil, fmt.Errorf("invalid trailer key '%s' in rules.requiredTrailers", rule.Key)
+		}
+
+		var pattern *regexp.Regexp
+		if rule.Pattern != nil {
+			var err error
+			pattern, err = regexp.Compile(*rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile pattern for trailer '%s': %w", rule.Key, err)
+			}
+		}
+
+		parsed = append(parsed, ParsedTrailerRule{
+			Key:         rule.Key,
+			Pattern:     pattern,
+			MatchAuthor: rule.MatchAuthor,
+		})
+	}
+
+	return parsed, nil
+}
+
 func combineRules(global *Rules, local *Rules) (*Rules, error) {
 	if local != nil {
 		return local, nil
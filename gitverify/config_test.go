@@ -192,3 +192,55 @@ func TestConfig(t *testing.T) {
 		t.Errorf("repo1.ForgeRules.AllowContentCommits=%t, want %t", repo1.ForgeRules.AllowContentCommits, false)
 	}
 }
+
+func TestBranchStrategies(t *testing.T) {
+	config := `
+{
+  "_type": "https://supply-chain-tools.github.io/schemas/gitverify/v0.1",
+  "identities": [{"email": "a@example.internal"}],
+  "maintainers": ["a@example.internal"],
+  "rules": {"branchStrategies": {"main": "linear"}},
+  "protectedBranches": ["main"],
+  "repositories": [{
+    "uri": "git+https://github.com/foo/bar.git",
+    "after": [{"sha1": "0000000000000000000000000000000000000000", "branch": "main"}]
+  }]
+}
+`
+	runnerConfig := &Config{}
+	err := json.Unmarshal([]byte(config), runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseConfig(runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoConfig, err := LoadRepoConfig(parsed, "git+https://github.com/foo/bar.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strategy := branchStrategy("main", repoConfig); strategy != BranchStrategyLinear {
+		t.Errorf("branchStrategy(main)=%q, want %q", strategy, BranchStrategyLinear)
+	}
+
+	runnerConfig.Rules.BranchStrategies["main"] = "rebase"
+	_, err = parseConfig(runnerConfig)
+	if err == nil {
+		t.Errorf("expected unknown branch strategy to fail")
+	}
+
+	runnerConfig.Rules.BranchStrategies = map[string]BranchStrategy{"develop": BranchStrategySquash}
+	parsed, err = parseConfig(runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadRepoConfig(parsed, "git+https://github.com/foo/bar.git")
+	if err == nil {
+		t.Errorf("expected strategy for unprotected branch to fail")
+	}
+}
@@ -0,0 +1,192 @@
+package gitverify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// The errors below are returned, possibly wrapped, by Verify, VerifyLocalState and related functions
+// and can be inspected with errors.As. Hash is the SHA-1 of the commit or tag, Ref is the branch or
+// tag the failure was found through (empty if not known), and Identity is the email of the signer.
+
+type UnsignedCommitError struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+}
+
+func (e *UnsignedCommitError) Error() string {
+	return withRefSuffix(fmt.Sprintf("unsigned commit: %s", e.Hash.String()), e.Ref)
+}
+
+// UnknownIdentityError is returned when the committer, author or tagger is not a maintainer or contributor.
+type UnknownIdentityError struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+	Role     string
+}
+
+func (e *UnknownIdentityError) Error() string {
+	return withRefSuffix(fmt.Sprintf("no %s with email '%s' for %s", e.Role, e.Identity, e.Hash.String()), e.Ref)
+}
+
+// UnknownKeyError is returned when the object is signed with a key that is not associated with the identity.
+type UnknownKeyError struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+	KeyType  SignatureType
+}
+
+func (e *UnknownKeyError) Error() string {
+	return withRefSuffix(fmt.Sprintf("matching %s key not found for '%s' for %s", e.KeyType, e.Identity, e.Hash.String()), e.Ref)
+}
+
+// InvalidSignatureError is returned when the signature is made with a known key, but does not verify
+// or does not satisfy the signature rules.
+type InvalidSignatureError struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+	Err      error
+}
+
+func (e *InvalidSignatureError) Error() string {
+	return withRefSuffix(fmt.Sprintf("invalid signature by '%s' for %s: %s", e.Identity, e.Hash.String(), e.Err), e.Ref)
+}
+
+func (e *InvalidSignatureError) Unwrap() error {
+	return e.Err
+}
+
+type ProtectedBranchViolation struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+	Reason   string
+}
+
+func (e *ProtectedBranchViolation) Error() string {
+	return withRefSuffix(fmt.Sprintf("protected branch violation at %s: %s", e.Hash.String(), e.Reason), e.Ref)
+}
+
+// TagTeleportError is returned when a tag points to something other than what is expected, either from
+// `exemptTags` or the local state. Expected and Actual are hex encoded SHA-1 or SHA-512 digests.
+type TagTeleportError struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+	Expected string
+	Actual   string
+}
+
+func (e *TagTeleportError) Error() string {
+	return withIdentitySuffix(fmt.Sprintf("tag '%s' hash has changed from %s to %s", e.Ref, e.Expected, e.Actual), e.Identity)
+}
+
+// LocalStateRollbackError is returned when a tag or protected branch has been deleted or moved to
+// something that is not a descendant of what is stored in the local state.
+type LocalStateRollbackError struct {
+	Hash         plumbing.Hash
+	Ref          string
+	Identity     string
+	PreviousHash plumbing.Hash
+	Reason       string
+}
+
+func (e *LocalStateRollbackError) Error() string {
+	message := fmt.Sprintf("'%s' %s, was %s", e.Ref, e.Reason, e.PreviousHash.String())
+	if !e.Hash.IsZero() {
+		message += ", now " + e.Hash.String()
+	}
+
+	return withIdentitySuffix(message, e.Identity)
+}
+
+// DisallowedSignatureTypeError is returned when an object is signed with a signature type that is not allowed
+// by the config, e.g. an SSH signature when only GPG signatures are allowed.
+type DisallowedSignatureTypeError struct {
+	Hash          plumbing.Hash
+	Ref           string
+	Identity      string
+	SignatureType SignatureType
+}
+
+func (e *DisallowedSignatureTypeError) Error() string {
+	message := fmt.Sprintf("%s signatures not allowed: %s", strings.ToUpper(string(e.SignatureType)), e.Hash.String())
+	return withRefSuffix(withIdentitySuffix(message, e.Identity), e.Ref)
+}
+
+// TimestampError is returned when a commit or tag timestamp violates one of the timestamp rules.
+type TimestampError struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+	Reason   string
+}
+
+func (e *TimestampError) Error() string {
+	return withRefSuffix(fmt.Sprintf("timestamp violation at %s: %s", e.Hash.String(), e.Reason), e.Ref)
+}
+
+// CommitMessageError is returned when a commit message violates the trailer rules or the message
+// pattern of a protected branch.
+type CommitMessageError struct {
+	Hash     plumbing.Hash
+	Ref      string
+	Identity string
+	Reason   string
+}
+
+func (e *CommitMessageError) Error() string {
+	return withRefSuffix(fmt.Sprintf("commit message violation at %s: %s", e.Hash.String(), e.Reason), e.Ref)
+}
+
+func withRefSuffix(message string, ref string) string {
+	if ref == "" {
+		return message
+	}
+
+	return message + " (" + ref + ")"
+}
+
+func withIdentitySuffix(message string, identity string) string {
+	if identity == "" {
+		return message
+	}
+
+	return message + " by '" + identity + "'"
+}
+
+type refSetter interface {
+	setRef(ref string)
+}
+
+func (e *UnsignedCommitError) setRef(ref string)          { setIfEmpty(&e.Ref, ref) }
+func (e *UnknownIdentityError) setRef(ref string)         { setIfEmpty(&e.Ref, ref) }
+func (e *UnknownKeyError) setRef(ref string)              { setIfEmpty(&e.Ref, ref) }
+func (e *InvalidSignatureError) setRef(ref string)        { setIfEmpty(&e.Ref, ref) }
+func (e *ProtectedBranchViolation) setRef(ref string)     { setIfEmpty(&e.Ref, ref) }
+func (e *TimestampError) setRef(ref string)               { setIfEmpty(&e.Ref, ref) }
+func (e *CommitMessageError) setRef(ref string)           { setIfEmpty(&e.Ref, ref) }
+func (e *DisallowedSignatureTypeError) setRef(ref string) { setIfEmpty(&e.Ref, ref) }
+
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// withRef records the ref on a typed error if it does not already have one.
+func withRef(err error, ref string) error {
+	var e refSetter
+	if errors.As(err, &e) {
+		e.setRef(ref)
+	}
+
+	return err
+}
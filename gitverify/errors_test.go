@@ -0,0 +1,117 @@
+package gitverify
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestUnsignedCommitError(t *testing.T) {
+	commit := &object.Commit{
+		Hash:      plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658"),
+		Committer: object.Signature{Email: "a@example.internal"},
+	}
+
+	commitMetadata := map[plumbing.Hash]*CommitData{
+		commit.Hash: {SignatureType: SignatureTypeNone},
+	}
+
+	repoConfig := &RepoConfig{
+		maintainerOrContributorEmails: map[string]identity{
+			"a@example.internal": {email: "a@example.internal"},
+		},
+	}
+
+	err := validateCommit(commit, commitMetadata, repoConfig, "")
+	err = fmt.Errorf("wrapped: %w", withRef(err, "refs/heads/main"))
+
+	var unsignedCommitError *UnsignedCommitError
+	if !errors.As(err, &unsignedCommitError) {
+		t.Fatalf("expected UnsignedCommitError, got %v", err)
+	}
+
+	if unsignedCommitError.Hash != commit.Hash {
+		t.Errorf("Hash=%s, want %s", unsignedCommitError.Hash, commit.Hash)
+	}
+
+	if unsignedCommitError.Ref != "refs/heads/main" {
+		t.Errorf("Ref=%q, want %q", unsignedCommitError.Ref, "refs/heads/main")
+	}
+
+	if unsignedCommitError.Identity != "a@example.internal" {
+		t.Errorf("Identity=%q, want %q", unsignedCommitError.Identity, "a@example.internal")
+	}
+
+	delete(repoConfig.maintainerOrContributorEmails, "a@example.internal")
+	err = validateCommit(commit, commitMetadata, repoConfig, "")
+
+	var unknownIdentityError *UnknownIdentityError
+	if !errors.As(err, &unknownIdentityError) {
+		t.Fatalf("expected UnknownIdentityError, got %v", err)
+	}
+}
+
+func TestWithRefKeepsExistingRef(t *testing.T) {
+	err := withRef(&ProtectedBranchViolation{Ref: "refs/heads/main"}, "refs/heads/other")
+
+	var violation *ProtectedBranchViolation
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected ProtectedBranchViolation, got %v", err)
+	}
+
+	if violation.Ref != "refs/heads/main" {
+		t.Errorf("Ref=%q, want %q", violation.Ref, "refs/heads/main")
+	}
+}
+
+func TestDisallowedSignatureTypeError(t *testing.T) {
+	hash := plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658")
+	err := validateSSH(hash, "", "", identity{email: "a@example.internal"}, &RepoConfig{})
+	err = withRef(err, "refs/heads/main")
+
+	var disallowed *DisallowedSignatureTypeError
+	if !errors.As(err, &disallowed) {
+		t.Fatalf("expected DisallowedSignatureTypeError, got %v", err)
+	}
+
+	if disallowed.SignatureType != SignatureTypeSSH || disallowed.Hash != hash || disallowed.Identity != "a@example.internal" {
+		t.Errorf("unexpected fields %+v", disallowed)
+	}
+
+	expected := "SSH signatures not allowed: " + hash.String() + " by 'a@example.internal' (refs/heads/main)"
+	if err.Error() != expected {
+		t.Errorf("got %q, want %q", err.Error(), expected)
+	}
+}
+
+func TestErrorMessagesIncludeIdentity(t *testing.T) {
+	hash := plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658")
+	previous := plumbing.NewHash("2f46f2053221c040ce5bcba0239bc09214a37658")
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{
+			&TagTeleportError{Hash: hash, Ref: "refs/tags/v1", Identity: "a@example.internal", Expected: "aa", Actual: "bb"},
+			"tag 'refs/tags/v1' hash has changed from aa to bb by 'a@example.internal'",
+		},
+		{
+			&LocalStateRollbackError{Hash: hash, Ref: "refs/heads/main", Identity: "a@example.internal", PreviousHash: previous, Reason: "is not a descendant of the local state"},
+			"'refs/heads/main' is not a descendant of the local state, was " + previous.String() + ", now " + hash.String() + " by 'a@example.internal'",
+		},
+		{
+			&LocalStateRollbackError{Ref: "refs/tags/v1", PreviousHash: previous, Reason: "has been deleted"},
+			"'refs/tags/v1' has been deleted, was " + previous.String(),
+		},
+	}
+
+	for _, test := range tests {
+		if test.err.Error() != test.expected {
+			t.Errorf("got %q, want %q", test.err.Error(), test.expected)
+		}
+	}
+}
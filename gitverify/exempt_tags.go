@@ -14,7 +14,7 @@ type ExemptTag struct {
 	Hash Digests `json:"hash"`
 }
 
-func ComputeExemptTags(repo *git.Repository, state *gitkit.RepoState, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, useSHA512 bool) ([]ExemptTag, error) {
+func ComputeExemptTags(repo *git.Repository, state gitkit.RepoObjects, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, useSHA512 bool) ([]ExemptTag, error) {
 	tags, err := repo.Tags()
 	if err != nil {
 		return nil, err
@@ -29,7 +29,11 @@ func ComputeExemptTags(repo *git.Repository, state *gitkit.RepoState, gitHashSHA
 			var hashSHA512 []byte = nil
 			var err error
 
-			t, found := state.TagMap[tag.Hash()]
+			t, found, err := lookupTag(state, tag.Hash())
+			if err != nil {
+				return err
+			}
+
 			if found {
 				// annotated tag
 				hashSHA512, err = gitHashSHA512.TagSum(t.Hash)
@@ -0,0 +1,429 @@
+package gitverify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/supply-chain-tools/go-sandbox/githash"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+	"github.com/supply-chain-tools/go-sandbox/hashset"
+)
+
+const stashRef plumbing.ReferenceName = "refs/stash"
+
+type ObjectStatus string
+
+const (
+	// ObjectStatusDangling is an object that is not reachable from any ref and not pointed to by other unreachable objects.
+	ObjectStatusDangling ObjectStatus = "dangling"
+	// ObjectStatusUnreachable is an object that is not reachable from any ref, but is pointed to by another unreachable object.
+	ObjectStatusUnreachable ObjectStatus = "unreachable"
+	// ObjectStatusReflog is an object that is not reachable from any ref, but is still recorded in a reflog, e.g. a stash
+	// entry or a commit that was amended or rebased away.
+	ObjectStatusReflog ObjectStatus = "reflog"
+)
+
+// ForensicsObject describes a commit or annotated tag that is not reachable from any ref. Raw is the
+// base64 encoded object content as stored by git, which is enough to recreate the object after it is pruned.
+type ForensicsObject struct {
+	Type          string        `json:"type"`
+	Hash          Digests       `json:"hash"`
+	Status        ObjectStatus  `json:"status"`
+	Reflogs       []string      `json:"reflogs,omitempty"`
+	Signer        string        `json:"signer"`
+	Author        string        `json:"author,omitempty"`
+	SignatureType SignatureType `json:"signatureType"`
+	Time          time.Time     `json:"time"`
+	Summary       string        `json:"summary"`
+	PolicyError   string        `json:"policyError,omitempty"`
+	SafeToPrune   bool          `json:"safeToPrune"`
+	KeepReason    string        `json:"keepReason,omitempty"`
+	Raw           string        `json:"raw"`
+}
+
+type ForensicsReport struct {
+	RepositoryUri string            `json:"repositoryUri"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	Objects       []ForensicsObject `json:"objects"`
+}
+
+// Forensics finds all commits and annotated tags in the repository that are not reachable from a ref,
+// explains why they would fail verification and whether they can be pruned without losing anything
+// that is still referenced by the config, the local state or a stash entry. Objects that are only recorded in
+// other reflogs, e.g. of HEAD after an amend or a rebase, are reported as such but can be pruned, since they
+// are the previous versions of a ref and not work that only exists there. Trees and blobs are not reported.
+// The store can be nil to not keep objects referenced by the local state.
+func Forensics(ctx context.Context, repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, repoUri string, store LocalStateStore, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) (*ForensicsReport, error) {
+	gitDir, err := gitDirectory(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	reachable := hashset.New[plumbing.Hash]()
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	err = refs.ForEach(func(reference *plumbing.Reference) error {
+		// stash entries are reported through the reflog of refs/stash
+		if reference.Type() == plumbing.HashReference && reference.Name() != stashRef {
+			return markReachable(reference.Hash(), state, reachable)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reflogs, err := readReflogs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inReflog := make(map[plumbing.Hash][]string)
+	for name, hashes := range reflogs {
+		seen := hashset.New[plumbing.Hash]()
+		for _, hash := range hashes {
+			err = markReachable(hash, state, seen)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, hash := range seen.Values() {
+			if !reachable.Contains(hash) {
+				inReflog[hash] = append(inReflog[hash], name)
+			}
+		}
+	}
+
+	unreachable := hashset.New[plumbing.Hash]()
+	for _, hash := range append(state.CommitHashes(), state.TagHashes()...) {
+		if !reachable.Contains(hash) {
+			unreachable.Add(hash)
+		}
+	}
+
+	pointedTo := hashset.New[plumbing.Hash]()
+	for _, hash := range unreachable.Values() {
+		commit, found, err := lookupCommit(state, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			for _, parent := range commit.ParentHashes {
+				pointedTo.Add(parent)
+			}
+			continue
+		}
+
+		tag, found, err := lookupTag(state, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			pointedTo.Add(tag.Target)
+		}
+	}
+
+	keep, err := referencedByConfigOrLocalState(ctx, repoConfig, store)
+	if err != nil {
+		return nil, err
+	}
+
+	// stash commits created with 'git stash -u' have three parents, report that as their policy error
+	commitMetadata, err := buildCommitMetadata(state, repoConfig, gitHashSHA1, gitHashSHA512, now, false)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]ForensicsObject, 0, unreachable.Size())
+	for _, hash := range sortedHashes(unreachable.Values()) {
+		o := ForensicsObject{
+			Status: ObjectStatusDangling,
+		}
+
+		if pointedTo.Contains(hash) {
+			o.Status = ObjectStatusUnreachable
+		}
+
+		reflogNames, found := inReflog[hash]
+		if found {
+			sort.Strings(reflogNames)
+			o.Status = ObjectStatusReflog
+			o.Reflogs = reflogNames
+		}
+
+		var policyErr error
+		var sha512Sum []byte
+		commit, isCommit, err := lookupCommit(state, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if isCommit {
+			o.Type = "commit"
+			o.Signer = commit.Committer.Email
+			o.Author = commit.Author.Email
+			o.Time = commit.Committer.When
+			o.Summary = summary(commit.Message)
+			o.SignatureType, err = inferSignatureType(commit.PGPSignature)
+			if err != nil {
+				return nil, err
+			}
+
+			if commitMetadata[hash].Ignore {
+				o.PolicyError = "ignored, not a descendant of 'after'"
+			} else {
+				policyErr = validateCommit(commit, commitMetadata, repoConfig, gitDir)
+			}
+
+			sha512Sum, err = gitHashSHA512.CommitSum(hash)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			tag, err := state.Tag(hash)
+			if err != nil {
+				return nil, err
+			}
+
+			o.Type = "tag"
+			o.Signer = tag.Tagger.Email
+			o.Time = tag.Tagger.When
+			o.Summary = summary(tag.Message)
+			o.SignatureType, err = inferSignatureType(tag.PGPSignature)
+			if err != nil {
+				return nil, err
+			}
+
+			policyErr = validateTag(plumbing.NewHashReference(plumbing.ReferenceName(tagRef(tag)), hash), state, repoConfig, gitHashSHA1, gitHashSHA512, now)
+
+			sha512Sum, err = gitHashSHA512.TagSum(hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if policyErr != nil {
+			o.PolicyError = policyErr.Error()
+		}
+
+		hexSHA1 := hash.String()
+		hexSHA512 := hex.EncodeToString(sha512Sum)
+		o.Hash = Digests{
+			SHA1:   &hexSHA1,
+			SHA512: &hexSHA512,
+		}
+
+		o.Raw, err = rawObject(repo, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		keepReason, found := keep[hash]
+		switch {
+		case found:
+			o.KeepReason = keepReason
+		case inStash(o.Reflogs):
+			o.KeepReason = "recorded in " + stashRef.String() + ", might contain work that is not committed elsewhere"
+		default:
+			o.SafeToPrune = true
+		}
+
+		objects = append(objects, o)
+	}
+
+	return &ForensicsReport{
+		RepositoryUri: repoUri,
+		CreatedAt:     now.UTC(),
+		Objects:       objects,
+	}, nil
+}
+
+func inStash(reflogs []string) bool {
+	for _, name := range reflogs {
+		if name == stashRef.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markReachable marks the commit or tag and everything reachable from it.
+func markReachable(hash plumbing.Hash, state gitkit.RepoObjects, reachable hashset.Set[plumbing.Hash]) error {
+	queue := []plumbing.Hash{hash}
+	for len(queue) > 0 {
+		current := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		if reachable.Contains(current) {
+			continue
+		}
+
+		commit, found, err := lookupCommit(state, current)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			reachable.Add(current)
+			queue = append(queue, commit.ParentHashes...)
+			continue
+		}
+
+		tag, found, err := lookupTag(state, current)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			reachable.Add(current)
+			queue = append(queue, tag.Target)
+		}
+	}
+
+	return nil
+}
+
+// readReflogs returns the hashes recorded in each reflog under <gitDir>/logs, keyed by ref name.
+func readReflogs(gitDir string) (map[string][]plumbing.Hash, error) {
+	result := make(map[string][]plumbing.Hash)
+	logsDir := filepath.Join(gitDir, "logs")
+
+	err := filepath.WalkDir(logsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(logsDir, path)
+		if err != nil {
+			return err
+		}
+
+		hashes, err := readReflog(path)
+		if err != nil {
+			return fmt.Errorf("failed to read reflog %s: %w", name, err)
+		}
+
+		result[filepath.ToSlash(name)] = hashes
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// readReflog parses lines of the form '<old> <new> <name> <<email>> <time> <tz>\t<message>'.
+func readReflog(path string) ([]plumbing.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make([]plumbing.Hash, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		for _, field := range fields[:2] {
+			if plumbing.IsHash(field) && !plumbing.NewHash(field).IsZero() {
+				hashes = append(hashes, plumbing.NewHash(field))
+			}
+		}
+	}
+
+	return hashes, scanner.Err()
+}
+
+// referencedByConfigOrLocalState returns the reason to keep objects that are referenced by `after`,
+// `exemptTags` or the local state, since removing them would make verification fail.
+func referencedByConfigOrLocalState(ctx context.Context, repoConfig *RepoConfig, store LocalStateStore) (map[plumbing.Hash]string, error) {
+	keep := make(map[plumbing.Hash]string)
+
+	for _, hash := range repoConfig.afterSHA1.Values() {
+		keep[hash] = "referenced by 'after' in the config"
+	}
+
+	for ref, hash := range repoConfig.exemptedTags {
+		keep[plumbing.NewHash(hash)] = fmt.Sprintf("referenced by 'exemptTags' (%s) in the config", ref)
+	}
+
+	if store == nil {
+		return keep, nil
+	}
+
+	localState, err := store.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local state: %w", err)
+	}
+
+	if localState == nil {
+		return keep, nil
+	}
+
+	for _, entry := range append(localState.Tags, localState.Branches...) {
+		if entry.Hash.SHA1 != nil {
+			keep[plumbing.NewHash(*entry.Hash.SHA1)] = fmt.Sprintf("referenced by '%s' in the local state", entry.Ref)
+		}
+	}
+
+	return keep, nil
+}
+
+func rawObject(repo *git.Repository, hash plumbing.Hash) (string, error) {
+	obj, err := repo.Storer.EncodedObject(plumbing.AnyObject, hash)
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := obj.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func summary(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return line
+}
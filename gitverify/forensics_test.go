@@ -0,0 +1,246 @@
+package gitverify
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/supply-chain-tools/go-sandbox/githash"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+func TestReadReflogs(t *testing.T) {
+	gitDir := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(gitDir, "logs", "refs"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stash := "0000000000000000000000000000000000000000 1f46f2053221c040ce5bcba0239bc09214a37658 A <a@example.internal> 1717243200 +0000\tWIP on main\n" +
+		"1f46f2053221c040ce5bcba0239bc09214a37658 d453471f46f2053221c040ce5bcba0239bc09214 A <a@example.internal> 1717243260 +0000\tWIP on main\n"
+	err = os.WriteFile(filepath.Join(gitDir, "logs", "refs", "stash"), []byte(stash), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reflogs, err := readReflogs(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := reflogs["refs/stash"]
+	expected := []plumbing.Hash{
+		plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658"),
+		plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658"),
+		plumbing.NewHash("d453471f46f2053221c040ce5bcba0239bc09214"),
+	}
+
+	if len(hashes) != len(expected) {
+		t.Fatalf("got %v, want %v", hashes, expected)
+	}
+
+	for i := range expected {
+		if hashes[i] != expected[i] {
+			t.Errorf("hashes[%d]=%s, want %s", i, hashes[i], expected[i])
+		}
+	}
+
+	reflogs, err = readReflogs(t.TempDir())
+	if err != nil || len(reflogs) != 0 {
+		t.Errorf("expected no reflogs, got %v, %v", reflogs, err)
+	}
+}
+
+func TestForensics(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := commitEmpty(t, repo, "first")
+	main := commitEmpty(t, repo, "second")
+
+	// d1 <- d2 are left behind when master is reset
+	d1 := commitEmpty(t, repo, "dangling 1")
+	d2 := commitEmpty(t, repo, "dangling 2")
+	setRef(t, repo, "refs/heads/master", main)
+
+	// amended is only recorded in the reflog of HEAD
+	amended := commitEmpty(t, repo, "amended")
+	setRef(t, repo, "refs/heads/master", main)
+
+	// 'git stash -u' records HEAD, the index and the untracked files as the three parents of the stash commit
+	t.Setenv("GIT_AUTHOR_NAME", "A")
+	t.Setenv("GIT_AUTHOR_EMAIL", "a@example.internal")
+	t.Setenv("GIT_COMMITTER_NAME", "A")
+	t.Setenv("GIT_COMMITTER_EMAIL", "a@example.internal")
+
+	err = os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("work in progress\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = exec.Command("git", "-C", dir, "stash", "push", "--quiet", "--include-untracked").Run()
+	if err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+
+	stash, stashIndex, stashUntracked := gitRevParse(t, dir, "refs/stash"), gitRevParse(t, dir, "refs/stash^2"), gitRevParse(t, dir, "refs/stash^3")
+
+	tag, err := repo.CreateTag("old", after, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "A", Email: "a@example.internal", When: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		Message: "old",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Storer.RemoveReference(tag.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reflogLine := func(old plumbing.Hash, new plumbing.Hash, message string) string {
+		return old.String() + " " + new.String() + " A <a@example.internal> 1717243200 +0000\t" + message + "\n"
+	}
+
+	writeReflog := func(name string, content string) {
+		path := filepath.Join(dir, ".git", "logs", filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeReflog("HEAD", reflogLine(plumbing.ZeroHash, after, "commit (initial): first")+
+		reflogLine(after, main, "commit: second")+
+		reflogLine(main, amended, "commit: amended")+
+		reflogLine(amended, main, "reset: moving to HEAD~1"))
+	writeReflog("refs/heads/master", reflogLine(plumbing.ZeroHash, after, "commit (initial): first")+
+		reflogLine(after, main, "commit: second"))
+	writeReflog("refs/stash", reflogLine(plumbing.ZeroHash, stash, "WIP on master"))
+
+	config := `
+{
+  "_type": "https://supply-chain-tools.github.io/schemas/gitverify/v0.1",
+  "identities": [{"email": "a@example.internal"}],
+  "maintainers": ["a@example.internal"],
+  "rules": {"requireSignedTags": false},
+  "repositories": [{
+    "uri": "git+https://github.com/foo/bar.git",
+    "after": [{"sha1": "` + after.String() + `"}],
+    "exemptTags": [{"ref": "refs/tags/old", "hash": {"sha1": "` + tag.Hash().String() + `"}}]
+  }]
+}
+`
+	runnerConfig := &Config{}
+	err = json.Unmarshal([]byte(config), runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseConfig(runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoConfig, err := LoadRepoConfig(parsed, "git+https://github.com/foo/bar.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// d1 was the tip of a protected branch when the local state was saved
+	d1Hex := d1.String()
+	store := &memoryLocalStateStore{localState: &LocalState{Branches: []ExemptTag{{Ref: "refs/heads/release", Hash: Digests{SHA1: &d1Hex}}}}}
+
+	report, err := Forensics(context.Background(), repo, state, repoConfig, "git+https://github.com/foo/bar.git", store,
+		githash.NewGitHashFromRepoStateFunc(state, sha1.New), githash.NewGitHashFromRepoStateFunc(state, sha512.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type expectation struct {
+		objectType  string
+		status      ObjectStatus
+		reflogs     string
+		safeToPrune bool
+		keepReason  string
+	}
+
+	expected := map[plumbing.Hash]expectation{
+		d1:             {"commit", ObjectStatusUnreachable, "", false, "local state"},
+		d2:             {"commit", ObjectStatusDangling, "", true, ""},
+		amended:        {"commit", ObjectStatusReflog, "HEAD", true, ""},
+		stash:          {"commit", ObjectStatusReflog, "refs/stash", false, "refs/stash"},
+		stashIndex:     {"commit", ObjectStatusReflog, "refs/stash", false, "refs/stash"},
+		stashUntracked: {"commit", ObjectStatusReflog, "refs/stash", false, "refs/stash"},
+		tag.Hash():     {"tag", ObjectStatusDangling, "", false, "exemptTags"},
+	}
+
+	if len(report.Objects) != len(expected) {
+		t.Fatalf("got %d objects, want %d: %+v", len(report.Objects), len(expected), report.Objects)
+	}
+
+	for _, o := range report.Objects {
+		hash := plumbing.NewHash(*o.Hash.SHA1)
+		e, found := expected[hash]
+		if !found {
+			t.Errorf("unexpected object %s (%s)", hash, o.Summary)
+			continue
+		}
+
+		if o.Type != e.objectType || o.Status != e.status || strings.Join(o.Reflogs, ",") != e.reflogs || o.SafeToPrune != e.safeToPrune {
+			t.Errorf("%s (%s): got type %s, status %s, reflogs %v, safe to prune %v", hash, o.Summary, o.Type, o.Status, o.Reflogs, o.SafeToPrune)
+		}
+
+		if !strings.Contains(o.KeepReason, e.keepReason) || (e.keepReason == "") != (o.KeepReason == "") {
+			t.Errorf("%s (%s): got keep reason %q, want it to mention %q", hash, o.Summary, o.KeepReason, e.keepReason)
+		}
+
+		expectedPolicyError := "unsigned commit"
+		if hash == stash {
+			expectedPolicyError = "up to two parents are allowed"
+		}
+
+		if o.Type == "commit" && !strings.Contains(o.PolicyError, expectedPolicyError) {
+			t.Errorf("%s (%s): expected a policy error containing %q, got %q", hash, o.Summary, expectedPolicyError, o.PolicyError)
+		}
+
+		if o.Hash.SHA512 == nil || len(*o.Hash.SHA512) != 128 || o.Raw == "" {
+			t.Errorf("%s (%s): expected the SHA-512 and raw object", hash, o.Summary)
+		}
+	}
+}
+
+func gitRevParse(t *testing.T, dir string, revision string) plumbing.Hash {
+	t.Helper()
+
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", revision).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return plumbing.NewHash(strings.TrimSpace(string(out)))
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/supply-chain-tools/go-sandbox/gitkit"
 )
 
@@ -31,24 +32,45 @@ type CommitData struct {
 	Ignore                          bool
 	VerifiedToNotHaveContentChanges bool
 	SignatureVerified               bool
+	// OnProtectedBranch is set for commits reachable from a protected branch, the scope of the trailer rules by default.
+	OnProtectedBranch bool
+
+	parentsError   error
+	timestampError error
 }
 
 func InferForgeOrgAndRepo(repo *git.Repository) (forge string, org string, repoName string) {
-	remote, err := repo.Remote("origin")
+	forge, org, repoName, err := ForgeOrgAndRepo(repo)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	return forge, org, repoName
+}
+
+// ForgeOrgAndRepo infers the forge, organization and repository name from the origin URL. Only GitHub is
+// supported, an error is returned for other hosts.
+func ForgeOrgAndRepo(repo *git.Repository) (forge string, org string, repoName string, err error) {
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", "", "", err
+	}
 	urls := remote.Config().URLs
 	if len(urls) != 1 {
-		log.Fatal("Expected exactly one remote url")
+		return "", "", "", fmt.Errorf("expected exactly one remote url, got %d", len(urls))
+	}
+
+	host := remoteHost(urls[0])
+	if host != gitHubForgeId {
+		return "", "", "", fmt.Errorf("unsupported forge '%s' for remote '%s', only %s is supported", host, urls[0], gitHubForgeId)
 	}
 
 	org, repoName, err = getGitHubOrgRepo(urls[0])
 	if err != nil {
-		log.Fatal(err)
+		return "", "", "", err
 	}
 
-	return gitHubForgeId, org, repoName
+	return gitHubForgeId, org, repoName, nil
 }
 
 func getGitHubOrgRepo(url string) (org string, repoName string, err error) {
@@ -69,7 +91,7 @@ func getGitHubOrgRepo(url string) (org string, repoName string, err error) {
 	suffix = strings.TrimSuffix(suffix, ".git")
 	parts := strings.Split(suffix, "/")
 
-	if len(parts) != 2 {
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 		return "", "", fmt.Errorf("unexpected URL format: %s", url)
 	}
 
@@ -79,7 +101,28 @@ func getGitHubOrgRepo(url string) (org string, repoName string, err error) {
 	return org, repoName, nil
 }
 
-func ignoreCommitAndParents(commit *object.Commit, commitMap map[plumbing.Hash]*CommitData, state *gitkit.RepoState) error {
+// remoteHost returns the host of an https, ssh or scp-like URL, or the URL itself if the host can't be found.
+func remoteHost(url string) string {
+	rest := url
+	if _, afterScheme, found := strings.Cut(url, "://"); found {
+		rest = afterScheme
+	} else if beforeColon, _, found := strings.Cut(url, ":"); found {
+		rest = beforeColon
+	}
+
+	rest, _, _ = strings.Cut(rest, "/")
+	if _, afterUser, found := strings.Cut(rest, "@"); found {
+		rest = afterUser
+	}
+	host, _, _ := strings.Cut(rest, ":")
+
+	if host == "" {
+		return url
+	}
+	return strings.ToLower(host)
+}
+
+func ignoreCommitAndParents(commit *object.Commit, commitMap map[plumbing.Hash]*CommitData, state gitkit.RepoObjects) error {
 	queue := []*object.Commit{commit}
 
 	for {
@@ -96,9 +139,9 @@ func ignoreCommitAndParents(commit *object.Commit, commitMap map[plumbing.Hash]*
 		}
 
 		for _, parentHash := range current.ParentHashes {
-			parent, found := state.CommitMap[parentHash]
-			if !found {
-				return fmt.Errorf("failed to get parent commit %s", parentHash)
+			parent, err := state.Commit(parentHash)
+			if err != nil {
+				return fmt.Errorf("failed to get parent commit %s: %w", parentHash, err)
 			}
 
 			queue = append(queue, parent)
@@ -130,7 +173,16 @@ func inferSignatureType(signature string) (SignatureType, error) {
 	}
 }
 
-func gitDiff(a string, b string) (string, error) {
+func gitDirectory(repo *git.Repository) (string, error) {
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", fmt.Errorf("repository is not stored on the filesystem")
+	}
+
+	return storage.Filesystem().Root(), nil
+}
+
+func gitDiff(gitDir string, a string, b string) (string, error) {
 	match, err := regexp.MatchString(hexSHA1Regex, a)
 	if err != nil {
 		return "", err
@@ -148,7 +200,7 @@ func gitDiff(a string, b string) (string, error) {
 	}
 
 	var stdout bytes.Buffer
-	command := []string{"git", "diff", a + "..." + b}
+	command := []string{"git", "--git-dir", gitDir, "diff", a + "..." + b}
 	cmd := exec.Command(command[0], command[1:]...)
 	cmd.Stdout = &stdout
 	err = cmd.Run()
@@ -160,14 +212,14 @@ func gitDiff(a string, b string) (string, error) {
 	return stdout.String(), err
 }
 
-func verifyMergeCommitNoContentChanges(commit *object.Commit) error {
+func verifyMergeCommitNoContentChanges(gitDir string, commit *object.Commit) error {
 	if len(commit.ParentHashes) != 2 {
 		return fmt.Errorf("expected 2 parent hashes, got %d", len(commit.ParentHashes))
 	}
 
 	a := commit.ParentHashes[0].String()
 	b := commit.ParentHashes[1].String()
-	treeHash, err := gitMergeTree(a, b)
+	treeHash, err := gitMergeTree(gitDir, a, b)
 	if err != nil {
 		return err
 	}
@@ -179,7 +231,7 @@ func verifyMergeCommitNoContentChanges(commit *object.Commit) error {
 	return nil
 }
 
-func gitMergeTree(a string, b string) (string, error) {
+func gitMergeTree(gitDir string, a string, b string) (string, error) {
 	match, err := regexp.MatchString(hexSHA1Regex, a)
 	if err != nil {
 		return "", err
@@ -197,7 +249,7 @@ func gitMergeTree(a string, b string) (string, error) {
 	}
 
 	var buffer bytes.Buffer
-	command := []string{"git", "merge-tree", a, b}
+	command := []string{"git", "--git-dir", gitDir, "merge-tree", a, b}
 	cmd := exec.Command(command[0], command[1:]...)
 	cmd.Stdout = &buffer
 	cmd.Stdout = &buffer
@@ -224,7 +276,7 @@ func gitMergeTree(a string, b string) (string, error) {
 	return result, nil
 }
 
-func gitMergeBase(a string, b string) (string, error) {
+func gitMergeBase(gitDir string, a string, b string) (string, error) {
 	match, err := regexp.MatchString(hexSHA1Regex, a)
 	if err != nil {
 		return "", err
@@ -242,7 +294,7 @@ func gitMergeBase(a string, b string) (string, error) {
 	}
 
 	var buffer bytes.Buffer
-	command := []string{"git", "merge-base", a, b}
+	command := []string{"git", "--git-dir", gitDir, "merge-base", a, b}
 	cmd := exec.Command(command[0], command[1:]...)
 	cmd.Stdout = &buffer
 	cmd.Stdout = &buffer
@@ -268,3 +320,29 @@ func gitMergeBase(a string, b string) (string, error) {
 
 	return result, nil
 }
+
+// lookupTag returns the annotated tag for the hash, found is false for lightweight tags.
+func lookupTag(state gitkit.RepoObjects, hash plumbing.Hash) (*object.Tag, bool, error) {
+	tag, err := state.Tag(hash)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return tag, true, nil
+}
+
+// lookupCommit returns the commit for the hash, found is false if the hash is not a commit.
+func lookupCommit(state gitkit.RepoObjects, hash plumbing.Hash) (*object.Commit, bool, error) {
+	commit, err := state.Commit(hash)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return commit, true, nil
+}
@@ -0,0 +1,48 @@
+package gitverify
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestForgeOrgAndRepo(t *testing.T) {
+	tests := []struct {
+		url   string
+		org   string
+		repo  string
+		valid bool
+	}{
+		{"https://github.com/org/repo.git", "org", "repo", true},
+		{"git@github.com:org/repo.git", "org", "repo", true},
+		{"https://gitlab.com/org/repo.git", "", "", false},
+		{"git@gitlab.com:org/repo.git", "", "", false},
+		{"ssh://git@github.example.com/org/repo.git", "", "", false},
+		{"https://github.com/group/subgroup/repo.git", "", "", false},
+		{"https://github.com//repo.git", "", "", false},
+	}
+
+	for _, test := range tests {
+		repo, err := git.Init(memory.NewStorage(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{test.url}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		forge, org, repoName, err := ForgeOrgAndRepo(repo)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v", test.url, err)
+			continue
+		}
+
+		if test.valid && (forge != gitHubForgeId || org != test.org || repoName != test.repo) {
+			t.Errorf("%s: got %s/%s/%s", test.url, forge, org, repoName)
+		}
+	}
+}
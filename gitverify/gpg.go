@@ -8,11 +8,19 @@ import (
 
 func validateIdentityGPGCommit(commit *object.Commit, id identity, config *RepoConfig) error {
 	if !config.allowGPGSignatures {
-		return fmt.Errorf("GPG signatures not allowed: %s", commit.Hash.String())
+		return &DisallowedSignatureTypeError{
+			Hash:          commit.Hash,
+			Identity:      id.email,
+			SignatureType: SignatureTypeGPG,
+		}
 	}
 
 	if len(id.gpgPublicKeys) < 1 {
-		return fmt.Errorf("GPG public key not found for commit %s", commit.Hash.String())
+		return &UnknownKeyError{
+			Hash:     commit.Hash,
+			Identity: id.email,
+			KeyType:  SignatureTypeGPG,
+		}
 	}
 
 	if len(id.gpgPublicKeys) > 1 {
@@ -25,7 +33,11 @@ func validateIdentityGPGCommit(commit *object.Commit, id identity, config *RepoC
 func validateGPGCommit(commit *object.Commit, key string) error {
 	entity, err := commit.Verify(key)
 	if err != nil {
-		return fmt.Errorf("failed to verify commit %s: %w", commit.Hash.String(), err)
+		return &InvalidSignatureError{
+			Hash:     commit.Hash,
+			Identity: commit.Committer.Email,
+			Err:      err,
+		}
 	}
 
 	entityEmails := hashset.New[string]()
@@ -34,7 +46,11 @@ func validateGPGCommit(commit *object.Commit, key string) error {
 	}
 
 	if !entityEmails.Contains(commit.Committer.Email) {
-		return fmt.Errorf("GPG key does not match committer email '%s' for commit %s", commit.Committer.Email, commit.Hash.String())
+		return &UnknownKeyError{
+			Hash:     commit.Hash,
+			Identity: commit.Committer.Email,
+			KeyType:  SignatureTypeGPG,
+		}
 	}
 
 	return nil
@@ -42,11 +58,21 @@ func validateGPGCommit(commit *object.Commit, key string) error {
 
 func validateIdentityGPGTag(tag *object.Tag, id identity, config *RepoConfig) error {
 	if !config.allowGPGSignatures {
-		return fmt.Errorf("GPG signatures not allowed: %s", tag.Name)
+		return &DisallowedSignatureTypeError{
+			Hash:          tag.Hash,
+			Ref:           tagRef(tag),
+			Identity:      id.email,
+			SignatureType: SignatureTypeGPG,
+		}
 	}
 
 	if len(id.gpgPublicKeys) < 1 {
-		return fmt.Errorf("GPG public key not found for commit %s", tag.Name)
+		return &UnknownKeyError{
+			Hash:     tag.Hash,
+			Ref:      tagRef(tag),
+			Identity: id.email,
+			KeyType:  SignatureTypeGPG,
+		}
 	}
 
 	if len(id.gpgPublicKeys) > 1 {
@@ -59,7 +85,12 @@ func validateIdentityGPGTag(tag *object.Tag, id identity, config *RepoConfig) er
 func validateGPGTag(tag *object.Tag, key string) error {
 	entity, err := tag.Verify(key)
 	if err != nil {
-		return fmt.Errorf("failed to verify tag %s: %w", tag.Hash.String(), err)
+		return &InvalidSignatureError{
+			Hash:     tag.Hash,
+			Ref:      tagRef(tag),
+			Identity: tag.Tagger.Email,
+			Err:      err,
+		}
 	}
 
 	entityEmails := hashset.New[string]()
@@ -68,8 +99,17 @@ func validateGPGTag(tag *object.Tag, key string) error {
 	}
 
 	if !entityEmails.Contains(tag.Tagger.Email) {
-		return fmt.Errorf("GPG key does not match tagger email '%s' for tag %s", tag.Tagger.Email, tag.Hash.String())
+		return &UnknownKeyError{
+			Hash:     tag.Hash,
+			Ref:      tagRef(tag),
+			Identity: tag.Tagger.Email,
+			KeyType:  SignatureTypeGPG,
+		}
 	}
 
 	return nil
 }
+
+func tagRef(tag *object.Tag) string {
+	return "refs/tags/" + tag.Name
+}
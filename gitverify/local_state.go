@@ -1,9 +1,8 @@
 package gitverify
 
 import (
+	"context"
 	"encoding/hex"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -29,67 +28,73 @@ func GetLocalStatePath(forge string, org string, repoName string) (string, error
 	return filepath.Join(homeDirectory, ".config", "gitverify", forge, org, repoName, "local.json"), nil
 }
 
-func SaveLocalState(repo *git.Repository, state *gitkit.RepoState, repoConfig *RepoConfig, repoUri string, localPath string, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) error {
-	// TODO time of check, time of save issues
-	tags, err := ComputeExemptTags(repo, state, gitHashSHA1, gitHashSHA512, true)
-	if err != nil {
-		return err
-	}
+// LoadLocalState reads the local state, it returns nil if no local state has been saved yet.
+func LoadLocalState(localPath string) (*LocalState, error) {
+	return NewFileLocalStateStore(localPath).Load(context.Background())
+}
 
-	protectedBranches, err := computeProtectedBranches(repo, repoConfig, gitHashSHA1, gitHashSHA512)
+func SaveLocalState(repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, repoUri string, localPath string, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) error {
+	localState, err := computeLocalState(repo, state, repoConfig, gitHashSHA1, gitHashSHA512)
 	if err != nil {
 		return err
 	}
 
-	localState := &LocalState{
-		Tags:     tags,
-		Branches: protectedBranches,
-	}
+	return NewFileLocalStateStore(localPath).Save(context.Background(), localState)
+}
+
+// BranchUpdate is a protected branch that has moved forward since the local state was saved, the changes can
+// be reviewed with 'git log -p --full-diff <From>...<To>'.
+type BranchUpdate struct {
+	Ref  string
+	From plumbing.Hash
+	To   plumbing.Hash
+}
 
-	data, err := json.Marshal(localState)
+func VerifyLocalState(repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, repoUri string, localPath string, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) ([]BranchUpdate, error) {
+	localState, err := LoadLocalState(localPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = os.MkdirAll(filepath.Dir(localPath), defaultDirectoryPermission)
+	return verifyLocalState(repo, state, repoConfig, localState, gitHashSHA1, gitHashSHA512)
+}
+
+func computeLocalState(repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) (*LocalState, error) {
+	// TODO time of check, time of save issues
+	tags, err := ComputeExemptTags(repo, state, gitHashSHA1, gitHashSHA512, true)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = os.WriteFile(localPath, data, defaultFilePermission)
+	protectedBranches, err := computeProtectedBranches(repo, repoConfig, gitHashSHA1, gitHashSHA512)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &LocalState{
+		Tags:     tags,
+		Branches: protectedBranches,
+	}, nil
 }
 
-func VerifyLocalState(repo *git.Repository, state *gitkit.RepoState, repoConfig *RepoConfig, repoUri string, localPath string, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) error {
-	data, err := os.ReadFile(localPath)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
-		} else {
-			return err
-		}
-	}
-
-	localState := LocalState{}
-	err = json.Unmarshal(data, &localState)
-	if err != nil {
-		return err
+// verifyLocalState checks that no tags have moved and that protected branches have only moved forward
+// compared to the stored local state, and returns the protected branches that moved. A nil local state is the
+// first run and always passes.
+func verifyLocalState(repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, localState *LocalState, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) ([]BranchUpdate, error) {
+	if localState == nil {
+		return nil, nil
 	}
 
 	newTags, err := ComputeExemptTags(repo, state, gitHashSHA1, gitHashSHA512, true)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	newTagMap := make(map[string]ExemptTag)
 	for _, tag := range newTags {
 		_, found := newTagMap[tag.Ref]
 		if found {
-			return fmt.Errorf("duplicate tag '%s'", tag.Ref)
+			return nil, fmt.Errorf("duplicate tag '%s'", tag.Ref)
 		}
 
 		newTagMap[tag.Ref] = tag
@@ -99,54 +104,71 @@ func VerifyLocalState(repo *git.Repository, state *gitkit.RepoState, repoConfig
 		newTag, found := newTagMap[tag.Ref]
 		if found {
 			if newTag.Hash.SHA1 == nil || tag.Hash.SHA1 == nil {
-				return fmt.Errorf("tag SHA-1 hashes must be set")
+				return nil, fmt.Errorf("tag SHA-1 hashes must be set")
 			}
 
 			if newTag.Hash.SHA512 == nil || tag.Hash.SHA512 == nil {
-				return fmt.Errorf("tag SHA-512 hashes must be set")
+				return nil, fmt.Errorf("tag SHA-512 hashes must be set")
 			}
 
 			if *newTag.Hash.SHA1 != *tag.Hash.SHA1 {
-				return fmt.Errorf("tag '%s' hash has changed from %s to %s", tag.Ref, *tag.Hash.SHA1, *newTag.Hash.SHA1)
+				return nil, &TagTeleportError{
+					Hash:     plumbing.NewHash(*newTag.Hash.SHA1),
+					Ref:      tag.Ref,
+					Identity: taggerEmail(state, plumbing.NewHash(*newTag.Hash.SHA1)),
+					Expected: *tag.Hash.SHA1,
+					Actual:   *newTag.Hash.SHA1,
+				}
 			}
 
 			if *newTag.Hash.SHA512 != *tag.Hash.SHA512 {
-				return fmt.Errorf("tag '%s' SHA-512 hash has changed from %s to %s", tag.Ref, *tag.Hash.SHA512, *newTag.Hash.SHA512)
+				return nil, &TagTeleportError{
+					Hash:     plumbing.NewHash(*newTag.Hash.SHA1),
+					Ref:      tag.Ref,
+					Identity: taggerEmail(state, plumbing.NewHash(*newTag.Hash.SHA1)),
+					Expected: *tag.Hash.SHA512,
+					Actual:   *newTag.Hash.SHA512,
+				}
 			}
 		} else {
-			return fmt.Errorf("tag '%s' has been deleted, was %s", tag.Ref, *tag.Hash.SHA1)
+			return nil, &LocalStateRollbackError{
+				Ref:          tag.Ref,
+				PreviousHash: plumbing.NewHash(*tag.Hash.SHA1),
+				Reason:       "has been deleted",
+			}
 		}
 	}
 
 	newProtectedBranches, err := computeProtectedBranches(repo, repoConfig, gitHashSHA1, gitHashSHA512)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	newProtectedBranchesMap := make(map[string]ExemptTag)
 	for _, branch := range newProtectedBranches {
 		_, found := newProtectedBranchesMap[branch.Ref]
 		if found {
-			return fmt.Errorf("duplicate branch '%s'", branch.Ref)
+			return nil, fmt.Errorf("duplicate branch '%s'", branch.Ref)
 		}
 
 		newProtectedBranchesMap[branch.Ref] = branch
 	}
 
+	updates := make([]BranchUpdate, 0)
 	for _, branch := range localState.Branches {
 		newBranch, found := newProtectedBranchesMap[branch.Ref]
 		if found {
 			if newBranch.Hash.SHA1 == nil || branch.Hash.SHA1 == nil {
-				return fmt.Errorf("branch hashes must be set")
+				return nil, fmt.Errorf("branch hashes must be set")
 			}
 
 			if newBranch.Hash.SHA512 == nil || branch.Hash.SHA512 == nil {
-				return fmt.Errorf("branch SHA-512 hashes must be set")
+				return nil, fmt.Errorf("branch SHA-512 hashes must be set")
 			}
 
-			c, found := state.CommitMap[plumbing.NewHash(*newBranch.Hash.SHA1)]
-			if !found {
-				return fmt.Errorf("target commit '%s' not found for %s", *newBranch.Hash.SHA1, branch.Ref)
+			c, err := state.Commit(plumbing.NewHash(*newBranch.Hash.SHA1))
+			if err != nil {
+				return nil, fmt.Errorf("target commit '%s' not found for %s: %w", *newBranch.Hash.SHA1, branch.Ref, err)
 			}
 
 			visited := hashset.New[plumbing.Hash]()
@@ -155,7 +177,13 @@ func VerifyLocalState(repo *git.Repository, state *gitkit.RepoState, repoConfig
 
 			for {
 				if len(queue) == 0 {
-					return fmt.Errorf("new state of %s is not a descendant of the local state", branch.Ref)
+					return nil, &LocalStateRollbackError{
+						Hash:         c.Hash,
+						Ref:          branch.Ref,
+						Identity:     c.Committer.Email,
+						PreviousHash: plumbing.NewHash(*branch.Hash.SHA1),
+						Reason:       "is not a descendant of the local state",
+					}
 				}
 
 				current := queue[0]
@@ -164,11 +192,11 @@ func VerifyLocalState(repo *git.Repository, state *gitkit.RepoState, repoConfig
 				if current.Hash.String() == *branch.Hash.SHA1 {
 					hashSHA512, err := gitHashSHA512.CommitSum(current.Hash)
 					if err != nil {
-						return err
+						return nil, err
 					}
 
 					if hex.EncodeToString(hashSHA512) != *branch.Hash.SHA512 {
-						return fmt.Errorf("SHA-512 does not match SHA-1 for %s", branch.Ref)
+						return nil, fmt.Errorf("SHA-512 does not match SHA-1 for %s", branch.Ref)
 					}
 
 					break
@@ -181,9 +209,9 @@ func VerifyLocalState(repo *git.Repository, state *gitkit.RepoState, repoConfig
 					// sufficient.
 					parentHash := current.ParentHashes[0]
 					if !visited.Contains(parentHash) {
-						parent, found := state.CommitMap[parentHash]
-						if !found {
-							return fmt.Errorf("target parent hash not found: %s", parentHash)
+						parent, err := state.Commit(parentHash)
+						if err != nil {
+							return nil, fmt.Errorf("target parent hash not found: %s: %w", parentHash, err)
 						}
 
 						queue = append(queue, parent)
@@ -193,14 +221,22 @@ func VerifyLocalState(repo *git.Repository, state *gitkit.RepoState, repoConfig
 			}
 
 			if *branch.Hash.SHA1 != *newBranch.Hash.SHA1 {
-				fmt.Printf("%s: git log -p --full-diff %s...%s\n", branch.Ref, *branch.Hash.SHA1, *newBranch.Hash.SHA1)
+				updates = append(updates, BranchUpdate{
+					Ref:  branch.Ref,
+					From: plumbing.NewHash(*branch.Hash.SHA1),
+					To:   plumbing.NewHash(*newBranch.Hash.SHA1),
+				})
 			}
 		} else {
-			return fmt.Errorf("protected branch '%s' has been deleted, was %s", branch.Ref, *branch.Hash.SHA1)
+			return nil, &LocalStateRollbackError{
+				Ref:          branch.Ref,
+				PreviousHash: plumbing.NewHash(*branch.Hash.SHA1),
+				Reason:       "protected branch has been deleted",
+			}
 		}
 	}
 
-	return nil
+	return updates, nil
 }
 
 func computeProtectedBranches(repo *git.Repository, config *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) ([]ExemptTag, error) {
@@ -239,3 +275,12 @@ func computeProtectedBranches(repo *git.Repository, config *RepoConfig, gitHashS
 
 	return result, nil
 }
+
+func taggerEmail(state gitkit.RepoObjects, tagHash plumbing.Hash) string {
+	t, err := state.Tag(tagHash)
+	if err != nil {
+		return ""
+	}
+
+	return t.Tagger.Email
+}
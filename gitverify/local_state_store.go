@@ -0,0 +1,380 @@
+package gitverify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// LocalStateStore persists the local state between runs, it is used to detect tags that are moved
+// and protected branches that are rolled back.
+type LocalStateStore interface {
+	// Load returns the stored local state, or nil if nothing has been stored yet.
+	Load(ctx context.Context) (*LocalState, error)
+	Save(ctx context.Context, localState *LocalState) error
+}
+
+// FileLocalStateStore stores the local state as a JSON file, see GetLocalStatePath for the default location.
+type FileLocalStateStore struct {
+	path string
+}
+
+func NewFileLocalStateStore(path string) *FileLocalStateStore {
+	return &FileLocalStateStore{
+		path: path,
+	}
+}
+
+func (s *FileLocalStateStore) Load(ctx context.Context) (*LocalState, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	localState := &LocalState{}
+	err = json.Unmarshal(data, localState)
+	if err != nil {
+		return nil, err
+	}
+
+	return localState, nil
+}
+
+func (s *FileLocalStateStore) Save(ctx context.Context, localState *LocalState) error {
+	data, err := json.Marshal(localState)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.path), defaultDirectoryPermission)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, data, defaultFilePermission)
+}
+
+const localStateFileName = "local.json"
+
+// GitRefLocalStateStore stores the local state as local.json in a commit pointed to by a ref in a separate
+// state repository, e.g. one that is pushed somewhere durable from CI. Each save creates a new commit on top
+// of the previous one. If sign is set the commits are signed using the git config of the state repository
+// (`user.signingKey`, `gpg.format`), and the commit is verified with `git verify-commit` when loading.
+type GitRefLocalStateStore struct {
+	gitDir string
+	ref    string
+	sign   bool
+}
+
+func NewGitRefLocalStateStore(gitDir string, ref string, sign bool) *GitRefLocalStateStore {
+	return &GitRefLocalStateStore{
+		gitDir: gitDir,
+		ref:    ref,
+		sign:   sign,
+	}
+}
+
+// LocalStateRef returns the default ref in a state repository for a repository.
+func LocalStateRef(forge string, org string, repoName string) string {
+	return "refs/gitverify/" + forge + "/" + org + "/" + repoName
+}
+
+func (s *GitRefLocalStateStore) Load(ctx context.Context) (*LocalState, error) {
+	commit, found, err := s.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	if s.sign {
+		_, err = s.git(ctx, nil, "verify-commit", commit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify signature of %s in %s: %w", s.ref, s.gitDir, err)
+		}
+	}
+
+	data, err := s.git(ctx, nil, "cat-file", "blob", commit+":"+localStateFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	localState := &LocalState{}
+	err = json.Unmarshal([]byte(data), localState)
+	if err != nil {
+		return nil, err
+	}
+
+	return localState, nil
+}
+
+func (s *GitRefLocalStateStore) Save(ctx context.Context, localState *LocalState) error {
+	data, err := json.Marshal(localState)
+	if err != nil {
+		return err
+	}
+
+	parent, found, err := s.head(ctx)
+	if err != nil {
+		return err
+	}
+
+	blob, err := s.git(ctx, bytes.NewReader(data), "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+
+	tree, err := s.git(ctx, strings.NewReader("100644 blob "+blob+"\t"+localStateFileName+"\n"), "mktree")
+	if err != nil {
+		return err
+	}
+
+	args := []string{"commit-tree", tree, "-m", "Update gitverify local state"}
+	if found {
+		args = append(args, "-p", parent)
+	}
+
+	if s.sign {
+		args = append(args, "-S")
+	}
+
+	commit, err := s.git(ctx, nil, args...)
+	if err != nil {
+		return err
+	}
+
+	// compare-and-swap, fails if the ref was updated since it was read
+	oldValue := plumbing.ZeroHash.String()
+	if found {
+		oldValue = parent
+	}
+
+	_, err = s.git(ctx, nil, "update-ref", s.ref, commit, oldValue)
+	return err
+}
+
+func (s *GitRefLocalStateStore) head(ctx context.Context) (string, bool, error) {
+	_, err := s.git(ctx, nil, "show-ref", "--verify", "--quiet", s.ref)
+	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && exitError.ExitCode() == 1 {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	commit, err := s.git(ctx, nil, "rev-parse", "--verify", s.ref+"^{commit}")
+	if err != nil {
+		return "", false, err
+	}
+
+	return commit, true, nil
+}
+
+func (s *GitRefLocalStateStore) git(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", s.gitDir}, args...)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// HTTPLocalStateStore stores the local state in a generic key-value endpoint: GET returns the state
+// (404 if there is none) and PUT replaces it. The ETag from the last load is sent as If-Match when
+// saving, and If-None-Match: * when no state was found, so concurrent updates are rejected by servers
+// that support it.
+type HTTPLocalStateStore struct {
+	url    string
+	client *http.Client
+	header http.Header
+
+	mutex  sync.Mutex
+	etag   string
+	exists bool
+}
+
+// NewHTTPLocalStateStore returns a store for the url. The header is added to all requests, e.g. for authorization.
+func NewHTTPLocalStateStore(url string, client *http.Client, header http.Header) *HTTPLocalStateStore {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPLocalStateStore{
+		url:    url,
+		client: client,
+		header: header,
+	}
+}
+
+func (s *HTTPLocalStateStore) Load(ctx context.Context) (*LocalState, error) {
+	request, err := s.newRequest(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		s.mutex.Lock()
+		s.etag = ""
+		s.exists = false
+		s.mutex.Unlock()
+		return nil, nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to load local state from %s: %s", s.url, response.Status)
+	}
+
+	localState := &LocalState{}
+	err = json.NewDecoder(response.Body).Decode(localState)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.etag = response.Header.Get("ETag")
+	s.exists = true
+	s.mutex.Unlock()
+
+	return localState, nil
+}
+
+func (s *HTTPLocalStateStore) Save(ctx context.Context, localState *LocalState) error {
+	data, err := json.Marshal(localState)
+	if err != nil {
+		return err
+	}
+
+	request, err := s.newRequest(ctx, http.MethodPut, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	s.mutex.Lock()
+	if s.etag != "" {
+		request.Header.Set("If-Match", s.etag)
+	} else if !s.exists {
+		// only create the state if no one else has in the meantime
+		request.Header.Set("If-None-Match", "*")
+	}
+	s.mutex.Unlock()
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("failed to save local state to %s: %s", s.url, response.Status)
+	}
+
+	s.mutex.Lock()
+	s.etag = response.Header.Get("ETag")
+	s.exists = true
+	s.mutex.Unlock()
+
+	return nil
+}
+
+func (s *HTTPLocalStateStore) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, s.url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range s.header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+
+	return request, nil
+}
+
+// ParseLocalStateStore returns the store described by spec for the repository:
+//   - "" uses the default file, see GetLocalStatePath
+//   - "git:<path to state repository>" uses LocalStateRef in the state repository, with signed commits
+//   - "http://..." or "https://..." uses <url>/<forge>/<org>/<repo>/local.json, with the
+//     GITVERIFY_LOCAL_STATE_TOKEN environment variable as bearer token if set, which requires https
+//   - anything else is a path to a local state file
+func ParseLocalStateStore(spec string, forge string, org string, repoName string) (LocalStateStore, error) {
+	switch {
+	case spec == "":
+		path, err := GetLocalStatePath(forge, org, repoName)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileLocalStateStore(path), nil
+	case strings.HasPrefix(spec, "git:"):
+		gitDir, err := stateRepoGitDir(strings.TrimPrefix(spec, "git:"))
+		if err != nil {
+			return nil, err
+		}
+		return NewGitRefLocalStateStore(gitDir, LocalStateRef(forge, org, repoName), true), nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		header := http.Header{}
+		token := os.Getenv("GITVERIFY_LOCAL_STATE_TOKEN")
+		if token != "" {
+			if !strings.HasPrefix(spec, "https://") {
+				return nil, fmt.Errorf("GITVERIFY_LOCAL_STATE_TOKEN is set, refusing to send it over plain http to %s", spec)
+			}
+			header.Set("Authorization", "Bearer "+token)
+		}
+
+		url := strings.TrimSuffix(spec, "/") + "/" + forge + "/" + org + "/" + repoName + "/" + localStateFileName
+		return NewHTTPLocalStateStore(url, nil, header), nil
+	default:
+		return NewFileLocalStateStore(spec), nil
+	}
+}
+
+// stateRepoGitDir returns the git directory for both bare and non-bare state repositories.
+func stateRepoGitDir(path string) (string, error) {
+	dotGit := filepath.Join(path, ".git")
+	info, err := os.Stat(dotGit)
+	if err == nil && info.IsDir() {
+		return dotGit, nil
+	}
+
+	info, err = os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("state repository not found: %w", err)
+	}
+
+	if !info.IsDir() {
+		return "", fmt.Errorf("state repository %s is not a directory", path)
+	}
+
+	return path, nil
+}
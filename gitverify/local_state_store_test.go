@@ -0,0 +1,164 @@
+package gitverify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func testLocalStateStore(t *testing.T, store LocalStateStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	localState, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if localState != nil {
+		t.Fatalf("expected no local state, got %v", localState)
+	}
+
+	for _, hash := range []string{"1f46f2053221c040ce5bcba0239bc09214a37658", "d453471f46f2053221c040ce5bcba0239bc09214"} {
+		err = store.Save(ctx, &LocalState{
+			Tags:     []ExemptTag{{Ref: "refs/tags/v1", Hash: Digests{SHA1: &hash}}},
+			Branches: []ExemptTag{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		localState, err = store.Load(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if localState == nil || len(localState.Tags) != 1 || *localState.Tags[0].Hash.SHA1 != hash {
+			t.Fatalf("got %v, want tag with %s", localState, hash)
+		}
+	}
+}
+
+func TestFileLocalStateStore(t *testing.T) {
+	testLocalStateStore(t, NewFileLocalStateStore(filepath.Join(t.TempDir(), "a", "local.json")))
+}
+
+func TestGitRefLocalStateStore(t *testing.T) {
+	gitDir := t.TempDir()
+	err := exec.Command("git", "init", "--quiet", "--bare", gitDir).Run()
+	if err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+
+	t.Setenv("GIT_AUTHOR_NAME", "A")
+	t.Setenv("GIT_AUTHOR_EMAIL", "a@example.internal")
+	t.Setenv("GIT_COMMITTER_NAME", "A")
+	t.Setenv("GIT_COMMITTER_EMAIL", "a@example.internal")
+
+	testLocalStateStore(t, NewGitRefLocalStateStore(gitDir, LocalStateRef("github.com", "foo", "bar"), false))
+
+	out, err := exec.Command("git", "--git-dir", gitDir, "rev-list", "--count", "refs/gitverify/github.com/foo/bar").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != "2\n" {
+		t.Errorf("expected 2 commits, got %s", out)
+	}
+}
+
+func TestHTTPLocalStateStore(t *testing.T) {
+	var mutex sync.Mutex
+	var stored []byte
+	version := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		etag := `"` + string(rune('0'+version)) + `"`
+
+		switch r.Method {
+		case http.MethodGet:
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write(stored)
+		case http.MethodPut:
+			if stored != nil && (r.Header.Get("If-Match") != etag || r.Header.Get("If-None-Match") == "*") {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+
+			if stored == nil && r.Header.Get("If-None-Match") != "*" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			stored, _ = io.ReadAll(r.Body)
+			version++
+			w.Header().Set("ETag", `"`+string(rune('0'+version))+`"`)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+
+	store := NewHTTPLocalStateStore(server.URL+"/github.com/foo/bar/local.json", server.Client(), header)
+	testLocalStateStore(t, store)
+
+	// a second writer with a stale ETag is rejected
+	other := NewHTTPLocalStateStore(server.URL+"/github.com/foo/bar/local.json", server.Client(), header)
+	_, err := other.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Save(context.Background(), &LocalState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = other.Save(context.Background(), &LocalState{})
+	if err == nil {
+		t.Errorf("expected save with stale ETag to fail")
+	}
+
+	// a writer that has not seen any state does not overwrite the existing one
+	err = NewHTTPLocalStateStore(server.URL+"/github.com/foo/bar/local.json", server.Client(), header).Save(context.Background(), &LocalState{})
+	if err == nil {
+		t.Errorf("expected save without a loaded state to fail when the state exists")
+	}
+}
+
+func TestParseLocalStateStoreToken(t *testing.T) {
+	t.Setenv("GITVERIFY_LOCAL_STATE_TOKEN", "token")
+
+	_, err := ParseLocalStateStore("http://example.internal/state", "github.com", "foo", "bar")
+	if err == nil {
+		t.Errorf("expected the token to be rejected over http")
+	}
+
+	store, err := ParseLocalStateStore("https://example.internal/state/", "github.com", "foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpStore, ok := store.(*HTTPLocalStateStore)
+	if !ok || httpStore.url != "https://example.internal/state/github.com/foo/bar/local.json" || httpStore.header.Get("Authorization") != "Bearer token" {
+		t.Errorf("got %+v", store)
+	}
+}
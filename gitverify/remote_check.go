@@ -0,0 +1,187 @@
+package gitverify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+type RemoteRefStatus string
+
+const (
+	// RemoteRefStatusUnchanged means the remote advertises the same hash as the local state.
+	RemoteRefStatusUnchanged RemoteRefStatus = "unchanged"
+	// RemoteRefStatusFastForward means the advertised commit is known locally and is a first-parent descendant.
+	RemoteRefStatusFastForward RemoteRefStatus = "fast-forward"
+	// RemoteRefStatusUpdated means the advertised commit is not known locally, it is verified after it has been fetched.
+	RemoteRefStatusUpdated RemoteRefStatus = "updated"
+	// RemoteRefStatusNonFastForward means the advertised commit is known locally, but is not a first-parent descendant.
+	RemoteRefStatusNonFastForward RemoteRefStatus = "non-fast-forward"
+	// RemoteRefStatusMoved means the remote advertises a different hash for a tag.
+	RemoteRefStatusMoved RemoteRefStatus = "moved"
+	// RemoteRefStatusDeleted means the remote no longer advertises the ref.
+	RemoteRefStatusDeleted RemoteRefStatus = "deleted"
+)
+
+type RemoteRefCheck struct {
+	Ref        string          `json:"ref"`
+	RemoteRef  string          `json:"remoteRef"`
+	Status     RemoteRefStatus `json:"status"`
+	LocalHash  string          `json:"localHash"`
+	RemoteHash string          `json:"remoteHash,omitempty"`
+}
+
+// Warning returns true if the status indicates a rollback or a deleted ref.
+func (c RemoteRefCheck) Warning() bool {
+	switch c.Status {
+	case RemoteRefStatusNonFastForward, RemoteRefStatusMoved, RemoteRefStatusDeleted:
+		return true
+	default:
+		return false
+	}
+}
+
+// ListRemote lists the references advertised by the remote without fetching any objects.
+func ListRemote(repo *git.Repository, remoteName string, auth transport.AuthMethod) ([]*plumbing.Reference, error) {
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote '%s': %w", remoteName, err)
+	}
+
+	refs, err := remote.List(&git.ListOptions{
+		Auth: auth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote '%s': %w", remoteName, err)
+	}
+
+	return refs, nil
+}
+
+// CheckRemoteRefs compares the references advertised by the remote with the tags and the remote tracking
+// protected branches in the local state. Branches that have moved are checked to be fast-forwards if
+// the new commit is already present in the repository.
+func CheckRemoteRefs(repo *git.Repository, remoteName string, advertised []*plumbing.Reference, localState *LocalState) ([]RemoteRefCheck, error) {
+	result := make([]RemoteRefCheck, 0)
+	if localState == nil {
+		return result, nil
+	}
+
+	remoteRefs := make(map[string]plumbing.Hash)
+	for _, ref := range advertised {
+		if ref.Type() == plumbing.HashReference {
+			remoteRefs[ref.Name().String()] = ref.Hash()
+		}
+	}
+
+	for _, tag := range localState.Tags {
+		if tag.Hash.SHA1 == nil {
+			return nil, fmt.Errorf("tag SHA-1 hashes must be set")
+		}
+
+		check := RemoteRefCheck{
+			Ref:       tag.Ref,
+			RemoteRef: tag.Ref,
+			LocalHash: *tag.Hash.SHA1,
+		}
+
+		hash, found := remoteRefs[tag.Ref]
+		switch {
+		case !found:
+			check.Status = RemoteRefStatusDeleted
+		case hash.String() == *tag.Hash.SHA1:
+			check.Status = RemoteRefStatusUnchanged
+		default:
+			check.Status = RemoteRefStatusMoved
+		}
+
+		if found {
+			check.RemoteHash = hash.String()
+		}
+
+		result = append(result, check)
+	}
+
+	remotePrefix := "refs/remotes/" + remoteName + "/"
+	for _, branch := range localState.Branches {
+		if !strings.HasPrefix(branch.Ref, remotePrefix) {
+			// local branches are not expected to match the remote
+			continue
+		}
+
+		if branch.Hash.SHA1 == nil {
+			return nil, fmt.Errorf("branch hashes must be set")
+		}
+
+		check := RemoteRefCheck{
+			Ref:       branch.Ref,
+			RemoteRef: "refs/heads/" + strings.TrimPrefix(branch.Ref, remotePrefix),
+			LocalHash: *branch.Hash.SHA1,
+		}
+
+		hash, found := remoteRefs[check.RemoteRef]
+		if !found {
+			check.Status = RemoteRefStatusDeleted
+			result = append(result, check)
+			continue
+		}
+
+		check.RemoteHash = hash.String()
+
+		if hash.String() == *branch.Hash.SHA1 {
+			check.Status = RemoteRefStatusUnchanged
+		} else {
+			status, err := fastForwardStatus(repo, plumbing.NewHash(*branch.Hash.SHA1), hash)
+			if err != nil {
+				return nil, err
+			}
+			check.Status = status
+		}
+
+		result = append(result, check)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Ref < result[j].Ref
+	})
+
+	return result, nil
+}
+
+// fastForwardStatus follows the first parents of the new commit, like VerifyLocalState, to look for the previous commit.
+func fastForwardStatus(repo *git.Repository, previous plumbing.Hash, next plumbing.Hash) (RemoteRefStatus, error) {
+	commit, err := repo.CommitObject(next)
+	if err != nil {
+		if err == plumbing.ErrObjectNotFound {
+			return RemoteRefStatusUpdated, nil
+		}
+		return "", err
+	}
+
+	for {
+		if commit.Hash == previous {
+			return RemoteRefStatusFastForward, nil
+		}
+
+		if len(commit.ParentHashes) == 0 {
+			return RemoteRefStatusNonFastForward, nil
+		}
+
+		var parent *object.Commit
+		parent, err = repo.CommitObject(commit.ParentHashes[0])
+		if err != nil {
+			if err == plumbing.ErrObjectNotFound {
+				// shallow or partial history, the rest is verified after fetching
+				return RemoteRefStatusUpdated, nil
+			}
+			return "", err
+		}
+
+		commit = parent
+	}
+}
@@ -0,0 +1,139 @@
+package gitverify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCheckRemoteRefs(t *testing.T) {
+	remoteDir := t.TempDir()
+	remoteRepo, err := git.PlainInit(remoteDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := commitEmpty(t, remoteRepo, "first")
+	second := commitEmpty(t, remoteRepo, "second")
+	setRef(t, remoteRepo, "refs/heads/main", second)
+	setRef(t, remoteRepo, "refs/tags/v1", first)
+	setRef(t, remoteRepo, "refs/tags/v2", second)
+
+	localRepo, err := git.PlainClone(t.TempDir(), false, &git.CloneOptions{URL: remoteDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstSHA1 := first.String()
+	secondSHA1 := second.String()
+	localState := &LocalState{
+		Tags: []ExemptTag{
+			{Ref: "refs/tags/v1", Hash: Digests{SHA1: &firstSHA1}},
+			{Ref: "refs/tags/v2", Hash: Digests{SHA1: &secondSHA1}},
+		},
+		Branches: []ExemptTag{
+			{Ref: "refs/heads/main", Hash: Digests{SHA1: &secondSHA1}},
+			{Ref: "refs/remotes/origin/main", Hash: Digests{SHA1: &secondSHA1}},
+		},
+	}
+
+	expectStatuses := func(expected map[string]RemoteRefStatus) {
+		t.Helper()
+
+		advertised, err := ListRemote(localRepo, "origin", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checks, err := CheckRemoteRefs(localRepo, "origin", advertised, localState)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(checks) != len(expected) {
+			t.Fatalf("got %v, want %v", checks, expected)
+		}
+
+		for _, check := range checks {
+			if check.Status != expected[check.Ref] {
+				t.Errorf("%s: status=%s, want %s", check.Ref, check.Status, expected[check.Ref])
+			}
+		}
+	}
+
+	expectStatuses(map[string]RemoteRefStatus{
+		"refs/tags/v1":             RemoteRefStatusUnchanged,
+		"refs/tags/v2":             RemoteRefStatusUnchanged,
+		"refs/remotes/origin/main": RemoteRefStatusUnchanged,
+	})
+
+	third := commitEmpty(t, remoteRepo, "third")
+	setRef(t, remoteRepo, "refs/heads/main", third)
+	setRef(t, remoteRepo, "refs/tags/v2", first)
+
+	expectStatuses(map[string]RemoteRefStatus{
+		"refs/tags/v1":             RemoteRefStatusUnchanged,
+		"refs/tags/v2":             RemoteRefStatusMoved,
+		"refs/remotes/origin/main": RemoteRefStatusUpdated,
+	})
+
+	setRef(t, remoteRepo, "refs/heads/main", first)
+	err = remoteRepo.Storer.RemoveReference("refs/tags/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatuses(map[string]RemoteRefStatus{
+		"refs/tags/v1":             RemoteRefStatusDeleted,
+		"refs/tags/v2":             RemoteRefStatusMoved,
+		"refs/remotes/origin/main": RemoteRefStatusNonFastForward,
+	})
+
+	setRef(t, remoteRepo, "refs/heads/main", third)
+	err = localRepo.Fetch(&git.FetchOptions{RefSpecs: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatuses(map[string]RemoteRefStatus{
+		"refs/tags/v1":             RemoteRefStatusDeleted,
+		"refs/tags/v2":             RemoteRefStatusMoved,
+		"refs/remotes/origin/main": RemoteRefStatusFastForward,
+	})
+}
+
+func commitEmpty(t *testing.T, repo *git.Repository, message string) plumbing.Hash {
+	t.Helper()
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := worktree.Commit(message, &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author: &object.Signature{
+			Name:  "A",
+			Email: "a@example.internal",
+			When:  time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func setRef(t *testing.T, repo *git.Repository, name string, hash plumbing.Hash) {
+	t.Helper()
+
+	err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"golang.org/x/crypto/ssh"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type RepoConfig struct {
@@ -33,6 +34,14 @@ type RepoConfig struct {
 	requireSignedTags                  bool
 	requireMergeCommits                bool
 	requireUpToDate                    bool
+	maxTimestampSkew                   *time.Duration
+	rejectFutureTimestamps             bool
+	requireTagsNewerThanTarget         bool
+	requiredTrailers                   []ParsedTrailerRule
+	forbiddenTrailers                  []string
+	trailerScope                       TrailerScope
+	messagePatterns                    map[string]*regexp.Regexp
+	branchStrategies                   map[string]BranchStrategy
 	protectedBranches                  hashset.Set[string]
 	exemptedTags                       map[string]string
 	exemptedTagsSHA512                 map[string]string
@@ -44,6 +53,9 @@ type identity struct {
 	forgeUserId   *string
 	sshPublicKeys map[string]*ssh.PublicKey
 	gpgPublicKeys []string
+	validFrom     *time.Time
+	// keyValidFrom overrides validFrom per key, keyed like sshPublicKeys or by the armored GPG key
+	keyValidFrom map[string]time.Time
 }
 
 type forge struct {
@@ -105,12 +117,37 @@ func LoadRepoConfig(config *ParsedConfig, repoUri string) (*RepoConfig, error) {
 			sshPublicKeys[string(rawKey)] = &publicKey
 		}
 
+		var validFrom *time.Time
+		if i.ValidFrom != nil {
+			t, err := time.Parse(time.RFC3339, *i.ValidFrom)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse validFrom for '%s': %w", i.Email, err)
+			}
+			validFrom = &t
+		}
+
+		keyValidFrom := make(map[string]time.Time)
+		for key, value := range i.KeyValidFrom {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse keyValidFrom for '%s': %w", i.Email, err)
+			}
+
+			keyId, err := signingKeyId(key, i)
+			if err != nil {
+				return nil, fmt.Errorf("invalid keyValidFrom for '%s': %w", i.Email, err)
+			}
+			keyValidFrom[keyId] = t
+		}
+
 		identityEntry := identity{
 			email:         i.Email,
 			forgeUsername: i.ForgeUsername,
 			forgeUserId:   i.ForgeUserId,
 			sshPublicKeys: sshPublicKeys,
 			gpgPublicKeys: i.GPGPublicKeys,
+			validFrom:     validFrom,
+			keyValidFrom:  keyValidFrom,
 		}
 
 		var forgeEmail = ""
@@ -229,6 +266,18 @@ func LoadRepoConfig(config *ParsedConfig, repoUri string) (*RepoConfig, error) {
 
 	protectedBranches := hashset.New[string](repo.ProtectedBranches...)
 
+	for branch := range repo.Rules.MessagePatterns {
+		if !protectedBranches.Contains(branch) {
+			return nil, fmt.Errorf("rules.messagePatterns has pattern for '%s' which is not a protected branch", branch)
+		}
+	}
+
+	for branch := range repo.Rules.BranchStrategies {
+		if !protectedBranches.Contains(branch) {
+			return nil, fmt.Errorf("rules.branchStrategies has strategy for '%s' which is not a protected branch", branch)
+		}
+	}
+
 	var afterSHA1 = hashset.New[plumbing.Hash]()
 	var afterSHA512 = hashset.New[[64]byte]()
 	afterSHA1ToSHA512 := make(map[plumbing.Hash][64]byte)
@@ -297,8 +346,41 @@ func LoadRepoConfig(config *ParsedConfig, repoUri string) (*RepoConfig, error) {
 		requireSignedTags:                  repo.Rules.RequireSignedTags,
 		requireMergeCommits:                repo.Rules.RequireMergeCommits,
 		requireUpToDate:                    repo.Rules.RequireUpToDate,
+		maxTimestampSkew:                   repo.Rules.MaxTimestampSkew,
+		rejectFutureTimestamps:             repo.Rules.RejectFutureTimestamps,
+		requireTagsNewerThanTarget:         repo.Rules.RequireTagsNewerThanTarget,
+		requiredTrailers:                   repo.Rules.RequiredTrailers,
+		forbiddenTrailers:                  repo.Rules.ForbiddenTrailers,
+		trailerScope:                       repo.Rules.TrailerScope,
+		messagePatterns:                    repo.Rules.MessagePatterns,
+		branchStrategies:                   repo.Rules.BranchStrategies,
 		exemptedTags:                       exemptedTagMap,
 		exemptedTagsSHA512:                 exemptedTagSHA512Map,
 		protectedBranches:                  protectedBranches,
 	}, nil
 }
+
+// signingKeyId returns the id used for a key in keyValidFrom, which must be one of the SSH or GPG keys of the
+// identity. SSH keys are identified by the raw key, like in identity.sshPublicKeys.
+func signingKeyId(key string, i Identity) (string, error) {
+	for _, gpgPublicKey := range i.GPGPublicKeys {
+		if key == gpgPublicKey {
+			return key, nil
+		}
+	}
+
+	for _, sshPublicKey := range i.SSHPublicKeys {
+		if key != sshPublicKey {
+			continue
+		}
+
+		parts := strings.Split(sshPublicKey, " ")
+		rawKey, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return "", err
+		}
+		return string(rawKey), nil
+	}
+
+	return "", fmt.Errorf("key is not one of the sshPublicKeys or gpgPublicKeys: %s", key)
+}
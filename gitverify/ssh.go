@@ -5,6 +5,7 @@ import (
 	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
 	"golang.org/x/crypto/ssh"
 	"strings"
 )
@@ -19,48 +20,64 @@ type SSHSig struct {
 	Signature     string
 }
 
-func validateSSH(content string, signature string, identity identity, config *RepoConfig) error {
+func validateSSH(hash plumbing.Hash, content string, signature string, identity identity, config *RepoConfig) error {
 	if !config.allowSSHSignatures {
-		return fmt.Errorf("SSH signatures not allowed")
+		return &DisallowedSignatureTypeError{
+			Hash:          hash,
+			Identity:      identity.email,
+			SignatureType: SignatureTypeSSH,
+		}
+	}
+
+	invalidSignature := func(err error) error {
+		return &InvalidSignatureError{
+			Hash:     hash,
+			Identity: identity.email,
+			Err:      err,
+		}
 	}
 
 	sshSig, err := decodeAndParseSSHSignature(signature)
 	if err != nil {
-		return err
+		return invalidSignature(err)
 	}
 
 	trustedKey, found := identity.sshPublicKeys[sshSig.PublicKey]
 	if found {
 		err = verifySignature(*trustedKey, content, sshSig, namespaceSSH, config.allowSSHSHA256)
 		if err != nil {
-			return err
+			return invalidSignature(err)
 		}
 
 		if config.requireSSHUserPresent || config.requireSSHUserVerified {
 			publicKey, err := parsePublicKey(sshSig)
 			if err != nil {
-				return err
+				return invalidSignature(err)
 			}
 
 			if !(publicKey.KeyType == "sk-ssh-ed25519@openssh.com" || publicKey.KeyType == "sk-ecdsa-sha2-nistp256@openssh.com") {
-				return fmt.Errorf("unsupported public key type %s for user present/verified", publicKey.KeyType)
+				return invalidSignature(fmt.Errorf("unsupported public key type %s for user present/verified", publicKey.KeyType))
 			}
 
 			signature, err := parseU2FSignature(sshSig)
 			if err != nil {
-				return err
+				return invalidSignature(err)
 			}
 
 			if config.requireSSHUserPresent && !signature.userPresent() {
-				return fmt.Errorf("user present missing")
+				return invalidSignature(fmt.Errorf("user present missing"))
 			}
 
 			if config.requireSSHUserVerified && !signature.userVerified() {
-				return fmt.Errorf("user verified missing")
+				return invalidSignature(fmt.Errorf("user verified missing"))
 			}
 		}
 	} else {
-		return fmt.Errorf("matching SSH key not found for '%s'", identity.email)
+		return &UnknownKeyError{
+			Hash:     hash,
+			Identity: identity.email,
+			KeyType:  SignatureTypeSSH,
+		}
 	}
 
 	return nil
@@ -0,0 +1,141 @@
+package gitverify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+// validateCommitTimestamps checks the committer time against the parents and the verification time.
+// Both checks are optional and allow for the configured skew. Parents that are missing, e.g. at the boundary of a
+// shallow clone, are not checked.
+func validateCommitTimestamps(commit *object.Commit, state gitkit.RepoObjects, repoConfig *RepoConfig, now time.Time) error {
+	skew := timestampSkew(repoConfig)
+
+	if repoConfig.maxTimestampSkew != nil {
+		for _, parentHash := range commit.ParentHashes {
+			parent, err := state.Commit(parentHash)
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				// the boundary of a shallow clone
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("parent %s of commit %s not found: %w", parentHash.String(), commit.Hash.String(), err)
+			}
+
+			if commit.Committer.When.Before(parent.Committer.When.Add(-skew)) {
+				return &TimestampError{
+					Hash:     commit.Hash,
+					Identity: commit.Committer.Email,
+					Reason: fmt.Sprintf("committed at %s, more than %s before parent %s at %s",
+						formatTime(commit.Committer.When), skew, parentHash.String(), formatTime(parent.Committer.When)),
+				}
+			}
+		}
+	}
+
+	if repoConfig.rejectFutureTimestamps {
+		for _, signature := range []object.Signature{commit.Author, commit.Committer} {
+			if signature.When.After(now.Add(skew)) {
+				return &TimestampError{
+					Hash:     commit.Hash,
+					Identity: signature.Email,
+					Reason:   fmt.Sprintf("timestamp %s is in the future", formatTime(signature.When)),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateTagTimestamps checks that the tag is not in the future and, if required, that it was made
+// after the commit it points to.
+func validateTagTimestamps(tag *object.Tag, state gitkit.RepoObjects, repoConfig *RepoConfig, now time.Time) error {
+	skew := timestampSkew(repoConfig)
+
+	if repoConfig.rejectFutureTimestamps && tag.Tagger.When.After(now.Add(skew)) {
+		return &TimestampError{
+			Hash:     tag.Hash,
+			Ref:      tagRef(tag),
+			Identity: tag.Tagger.Email,
+			Reason:   fmt.Sprintf("timestamp %s is in the future", formatTime(tag.Tagger.When)),
+		}
+	}
+
+	if repoConfig.requireTagsNewerThanTarget {
+		target, err := state.Commit(tag.Target)
+		if err != nil {
+			return fmt.Errorf("target %s of tag %s not found: %w", tag.Target.String(), tag.Name, err)
+		}
+
+		if tag.Tagger.When.Before(target.Committer.When.Add(-skew)) {
+			return &TimestampError{
+				Hash:     tag.Hash,
+				Ref:      tagRef(tag),
+				Identity: tag.Tagger.Email,
+				Reason: fmt.Sprintf("tagged at %s, before target %s at %s",
+					formatTime(tag.Tagger.When), tag.Target.String(), formatTime(target.Committer.When)),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateValidFrom checks that a signature by the identity is not dated before the signing key is valid.
+// The validFrom of the key takes precedence over the validFrom of the identity.
+func validateValidFrom(hash plumbing.Hash, id identity, signatureType SignatureType, pgpSignature string, signature object.Signature) error {
+	validFrom := id.validFrom
+	keyValidFrom, found := id.keyValidFrom[signingKey(id, signatureType, pgpSignature)]
+	if found {
+		validFrom = &keyValidFrom
+	}
+
+	if validFrom != nil && signature.When.Before(*validFrom) {
+		return &TimestampError{
+			Hash:     hash,
+			Identity: id.email,
+			Reason: fmt.Sprintf("signed at %s, before the key is valid from %s",
+				formatTime(signature.When), formatTime(*validFrom)),
+		}
+	}
+
+	return nil
+}
+
+// signingKey returns the key of the identity that the signature claims to be made with, as used in
+// identity.keyValidFrom, or an empty string if it is not known. The signature itself is verified separately.
+func signingKey(id identity, signatureType SignatureType, pgpSignature string) string {
+	switch signatureType {
+	case SignatureTypeSSH:
+		sshSig, err := decodeAndParseSSHSignature(pgpSignature)
+		if err != nil {
+			return ""
+		}
+		return sshSig.PublicKey
+	case SignatureTypeGPG:
+		// only one GPG key is currently supported
+		if len(id.gpgPublicKeys) == 1 {
+			return id.gpgPublicKeys[0]
+		}
+	}
+
+	return ""
+}
+
+func timestampSkew(repoConfig *RepoConfig) time.Duration {
+	if repoConfig.maxTimestampSkew == nil {
+		return 0
+	}
+
+	return *repoConfig.maxTimestampSkew
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,115 @@
+package gitverify
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+func TestValidateCommitTimestamps(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	parent := &object.Commit{
+		Hash:      plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658"),
+		Committer: object.Signature{Email: "a@example.internal", When: now.Add(-time.Hour)},
+	}
+
+	state := &gitkit.RepoState{
+		CommitMap: map[plumbing.Hash]*object.Commit{parent.Hash: parent},
+	}
+
+	skew := 10 * time.Minute
+	repoConfig := &RepoConfig{
+		maxTimestampSkew:       &skew,
+		rejectFutureTimestamps: true,
+	}
+
+	tests := []struct {
+		name      string
+		committed time.Time
+		fails     bool
+	}{
+		{"after parent", now.Add(-30 * time.Minute), false},
+		{"within skew before parent", now.Add(-65 * time.Minute), false},
+		{"before parent", now.Add(-2 * time.Hour), true},
+		{"within skew in the future", now.Add(5 * time.Minute), false},
+		{"in the future", now.Add(time.Hour), true},
+	}
+
+	for _, test := range tests {
+		commit := &object.Commit{
+			Hash:         plumbing.NewHash("d453471f46f2053221c040ce5bcba0239bc09214"),
+			Author:       object.Signature{Email: "a@example.internal", When: test.committed},
+			Committer:    object.Signature{Email: "a@example.internal", When: test.committed},
+			ParentHashes: []plumbing.Hash{parent.Hash},
+		}
+
+		err := validateCommitTimestamps(commit, state, repoConfig, now)
+
+		var timestampError *TimestampError
+		if test.fails != errors.As(err, &timestampError) {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+
+	err := validateCommitTimestamps(parent, state, &RepoConfig{}, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Errorf("rules are disabled by default, got %v", err)
+	}
+}
+
+func TestValidateCommitTimestampsShallow(t *testing.T) {
+	skew := 10 * time.Minute
+	commit := &object.Commit{
+		Hash:         plumbing.NewHash("d453471f46f2053221c040ce5bcba0239bc09214"),
+		Committer:    object.Signature{Email: "a@example.internal", When: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		ParentHashes: []plumbing.Hash{plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658")},
+	}
+
+	state := &gitkit.RepoState{
+		CommitMap: map[plumbing.Hash]*object.Commit{commit.Hash: commit},
+	}
+
+	err := validateCommitTimestamps(commit, state, &RepoConfig{maxTimestampSkew: &skew}, commit.Committer.When)
+	if err != nil {
+		t.Errorf("a missing parent should be treated as the shallow boundary, got %v", err)
+	}
+}
+
+func TestValidateValidFrom(t *testing.T) {
+	identityValidFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	keyValidFrom := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	id := identity{
+		email:         "a@example.internal",
+		gpgPublicKeys: []string{"new key"},
+		validFrom:     &identityValidFrom,
+		keyValidFrom:  map[string]time.Time{"new key": keyValidFrom},
+	}
+
+	hash := plumbing.NewHash("d453471f46f2053221c040ce5bcba0239bc09214")
+	tests := []struct {
+		name          string
+		signatureType SignatureType
+		signed        time.Time
+		fails         bool
+	}{
+		{"key valid", SignatureTypeGPG, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), false},
+		{"before key", SignatureTypeGPG, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"identity valid", SignatureTypeNone, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"before identity", SignatureTypeNone, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, test := range tests {
+		err := validateValidFrom(hash, id, test.signatureType, "", object.Signature{Email: id.email, When: test.signed})
+
+		var timestampError *TimestampError
+		if test.fails != errors.As(err, &timestampError) {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
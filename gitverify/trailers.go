@@ -0,0 +1,170 @@
+package gitverify
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var trailerKeyRegex = regexp.MustCompile("^[A-Za-z0-9][A-Za-z0-9-]*$")
+var trailerLineRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*):\s*(.*)$`)
+
+type trailer struct {
+	key   string
+	value string
+}
+
+// parseTrailers returns the trailers in the last paragraph of the message. Like git, the paragraph
+// is only considered trailers if every line is a 'Key: value' pair or the continuation of one.
+func parseTrailers(message string) []trailer {
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		// the subject is never a trailer
+		return nil
+	}
+
+	trailers := make([]trailer, 0)
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if len(trailers) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			trailers[len(trailers)-1].value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		match := trailerLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			return nil
+		}
+
+		trailers = append(trailers, trailer{key: match[1], value: strings.TrimSpace(match[2])})
+	}
+
+	return trailers
+}
+
+// validateTrailers checks the required and forbidden trailers of a commit. Merge commits are not checked
+// since their content is covered by the commits being merged.
+func validateTrailers(commit *object.Commit, repoConfig *RepoConfig) error {
+	if len(repoConfig.requiredTrailers) == 0 && len(repoConfig.forbiddenTrailers) == 0 {
+		return nil
+	}
+
+	if len(commit.ParentHashes) > 1 {
+		return nil
+	}
+
+	trailers := parseTrailers(commit.Message)
+
+	messageError := func(reason string) error {
+		return &CommitMessageError{
+			Hash:     commit.Hash,
+			Identity: commit.Author.Email,
+			Reason:   reason,
+		}
+	}
+
+	for _, key := range repoConfig.forbiddenTrailers {
+		for _, t := range trailers {
+			if strings.EqualFold(t.key, key) {
+				return messageError(fmt.Sprintf("forbidden trailer '%s'", t.key))
+			}
+		}
+	}
+
+	for _, rule := range repoConfig.requiredTrailers {
+		found := false
+		for _, t := range trailers {
+			if !strings.EqualFold(t.key, rule.Key) {
+				continue
+			}
+
+			if rule.Pattern != nil && !rule.Pattern.MatchString(t.value) {
+				continue
+			}
+
+			if rule.MatchAuthor && !isSameIdentity(trailerEmail(t.value), commit.Author.Email, repoConfig) {
+				continue
+			}
+
+			found = true
+			break
+		}
+
+		if !found {
+			reason := fmt.Sprintf("missing trailer '%s'", rule.Key)
+			if rule.Pattern != nil {
+				reason += fmt.Sprintf(" matching '%s'", rule.Pattern.String())
+			}
+
+			if rule.MatchAuthor {
+				reason += fmt.Sprintf(" for author '%s'", commit.Author.Email)
+			}
+
+			return messageError(reason)
+		}
+	}
+
+	return nil
+}
+
+// validateMessagePattern checks the message of a commit on a protected branch against the pattern for the branch.
+func validateMessagePattern(commit *object.Commit, branchName string, repoConfig *RepoConfig) error {
+	pattern, found := repoConfig.messagePatterns[branchName]
+	if !found {
+		return nil
+	}
+
+	if !pattern.MatchString(commit.Message) {
+		return &CommitMessageError{
+			Hash:     commit.Hash,
+			Identity: commit.Author.Email,
+			Reason:   fmt.Sprintf("message does not match '%s'", pattern.String()),
+		}
+	}
+
+	return nil
+}
+
+// trailerEmail extracts the email from values of the form 'Name <email>'.
+func trailerEmail(value string) string {
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return ""
+	}
+
+	return address.Address
+}
+
+func isSameIdentity(email string, other string, repoConfig *RepoConfig) bool {
+	if email == "" {
+		return false
+	}
+
+	if email == other {
+		return true
+	}
+
+	a, found := lookupIdentity(email, repoConfig)
+	if !found {
+		return false
+	}
+
+	b, found := lookupIdentity(other, repoConfig)
+	if !found {
+		return false
+	}
+
+	return a.email == b.email
+}
+
+func lookupIdentity(email string, repoConfig *RepoConfig) (identity, bool) {
+	id, found := repoConfig.maintainerOrContributorEmails[email]
+	if found {
+		return id, true
+	}
+
+	id, found = repoConfig.maintainerOrContributorForgeEmails[email]
+	return id, found
+}
@@ -0,0 +1,116 @@
+package gitverify
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+	"github.com/supply-chain-tools/go-sandbox/hashset"
+)
+
+func TestParseTrailers(t *testing.T) {
+	message := "Add feature\n\nLonger description.\n\nSigned-off-by: A <a@example.internal>\nRefs: ABC-1\n  ABC-2\n"
+
+	trailers := parseTrailers(message)
+	expected := []trailer{
+		{key: "Signed-off-by", value: "A <a@example.internal>"},
+		{key: "Refs", value: "ABC-1 ABC-2"},
+	}
+
+	if len(trailers) != len(expected) {
+		t.Fatalf("got %v, want %v", trailers, expected)
+	}
+
+	for i := range expected {
+		if trailers[i] != expected[i] {
+			t.Errorf("trailers[%d]=%v, want %v", i, trailers[i], expected[i])
+		}
+	}
+
+	if trailers := parseTrailers("Fix: the subject is not a trailer"); len(trailers) != 0 {
+		t.Errorf("expected no trailers, got %v", trailers)
+	}
+
+	if trailers := parseTrailers("Subject\n\nNot a trailer\nRefs: ABC-1"); len(trailers) != 0 {
+		t.Errorf("expected no trailers, got %v", trailers)
+	}
+}
+
+func TestValidateTrailers(t *testing.T) {
+	repoConfig := &RepoConfig{
+		maintainerOrContributorEmails: map[string]identity{
+			"a@example.internal": {email: "a@example.internal"},
+			"b@example.internal": {email: "b@example.internal"},
+		},
+		requiredTrailers: []ParsedTrailerRule{
+			{Key: "Signed-off-by", MatchAuthor: true},
+			{Key: "Refs", Pattern: regexp.MustCompile("^[A-Z]+-[0-9]+$")},
+		},
+		forbiddenTrailers: []string{"Change-Id"},
+	}
+
+	tests := []struct {
+		message string
+		fails   bool
+	}{
+		{"Subject\n\nSigned-off-by: A <a@example.internal>\nRefs: ABC-1", false},
+		{"Subject\n\nsigned-off-by: A <a@example.internal>\nrefs: ABC-1", false},
+		{"Subject\n\nSigned-off-by: B <b@example.internal>\nRefs: ABC-1", true},
+		{"Subject\n\nSigned-off-by: A <a@example.internal>\nRefs: none", true},
+		{"Subject\n\nSigned-off-by: A <a@example.internal>\nRefs: ABC-1\nChange-Id: I123", true},
+		{"Subject", true},
+	}
+
+	for _, test := range tests {
+		commit := &object.Commit{
+			Hash:    plumbing.NewHash("1f46f2053221c040ce5bcba0239bc09214a37658"),
+			Author:  object.Signature{Email: "a@example.internal"},
+			Message: test.message,
+		}
+
+		err := validateTrailers(commit, repoConfig)
+
+		var commitMessageError *CommitMessageError
+		if test.fails != errors.As(err, &commitMessageError) {
+			t.Errorf("%q: got %v", test.message, err)
+		}
+	}
+}
+
+func TestMarkProtectedBranchCommits(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := commitEmpty(t, repo, "after")
+	main := commitEmpty(t, repo, "main")
+	feature := commitEmpty(t, repo, "feature")
+	setRef(t, repo, "refs/heads/master", main)
+	setRef(t, repo, "refs/heads/feature", feature)
+
+	commitMetadata := map[plumbing.Hash]*CommitData{
+		after:   {Ignore: true},
+		main:    {},
+		feature: {},
+	}
+
+	state, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = markProtectedBranchCommits(repo, state, commitMetadata, &RepoConfig{protectedBranches: hashset.New("master")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commitMetadata[after].OnProtectedBranch || !commitMetadata[main].OnProtectedBranch || commitMetadata[feature].OnProtectedBranch {
+		t.Errorf("expected only the commit after 'after' on master to be marked, got after=%v main=%v feature=%v",
+			commitMetadata[after].OnProtectedBranch, commitMetadata[main].OnProtectedBranch, commitMetadata[feature].OnProtectedBranch)
+	}
+}
@@ -0,0 +1,219 @@
+package gitverify
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"fmt"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/supply-chain-tools/go-sandbox/githash"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+type Stage string
+
+const (
+	StageHashing           Stage = "hashing"
+	StageCommits           Stage = "commits"
+	StageTags              Stage = "tags"
+	StageProtectedBranches Stage = "protected-branches"
+	StageLocalState        Stage = "local-state"
+)
+
+// Progress is a snapshot of how far verification has come. ObjectsTotal and CommitsTotal are
+// zero until the stage that sets them has started.
+type Progress struct {
+	Stage           Stage
+	ObjectsHashed   int
+	ObjectsTotal    int
+	CommitsVerified int
+	CommitsTotal    int
+}
+
+// VerifyResult is the outcome of a successful verification.
+type VerifyResult struct {
+	// BranchUpdates are the protected branches that moved since the local state was saved,
+	// empty if no store is configured.
+	BranchUpdates []BranchUpdate
+}
+
+type VerifierOptions struct {
+	// ValidateOptions selects what to verify, nil verifies all commits, tags and protected branches.
+	ValidateOptions *ValidateOptions
+	// Progress is called when the progress changes. Calls are serialized, but can come from different goroutines.
+	Progress func(Progress)
+	// ObjectIndex reads the commit and tag hashes and the SHA-1 and SHA-512 sums of commits and tags from the
+	// on-disk gitkit.ObjectIndex rather than scanning and hashing the object storage, and adds new sums to it.
+	ObjectIndex bool
+}
+
+// Verifier verifies a repository against a config, with the local state loaded from and saved to a
+// LocalStateStore. It does not read the working directory or print anything.
+type Verifier struct {
+	repo       *git.Repository
+	repoConfig *RepoConfig
+	store      LocalStateStore
+	opts       VerifierOptions
+}
+
+// NewVerifier returns a Verifier for the repository. The store can be nil to not use local state.
+func NewVerifier(repo *git.Repository, repoConfig *RepoConfig, store LocalStateStore, opts *VerifierOptions) *Verifier {
+	v := &Verifier{
+		repo:       repo,
+		repoConfig: repoConfig,
+		store:      store,
+	}
+
+	if opts != nil {
+		v.opts = *opts
+	}
+
+	return v
+}
+
+// Verify loads the repository state and verifies it. If a store is configured, the local state is
+// verified and then updated, and the result has the protected branches that moved since it was
+// saved. Verification stops with the context error if ctx is cancelled.
+func (v *Verifier) Verify(ctx context.Context) (*VerifyResult, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := newProgressReporter(v.opts.Progress)
+	progress.stage(StageHashing)
+
+	var index *gitkit.ObjectIndex
+	var state *gitkit.LazyRepoState
+	if v.opts.ObjectIndex {
+		index, err = gitkit.OpenObjectIndex(v.repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open object index: %w", err)
+		}
+
+		state, err = gitkit.NewLazyRepoStateFromIndex(v.repo, index, gitkit.DefaultObjectCacheSize)
+	} else {
+		state, err = gitkit.NewLazyRepoState(v.repo, gitkit.DefaultObjectCacheSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gitHashSHA1 := githash.NewGitHashFromRepoStateFunc(state, sha1.New)
+	gitHashSHA512 := githash.NewGitHashFromRepoStateFunc(state, sha512.New)
+	if index != nil {
+		gitHashSHA1 = githash.NewGitHashFromIndex(state, index, "sha1", sha1.New)
+		gitHashSHA512 = githash.NewGitHashFromIndex(state, index, "sha512", sha512.New)
+	}
+
+	err = verify(ctx, v.repo, state, v.repoConfig, gitHashSHA1, gitHashSHA512, v.opts.ValidateOptions, progress)
+	if index != nil {
+		// the sums are correct even if verification failed
+		saveErr := index.Save()
+		if err == nil && saveErr != nil {
+			return nil, fmt.Errorf("failed to save object index: %w", saveErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if v.store == nil {
+		return &VerifyResult{}, nil
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	progress.stage(StageLocalState)
+
+	localState, err := v.store.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local state: %w", err)
+	}
+
+	branchUpdates, err := verifyLocalState(v.repo, state, v.repoConfig, localState, gitHashSHA1, gitHashSHA512)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify local state: %w", err)
+	}
+
+	newLocalState, err := computeLocalState(v.repo, state, v.repoConfig, gitHashSHA1, gitHashSHA512)
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.store.Save(ctx, newLocalState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save local state: %w", err)
+	}
+
+	return &VerifyResult{BranchUpdates: branchUpdates}, nil
+}
+
+// progressReporter keeps track of progress and serializes the calls to the callback. All
+// methods are no-ops on a nil reporter.
+type progressReporter struct {
+	mutex    sync.Mutex
+	progress Progress
+	f        func(Progress)
+}
+
+func newProgressReporter(f func(Progress)) *progressReporter {
+	if f == nil {
+		return nil
+	}
+
+	return &progressReporter{
+		f: f,
+	}
+}
+
+func (p *progressReporter) update(u func(progress *Progress)) {
+	if p == nil {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	u(&p.progress)
+	p.f(p.progress)
+}
+
+func (p *progressReporter) stage(stage Stage) {
+	p.update(func(progress *Progress) {
+		progress.Stage = stage
+	})
+}
+
+func (p *progressReporter) startHashing(total int) {
+	p.update(func(progress *Progress) {
+		progress.Stage = StageHashing
+		progress.ObjectsTotal = total
+	})
+}
+
+func (p *progressReporter) hashed(n int) {
+	p.update(func(progress *Progress) {
+		progress.ObjectsHashed += n
+	})
+}
+
+// startVerifying adds to the total, since commits can be verified in more than one pass, e.g. for
+// both the commit and the branch in ValidateOptions.
+func (p *progressReporter) startVerifying(total int) {
+	p.update(func(progress *Progress) {
+		progress.Stage = StageCommits
+		progress.CommitsTotal += total
+	})
+}
+
+func (p *progressReporter) verified(n int) {
+	p.update(func(progress *Progress) {
+		progress.CommitsVerified += n
+	})
+}
@@ -0,0 +1,258 @@
+package gitverify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/supply-chain-tools/go-sandbox/githash"
+	"github.com/supply-chain-tools/go-sandbox/gitkit"
+)
+
+type memoryLocalStateStore struct {
+	localState *LocalState
+}
+
+func (s *memoryLocalStateStore) Load(ctx context.Context) (*LocalState, error) {
+	return s.localState, nil
+}
+
+func (s *memoryLocalStateStore) Save(ctx context.Context, localState *LocalState) error {
+	s.localState = localState
+	return nil
+}
+
+func TestVerifier(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := commitEmpty(t, repo, "first")
+	setRef(t, repo, "refs/tags/v1", after)
+
+	config := `
+{
+  "_type": "https://supply-chain-tools.github.io/schemas/gitverify/v0.1",
+  "identities": [{"email": "a@example.internal"}],
+  "maintainers": ["a@example.internal"],
+  "rules": {"requireSignedTags": false},
+  "repositories": [{
+    "uri": "git+https://github.com/foo/bar.git",
+    "after": [{"sha1": "` + after.String() + `"}]
+  }]
+}
+`
+	runnerConfig := &Config{}
+	err = json.Unmarshal([]byte(config), runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseConfig(runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRepoConfig := func() *RepoConfig {
+		repoConfig, err := LoadRepoConfig(parsed, "git+https://github.com/foo/bar.git")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repoConfig
+	}
+
+	store := &memoryLocalStateStore{}
+	var last Progress
+	stages := make(map[Stage]bool)
+
+	verifier := NewVerifier(repo, newRepoConfig(), store, &VerifierOptions{
+		Progress: func(progress Progress) {
+			last = progress
+			stages[progress.Stage] = true
+		},
+	})
+
+	result, err := verifier.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result == nil || len(result.BranchUpdates) != 0 {
+		t.Errorf("expected no branch updates on the first run, got %+v", result)
+	}
+
+	if last.ObjectsTotal == 0 || last.ObjectsHashed != last.ObjectsTotal {
+		t.Errorf("ObjectsHashed=%d, ObjectsTotal=%d", last.ObjectsHashed, last.ObjectsTotal)
+	}
+
+	if last.CommitsTotal != 1 || last.CommitsVerified != 1 {
+		t.Errorf("CommitsVerified=%d, CommitsTotal=%d, want 1", last.CommitsVerified, last.CommitsTotal)
+	}
+
+	for _, stage := range []Stage{StageHashing, StageCommits, StageTags, StageProtectedBranches, StageLocalState} {
+		if !stages[stage] {
+			t.Errorf("stage %s not reported", stage)
+		}
+	}
+
+	if store.localState == nil || len(store.localState.Tags) != 1 {
+		t.Fatalf("expected local state with one tag, got %v", store.localState)
+	}
+
+	// the second run reads the hashes and sums from the index written by the first
+	t.Setenv("HOME", t.TempDir())
+	for i := 0; i < 2; i++ {
+		_, err = NewVerifier(repo, newRepoConfig(), store, &VerifierOptions{ObjectIndex: true}).Verify(context.Background())
+		if err != nil {
+			t.Fatalf("run %d with object index: %v", i, err)
+		}
+	}
+
+	index, err := gitkit.OpenObjectIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, found := index.Digest("sha1", after)
+	if !found || !bytes.Equal(sum, after[:]) {
+		t.Errorf("expected the verified SHA-1 of %s in the index, got %x", after, sum)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = NewVerifier(repo, newRepoConfig(), store, nil).Verify(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestVerifierValidateOptionsProgress(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := commitEmpty(t, repo, "first")
+
+	config := `
+{
+  "_type": "https://supply-chain-tools.github.io/schemas/gitverify/v0.1",
+  "identities": [{"email": "a@example.internal"}],
+  "maintainers": ["a@example.internal"],
+  "rules": {"requireSignedTags": false},
+  "repositories": [{
+    "uri": "git+https://github.com/foo/bar.git",
+    "after": [{"sha1": "` + after.String() + `"}]
+  }]
+}
+`
+	var last Progress
+	verifier := NewVerifier(repo, loadTestRepoConfig(t, config), &memoryLocalStateStore{}, &VerifierOptions{
+		ValidateOptions: &ValidateOptions{Commit: after.String(), VerifyOnHEAD: true},
+		Progress: func(progress Progress) {
+			last = progress
+		},
+	})
+
+	_, err = verifier.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last.CommitsTotal != 1 || last.CommitsVerified != 1 {
+		t.Errorf("CommitsVerified=%d, CommitsTotal=%d, want 1", last.CommitsVerified, last.CommitsTotal)
+	}
+}
+
+func TestVerifyLocalStateBranchUpdates(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := commitEmpty(t, repo, "first")
+	setRef(t, repo, "refs/remotes/origin/main", first)
+
+	config := `
+{
+  "_type": "https://supply-chain-tools.github.io/schemas/gitverify/v0.1",
+  "identities": [{"email": "a@example.internal"}],
+  "maintainers": ["a@example.internal"],
+  "rules": {"requireSignedTags": false},
+  "protectedBranches": ["main"],
+  "repositories": [{
+    "uri": "git+https://github.com/foo/bar.git",
+    "after": [{"sha1": "` + first.String() + `", "branch": "main"}]
+  }]
+}
+`
+	repoConfig := loadTestRepoConfig(t, config)
+
+	state, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitHashSHA1 := githash.NewGitHashFromRepoStateFunc(state, sha1.New)
+	gitHashSHA512 := githash.NewGitHashFromRepoStateFunc(state, sha512.New)
+
+	localState, err := computeLocalState(repo, state, repoConfig, gitHashSHA1, gitHashSHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := verifyLocalState(repo, state, repoConfig, localState, gitHashSHA1, gitHashSHA512)
+	if err != nil || len(updates) != 0 {
+		t.Fatalf("expected no updates, got %v, %v", updates, err)
+	}
+
+	second := commitEmpty(t, repo, "second")
+	setRef(t, repo, "refs/remotes/origin/main", second)
+
+	state, err = gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitHashSHA1 = githash.NewGitHashFromRepoStateFunc(state, sha1.New)
+	gitHashSHA512 = githash.NewGitHashFromRepoStateFunc(state, sha512.New)
+
+	updates, err = verifyLocalState(repo, state, repoConfig, localState, gitHashSHA1, gitHashSHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := BranchUpdate{Ref: "refs/remotes/origin/main", From: first, To: second}
+	if len(updates) != 1 || updates[0] != expected {
+		t.Errorf("got %v, want %v", updates, expected)
+	}
+}
+
+func loadTestRepoConfig(t *testing.T, config string) *RepoConfig {
+	t.Helper()
+
+	runnerConfig := &Config{}
+	err := json.Unmarshal([]byte(config), runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseConfig(runnerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoConfig, err := LoadRepoConfig(parsed, "git+https://github.com/foo/bar.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repoConfig
+}
@@ -2,6 +2,7 @@ package gitverify
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"github.com/go-git/go-git/v5"
@@ -12,6 +13,7 @@ import (
 	"github.com/supply-chain-tools/go-sandbox/hashset"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type ValidateOptions struct {
@@ -20,13 +22,53 @@ type ValidateOptions struct {
 	Branch       string
 	VerifyOnHEAD bool
 	VerifyOnTip  bool
+	// Concurrency is the number of workers used for hashing and signature verification,
+	// defaults to the number of CPUs.
+	Concurrency int
 }
 
 const hexSHA1Regex = "^[a-f0-9]{40}$"
 const hexSHA512Regex = "^[a-f0-9]{128}$"
 
-func Verify(repo *git.Repository, state *gitkit.RepoState, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, opts *ValidateOptions) error {
-	commitMetadata, err := computeCommitMetadata(state, repoConfig, gitHashSHA1, gitHashSHA512)
+func Verify(repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, opts *ValidateOptions) error {
+	return verify(context.Background(), repo, state, repoConfig, gitHashSHA1, gitHashSHA512, opts, nil)
+}
+
+func verify(ctx context.Context, repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, opts *ValidateOptions, progress *progressReporter) error {
+	gitDir, err := gitDirectory(repo)
+	if err != nil {
+		return err
+	}
+
+	concurrency := defaultConcurrency()
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+
+	gitHashes := []githash.GitHash{gitHashSHA1}
+	if repoConfig.afterSHA512.Size() > 0 {
+		gitHashes = append(gitHashes, gitHashSHA512)
+	}
+
+	err = precomputeHashes(ctx, state, concurrency, progress, gitHashes...)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	commitMetadata, err := computeCommitMetadata(state, repoConfig, gitHashSHA1, gitHashSHA512, now)
+	if err != nil {
+		return err
+	}
+
+	err = markProtectedBranchCommits(repo, state, commitMetadata, repoConfig)
+	if err != nil {
+		return err
+	}
+	progress.hashed(len(state.CommitHashes()))
+
+	err = ctx.Err()
 	if err != nil {
 		return err
 	}
@@ -41,24 +83,30 @@ func Verify(repo *git.Repository, state *gitkit.RepoState, repoConfig *RepoConfi
 			return fmt.Errorf("target commit must be a 40 character hex, not '%s'", opts.Commit)
 		}
 
-		err = validateOpts(opts, repo, state, commitMetadata, repoConfig, gitHashSHA1, gitHashSHA512)
+		err = validateOpts(opts, repo, state, commitMetadata, repoConfig, gitDir, gitHashSHA1, gitHashSHA512, now, progress)
 		if err != nil {
 			return err
 		}
 	} else {
-		for _, commit := range state.CommitMap {
-			err := validateCommit(commit, commitMetadata, repoConfig)
-			if err != nil {
-				return err
-			}
+		err = validateCommitsConcurrently(ctx, state, commitMetadata, repoConfig, gitDir, concurrency, progress)
+		if err != nil {
+			return err
 		}
 
-		err = validateTags(repo, state, repoConfig, gitHashSHA1, gitHashSHA512)
+		progress.stage(StageTags)
+		err = validateTags(repo, state, repoConfig, gitHashSHA1, gitHashSHA512, now)
 		if err != nil {
 			return err
 		}
 
-		err = validateProtectedBranches(repo, state, commitMetadata, repoConfig)
+		err = ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		progress.stage(StageProtectedBranches)
+
+		err = validateProtectedBranches(repo, state, commitMetadata, repoConfig, gitDir)
 		if err != nil {
 			return err
 		}
@@ -67,7 +115,7 @@ func Verify(repo *git.Repository, state *gitkit.RepoState, repoConfig *RepoConfi
 	return nil
 }
 
-func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*CommitData, repoConfig *RepoConfig) error {
+func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*CommitData, repoConfig *RepoConfig, gitDir string) error {
 	metadata, found := commitMetadata[commit.Hash]
 	if !found {
 		return fmt.Errorf("commit not processed: %s", commit.Hash)
@@ -77,6 +125,21 @@ func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*Com
 		return nil
 	}
 
+	if metadata.parentsError != nil {
+		return metadata.parentsError
+	}
+
+	if metadata.timestampError != nil {
+		return metadata.timestampError
+	}
+
+	if repoConfig.trailerScope == TrailerScopeAll || metadata.OnProtectedBranch {
+		err := validateTrailers(commit, repoConfig)
+		if err != nil {
+			return err
+		}
+	}
+
 	email := commit.Committer.Email
 
 	if repoConfig.forge != nil {
@@ -94,7 +157,11 @@ func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*Com
 			if !found {
 				_, found := repoConfig.maintainerOrContributorForgeEmails[commit.Author.Email]
 				if !found {
-					return fmt.Errorf("author email '%s' not found for forge commit: %s", commit.Author.Email, commit.Hash.String())
+					return &UnknownIdentityError{
+						Hash:     commit.Hash,
+						Identity: commit.Author.Email,
+						Role:     "maintainer or contributor author",
+					}
 				}
 			}
 
@@ -103,7 +170,7 @@ func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*Com
 			}
 
 			if repoConfig.forge.allowMergeCommits && !repoConfig.forge.allowContentCommits {
-				err := verifyMergeCommitNoContentChanges(commit)
+				err := verifyMergeCommitNoContentChanges(gitDir, commit)
 				if err != nil {
 					return fmt.Errorf("failed to verify forge merge commit %s to not have content changes: %s", commit.Hash.String(), err)
 				}
@@ -117,13 +184,22 @@ func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*Com
 
 	id, found := repoConfig.maintainerOrContributorEmails[email]
 	if !found {
-		return fmt.Errorf("no maintainer with email '%s' for commit %s", email, commit.Hash)
+		return &UnknownIdentityError{
+			Hash:     commit.Hash,
+			Identity: email,
+			Role:     "maintainer or contributor",
+		}
+	}
+
+	err := validateValidFrom(commit.Hash, id, metadata.SignatureType, commit.PGPSignature, commit.Committer)
+	if err != nil {
+		return err
 	}
 
 	switch metadata.SignatureType {
 	case SignatureTypeSSH:
 		content := buildContent(commit)
-		err := validateSSH(content, commit.PGPSignature, id, repoConfig)
+		err := validateSSH(commit.Hash, content, commit.PGPSignature, id, repoConfig)
 		if err != nil {
 			return fmt.Errorf("failed to validate commit %s: %w", commit.Hash.String(), err)
 		}
@@ -133,7 +209,10 @@ func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*Com
 			return err
 		}
 	case SignatureTypeNone:
-		return fmt.Errorf("unsigned commit: %s", commit.Hash.String())
+		return &UnsignedCommitError{
+			Hash:     commit.Hash,
+			Identity: email,
+		}
 	default:
 		return fmt.Errorf("unknown signature type for commit: %s", commit.Hash.String())
 	}
@@ -143,7 +222,7 @@ func validateCommit(commit *object.Commit, commitMetadata map[plumbing.Hash]*Com
 	return nil
 }
 
-func validateOpts(opts *ValidateOptions, repo *git.Repository, state *gitkit.RepoState, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) error {
+func validateOpts(opts *ValidateOptions, repo *git.Repository, state gitkit.RepoObjects, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig, gitDir string, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, now time.Time, progress *progressReporter) error {
 	head, err := repo.Head()
 	if err != nil {
 		return err
@@ -151,12 +230,12 @@ func validateOpts(opts *ValidateOptions, repo *git.Repository, state *gitkit.Rep
 
 	headHash := head.Hash()
 
-	c, found := state.CommitMap[plumbing.NewHash(opts.Commit)]
-	if !found {
-		return fmt.Errorf("target commit '%s' not found", opts.Commit)
+	c, err := state.Commit(plumbing.NewHash(opts.Commit))
+	if err != nil {
+		return fmt.Errorf("target commit '%s' not found: %w", opts.Commit, err)
 	}
 
-	err = validateCommitsRecursively(c, state, commitMetadata, config)
+	err = validateCommitsRecursively(c, state, commitMetadata, config, gitDir, progress)
 	if err != nil {
 		return err
 	}
@@ -181,12 +260,16 @@ func validateOpts(opts *ValidateOptions, repo *git.Repository, state *gitkit.Rep
 			tagName := strings.TrimPrefix(tag.Name().String(), "refs/tags/")
 
 			if tagName == opts.Tag {
-				err := validateTag(tag, state, config, gitHashSHA1, gitHashSHA512)
+				err := validateTag(tag, state, config, gitHashSHA1, gitHashSHA512, now)
+				if err != nil {
+					return err
+				}
+
+				t, found, err := lookupTag(state, tag.Hash())
 				if err != nil {
 					return err
 				}
 
-				t, found := state.TagMap[tag.Hash()]
 				if found {
 					// annotated tag
 					tagHash = &t.Target
@@ -228,18 +311,18 @@ func validateOpts(opts *ValidateOptions, repo *git.Repository, state *gitkit.Rep
 			if branchName == opts.Branch {
 				branchFound = true
 
-				c, found := state.CommitMap[reference.Hash()]
-				if !found {
-					return fmt.Errorf("commit '%s' not found", reference.Hash().String())
+				c, err := state.Commit(reference.Hash())
+				if err != nil {
+					return fmt.Errorf("commit '%s' not found: %w", reference.Hash().String(), err)
 				}
 
-				err := validateCommitsRecursively(c, state, commitMetadata, config)
+				err = validateCommitsRecursively(c, state, commitMetadata, config, gitDir, progress)
 				if err != nil {
-					return err
+					return withRef(err, reference.Name().String())
 				}
 
 				if isProtected {
-					err := validateProtectedBranch(reference, branchName, state, commitMetadata, config)
+					err := validateProtectedBranch(reference, branchName, state, commitMetadata, config, gitDir)
 					if err != nil {
 						return fmt.Errorf("failed to validate protected branch '%s' rules: %w", reference.Name(), err)
 					}
@@ -250,9 +333,9 @@ func validateOpts(opts *ValidateOptions, repo *git.Repository, state *gitkit.Rep
 						return fmt.Errorf("target commit %s does not point to the tip of branch '%s'", targetHash.String(), reference.Name())
 					}
 				} else {
-					err = validateOnBranch(targetHash, branchName, c, state, commitMetadata, config)
+					err = validateOnBranch(targetHash, branchName, c, state, commitMetadata, config, gitDir)
 					if err != nil {
-						return err
+						return withRef(err, reference.Name().String())
 					}
 				}
 			}
@@ -270,7 +353,7 @@ func validateOpts(opts *ValidateOptions, repo *git.Repository, state *gitkit.Rep
 	return nil
 }
 
-func validateOnBranch(targetHash plumbing.Hash, branchName string, c *object.Commit, state *gitkit.RepoState, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig) error {
+func validateOnBranch(targetHash plumbing.Hash, branchName string, c *object.Commit, state gitkit.RepoObjects, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig, gitDir string) error {
 	current := c
 
 	for {
@@ -283,12 +366,12 @@ func validateOnBranch(targetHash plumbing.Hash, branchName string, c *object.Com
 		}
 
 		parentHash := current.ParentHashes[0]
-		parent, found := state.CommitMap[parentHash]
-		if !found {
-			return fmt.Errorf("target parent hash not found: %s", parentHash)
+		parent, err := state.Commit(parentHash)
+		if err != nil {
+			return fmt.Errorf("target parent hash not found: %s: %w", parentHash, err)
 		}
 
-		err := validateCommit(parent, commitMetadata, config)
+		err = validateCommit(parent, commitMetadata, config, gitDir)
 		if err != nil {
 			return err
 		}
@@ -299,8 +382,9 @@ func validateOnBranch(targetHash plumbing.Hash, branchName string, c *object.Com
 	return nil
 }
 
-func validateCommitsRecursively(c *object.Commit, state *gitkit.RepoState, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig) error {
-	err := validateCommit(c, commitMetadata, config)
+// validateCommitsRecursively validates c and its ancestors that are not ignored.
+func validateCommitsRecursively(c *object.Commit, state gitkit.RepoObjects, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig, gitDir string, progress *progressReporter) error {
+	err := validateCommit(c, commitMetadata, config, gitDir)
 	if err != nil {
 		return err
 	}
@@ -308,6 +392,7 @@ func validateCommitsRecursively(c *object.Commit, state *gitkit.RepoState, commi
 	visited := hashset.New[plumbing.Hash]()
 	visited.Add(c.Hash)
 	queue := []*object.Commit{c}
+	commits := make([]*object.Commit, 0)
 
 	for {
 		if len(queue) == 0 {
@@ -319,17 +404,13 @@ func validateCommitsRecursively(c *object.Commit, state *gitkit.RepoState, commi
 
 		for _, parentHash := range current.ParentHashes {
 			if !visited.Contains(parentHash) {
-				parent, found := state.CommitMap[parentHash]
-				if !found {
-					return fmt.Errorf("target parent hash not found: %s", parentHash)
+				parent, err := state.Commit(parentHash)
+				if err != nil {
+					return fmt.Errorf("target parent hash not found: %s: %w", parentHash, err)
 				}
 
 				if !commitMetadata[parent.Hash].Ignore {
-					err := validateCommit(parent, commitMetadata, config)
-					if err != nil {
-						return err
-					}
-
+					commits = append(commits, parent)
 					queue = append(queue, parent)
 					visited.Add(parentHash)
 				}
@@ -337,17 +418,28 @@ func validateCommitsRecursively(c *object.Commit, state *gitkit.RepoState, commi
 		}
 	}
 
+	progress.startVerifying(len(commits) + 1)
+	progress.verified(1)
+
+	for _, commit := range commits {
+		err := validateCommit(commit, commitMetadata, config, gitDir)
+		if err != nil {
+			return err
+		}
+		progress.verified(1)
+	}
+
 	return nil
 }
 
-func verifyConnectedToSpecificAfter(commit *object.Commit, after plumbing.Hash, state *gitkit.RepoState, allowCommitsBeforeAfter bool) error {
+func verifyConnectedToSpecificAfter(commit *object.Commit, after plumbing.Hash, state gitkit.RepoObjects, allowCommitsBeforeAfter bool) error {
 	if commit.Hash == after {
 		return nil
 	}
 
-	afterCommit, found := state.CommitMap[after]
-	if !found {
-		return fmt.Errorf("target after hash not found: %s", after.String())
+	afterCommit, err := state.Commit(after)
+	if err != nil {
+		return fmt.Errorf("target after hash not found: %s: %w", after.String(), err)
 	}
 
 	// see if commit is a descendant of after
@@ -377,7 +469,7 @@ func verifyConnectedToSpecificAfter(commit *object.Commit, after plumbing.Hash,
 	return nil
 }
 
-func isLeftDescendant(a *object.Commit, b *object.Commit, state *gitkit.RepoState) (bool, error) {
+func isLeftDescendant(a *object.Commit, b *object.Commit, state gitkit.RepoObjects) (bool, error) {
 	current := a
 
 	for {
@@ -391,16 +483,16 @@ func isLeftDescendant(a *object.Commit, b *object.Commit, state *gitkit.RepoStat
 
 		parentHash := current.ParentHashes[0]
 
-		parent, found := state.CommitMap[parentHash]
-		if !found {
-			return false, fmt.Errorf("target parent hash not found: %s", parentHash)
+		parent, err := state.Commit(parentHash)
+		if err != nil {
+			return false, fmt.Errorf("target parent hash not found: %s: %w", parentHash, err)
 		}
 
 		current = parent
 	}
 }
 
-func validateProtectedBranches(repo *git.Repository, state *gitkit.RepoState, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig) error {
+func validateProtectedBranches(repo *git.Repository, state gitkit.RepoObjects, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig, gitDir string) error {
 	remotes, err := repo.References()
 	if err != nil {
 		return err
@@ -410,7 +502,7 @@ func validateProtectedBranches(repo *git.Repository, state *gitkit.RepoState, co
 		isProtected, branchName := isProtected(reference, config)
 
 		if isProtected {
-			err := validateProtectedBranch(reference, branchName, state, commitMetadata, config)
+			err := validateProtectedBranch(reference, branchName, state, commitMetadata, config, gitDir)
 			if err != nil {
 				return err
 			}
@@ -424,32 +516,67 @@ func validateProtectedBranches(repo *git.Repository, state *gitkit.RepoState, co
 	return nil
 }
 
-func validateProtectedBranch(reference *plumbing.Reference, branchName string, state *gitkit.RepoState, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig) error {
+func validateProtectedBranch(reference *plumbing.Reference, branchName string, state gitkit.RepoObjects, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig, gitDir string) error {
+	ref := reference.Name().String()
+
 	targetAfter, found := config.branchToSHA1[branchName]
 	if !found {
-		return fmt.Errorf("protected branch '%s' without matching after branch", branchName)
+		return &ProtectedBranchViolation{
+			Hash:   reference.Hash(),
+			Ref:    ref,
+			Reason: fmt.Sprintf("protected branch '%s' without matching after branch", branchName),
+		}
 	}
 
-	current, found := state.CommitMap[reference.Hash()]
-	if !found {
-		return fmt.Errorf("did not find commit %s", reference.Hash().String())
+	current, err := state.Commit(reference.Hash())
+	if err != nil {
+		return fmt.Errorf("did not find commit %s: %w", reference.Hash().String(), err)
+	}
+
+	strategy := branchStrategy(branchName, config)
+
+	violation := func(reason string) error {
+		return &ProtectedBranchViolation{
+			Hash:     current.Hash,
+			Ref:      ref,
+			Identity: current.Committer.Email,
+			Reason:   reason,
+		}
 	}
 
 	for {
-		err := validateCommit(current, commitMetadata, config)
+		err := validateCommit(current, commitMetadata, config, gitDir)
 		if err != nil {
-			return err
+			return withRef(err, ref)
 		}
 
 		if current.Hash == targetAfter {
 			break
 		}
 
-		if config.requireMergeCommits {
+		err = validateMessagePattern(current, branchName, config)
+		if err != nil {
+			return withRef(err, ref)
+		}
+
+		switch strategy {
+		case BranchStrategyMerge:
 			if len(current.ParentHashes) != 2 {
-				return fmt.Errorf("requireMergeCommits is set, but commit %s on protected branch has %d parents", current.Hash.String(), len(current.ParentHashes))
+				return violation(fmt.Sprintf("branch strategy is merge, but commit %s on protected branch has %d parents", current.Hash.String(), len(current.ParentHashes)))
+			}
+		case BranchStrategyLinear, BranchStrategySquash:
+			if len(current.ParentHashes) > 1 {
+				return violation(fmt.Sprintf("branch strategy is %s, but commit %s on protected branch is a merge commit", strategy, current.Hash.String()))
 			}
 
+			_, found := config.maintainerEmails[current.Committer.Email]
+			if !found && strategy == BranchStrategySquash && config.forge != nil {
+				found = current.Committer.Email == config.forge.email
+			}
+
+			if !found {
+				return violation(fmt.Sprintf("branch strategy is %s, but commit %s was made by %s which is not a maintainer", strategy, current.Hash.String(), current.Committer.Email))
+			}
 		}
 
 		if len(current.ParentHashes) == 2 {
@@ -463,53 +590,53 @@ func validateProtectedBranch(reference *plumbing.Reference, branchName string, s
 				}
 
 				if !found {
-					return fmt.Errorf("merge commit %s made by %s which is not a maintainer", current.Hash.String(), current.Committer.Email)
+					return violation(fmt.Sprintf("merge commit %s made by %s which is not a maintainer", current.Hash.String(), current.Committer.Email))
 				}
 			}
 
 			metadata := commitMetadata[current.Hash]
 			if !metadata.VerifiedToNotHaveContentChanges {
-				err := verifyMergeCommitNoContentChanges(current)
+				err := verifyMergeCommitNoContentChanges(gitDir, current)
 				if err != nil {
-					return fmt.Errorf("failed to verify protected merge commit %s to not have content changes: %s", current.Hash.String(), err)
+					return violation(fmt.Sprintf("failed to verify protected merge commit %s to not have content changes: %s", current.Hash.String(), err))
 				}
 
 				metadata.VerifiedToNotHaveContentChanges = true
 			}
 
 			if config.requireUpToDate {
-				mergeBase, err := gitMergeBase(current.ParentHashes[0].String(), current.ParentHashes[1].String())
+				mergeBase, err := gitMergeBase(gitDir, current.ParentHashes[0].String(), current.ParentHashes[1].String())
 				if err != nil {
 					return fmt.Errorf("failed to find merge base for parent commits of %s: %w", current.Hash.String(), err)
 				}
 
 				if mergeBase != current.ParentHashes[0].String() {
-					return fmt.Errorf("second parent of %s is not up to date with first", current.Hash.String())
+					return violation(fmt.Sprintf("second parent of %s is not up to date with first", current.Hash.String()))
 				}
 			}
 		}
 
 		if len(current.ParentHashes) == 0 {
-			return fmt.Errorf("protected branch %s is not a decendant of after", reference.Name().String())
+			return violation(fmt.Sprintf("protected branch %s is not a decendant of after", ref))
 		}
 
-		current, found = state.CommitMap[current.ParentHashes[0]]
-		if !found {
-			return fmt.Errorf("did not find commit %s", reference.Hash().String())
+		current, err = state.Commit(current.ParentHashes[0])
+		if err != nil {
+			return fmt.Errorf("did not find commit %s: %w", reference.Hash().String(), err)
 		}
 	}
 
 	return nil
 }
 
-func validateTags(repo *git.Repository, state *gitkit.RepoState, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) error {
+func validateTags(repo *git.Repository, state gitkit.RepoObjects, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, now time.Time) error {
 	tags, err := repo.Tags()
 	if err != nil {
 		return err
 	}
 
 	err = tags.ForEach(func(tag *plumbing.Reference) error {
-		return validateTag(tag, state, repoConfig, gitHashSHA1, gitHashSHA512)
+		return validateTag(tag, state, repoConfig, gitHashSHA1, gitHashSHA512, now)
 	})
 	if err != nil {
 		return err
@@ -518,18 +645,26 @@ func validateTags(repo *git.Repository, state *gitkit.RepoState, repoConfig *Rep
 	return nil
 }
 
-func validateTag(tag *plumbing.Reference, state *gitkit.RepoState, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) error {
+func validateTag(tag *plumbing.Reference, state gitkit.RepoObjects, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, now time.Time) error {
 	isExempted := false
 
 	tagHash, found := repoConfig.exemptedTags[tag.Name().String()]
 	if found {
 		if tagHash != tag.Hash().String() {
-			return fmt.Errorf("wrong hash.sha1 for exempted tag '%s', got %s, expected %s", tag.Name().String(), tag.Hash().String(), tagHash)
+			return &TagTeleportError{
+				Hash:     tag.Hash(),
+				Ref:      tag.Name().String(),
+				Expected: tagHash,
+				Actual:   tag.Hash().String(),
+			}
 		}
 		isExempted = true
 	}
 
-	t, isAnnotatedTag := state.TagMap[tag.Hash()]
+	t, isAnnotatedTag, err := lookupTag(state, tag.Hash())
+	if err != nil {
+		return err
+	}
 
 	tagHashSHA512, found := repoConfig.exemptedTagsSHA512[tag.Name().String()]
 	if found {
@@ -549,7 +684,12 @@ func validateTag(tag *plumbing.Reference, state *gitkit.RepoState, repoConfig *R
 
 		h := hex.EncodeToString(sha512Hash)
 		if tagHashSHA512 != h {
-			return fmt.Errorf("wrong SHA-512 for exempted tag '%s', got %s, expected %s", tag.Name().String(), h, tagHashSHA512)
+			return &TagTeleportError{
+				Hash:     tag.Hash(),
+				Ref:      tag.Name().String(),
+				Expected: tagHashSHA512,
+				Actual:   h,
+			}
 		}
 		isExempted = true
 	}
@@ -568,7 +708,22 @@ func validateTag(tag *plumbing.Reference, state *gitkit.RepoState, repoConfig *R
 
 			id, found := repoConfig.maintainerEmails[t.Tagger.Email]
 			if !found {
-				return fmt.Errorf("no maintainer with email '%s' for tag %s", t.Tagger.Email, t.Name)
+				return &UnknownIdentityError{
+					Hash:     t.Hash,
+					Ref:      tag.Name().String(),
+					Identity: t.Tagger.Email,
+					Role:     "maintainer",
+				}
+			}
+
+			err = validateTagTimestamps(t, state, repoConfig, now)
+			if err != nil {
+				return err
+			}
+
+			err = validateValidFrom(t.Hash, id, signatureType, t.PGPSignature, t.Tagger)
+			if err != nil {
+				return withRef(err, tag.Name().String())
 			}
 
 			switch signatureType {
@@ -577,9 +732,9 @@ func validateTag(tag *plumbing.Reference, state *gitkit.RepoState, repoConfig *R
 				if err != nil {
 					return err
 				}
-				err = validateSSH(content, t.PGPSignature, id, repoConfig)
+				err = validateSSH(t.Hash, content, t.PGPSignature, id, repoConfig)
 				if err != nil {
-					return fmt.Errorf("failed to validate tag %s: %w", t.Name, err)
+					return fmt.Errorf("failed to validate tag %s: %w", t.Name, withRef(err, tag.Name().String()))
 				}
 			case SignatureTypeGPG:
 				err := validateIdentityGPGTag(t, id, repoConfig)
@@ -619,15 +774,31 @@ func tagContent(tag *object.Tag) (string, error) {
 	return sb.String(), nil
 }
 
-func computeCommitMetadata(state *gitkit.RepoState, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash) (map[plumbing.Hash]*CommitData, error) {
+func computeCommitMetadata(state gitkit.RepoObjects, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, now time.Time) (map[plumbing.Hash]*CommitData, error) {
+	return buildCommitMetadata(state, repoConfig, gitHashSHA1, gitHashSHA512, now, true)
+}
+
+// buildCommitMetadata computes the metadata of every commit in state. If strictParents is false a commit with more
+// than two parents, like the stash commit created by 'git stash -u', does not fail the whole computation; the error
+// is returned by validateCommit for that commit instead.
+func buildCommitMetadata(state gitkit.RepoObjects, repoConfig *RepoConfig, gitHashSHA1 githash.GitHash, gitHashSHA512 githash.GitHash, now time.Time, strictParents bool) (map[plumbing.Hash]*CommitData, error) {
 	commitMap := make(map[plumbing.Hash]*CommitData)
 
 	foundAfterSHA1 := hashset.New[plumbing.Hash]()
 	foundAfterSHA512 := hashset.New[[64]byte]()
 
-	for hash, commit := range state.CommitMap {
+	for _, hash := range state.CommitHashes() {
+		commit, err := state.Commit(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		var parentsError error
 		if len(commit.ParentHashes) > 2 {
-			return nil, fmt.Errorf("up to two parents are allowed, commit '%s' has %d", hash.String(), len(commit.ParentHashes))
+			parentsError = fmt.Errorf("up to two parents are allowed, commit '%s' has %d", hash.String(), len(commit.ParentHashes))
+			if strictParents {
+				return nil, parentsError
+			}
 		}
 
 		verifiedSHA1, err := gitHashSHA1.CommitSum(hash)
@@ -715,7 +886,9 @@ func computeCommitMetadata(state *gitkit.RepoState, repoConfig *RepoConfig, gitH
 			}
 
 			commitMap[hash] = &CommitData{
-				SignatureType: signatureType,
+				SignatureType:  signatureType,
+				parentsError:   parentsError,
+				timestampError: validateCommitTimestamps(commit, state, repoConfig, now),
 			}
 		}
 	}
@@ -776,6 +949,21 @@ func isProtected(reference *plumbing.Reference, config *RepoConfig) (bool, strin
 	return isProtected, branchName
 }
 
+// branchStrategy returns the strategy for the protected branch. Without an explicit strategy, requireMergeCommits
+// selects the merge strategy, otherwise the branch is not restricted to a specific shape.
+func branchStrategy(branchName string, config *RepoConfig) BranchStrategy {
+	strategy, found := config.branchStrategies[branchName]
+	if found {
+		return strategy
+	}
+
+	if config.requireMergeCommits {
+		return BranchStrategyMerge
+	}
+
+	return ""
+}
+
 func BranchName(ref string) (string, bool) {
 	found := false
 	var branchName string
@@ -794,3 +982,45 @@ func BranchName(ref string) (string, bool) {
 
 	return branchName, found
 }
+
+// markProtectedBranchCommits sets OnProtectedBranch for the commits reachable from a protected branch,
+// stopping at the commits ignored because of 'after'.
+func markProtectedBranchCommits(repo *git.Repository, state gitkit.RepoObjects, commitMetadata map[plumbing.Hash]*CommitData, config *RepoConfig) error {
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	return refs.ForEach(func(reference *plumbing.Reference) error {
+		if reference.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		protected, _ := isProtected(reference, config)
+		if !protected {
+			return nil
+		}
+
+		queue := []plumbing.Hash{reference.Hash()}
+		for len(queue) > 0 {
+			current := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+
+			metadata, found := commitMetadata[current]
+			if !found || metadata.Ignore || metadata.OnProtectedBranch {
+				continue
+			}
+
+			metadata.OnProtectedBranch = true
+
+			commit, err := state.Commit(current)
+			if err != nil {
+				return err
+			}
+
+			queue = append(queue, commit.ParentHashes...)
+		}
+
+		return nil
+	})
+}
@@ -273,7 +273,11 @@ func modulePathAndVersion(repo *git.Repository, targetHash plumbing.Hash, prefix
 func getNewestTag(repo *git.Repository, hash plumbing.Hash, prefix string) (string, bool, bool, error) {
 	// TODO figure out proper way to find latest tag
 
-	repoState := gitkit.LoadRepoState(repo)
+	repoState, err := gitkit.NewLazyRepoState(repo, gitkit.DefaultObjectCacheSize)
+	if err != nil {
+		return "", false, false, err
+	}
+
 	tagMap := make(map[plumbing.Hash][]string)
 
 	tags, err := repo.Tags()
@@ -318,8 +322,8 @@ func getNewestTag(repo *git.Repository, hash plumbing.Hash, prefix string) (stri
 			return "", false, false, err
 		}
 
-		tags, found := repoState.TargetToTagMap[commit.Hash]
-		if found {
+		tags := repoState.TagsForTarget(commit.Hash)
+		if len(tags) > 0 {
 			if len(tags) > 1 {
 				return "", false, false, fmt.Errorf("multiple tags not supported, found for commit %s", commit.Hash.String())
 			}
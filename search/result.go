@@ -1,6 +1,10 @@
 package search
 
-import "github.com/supply-chain-tools/go-sandbox/hashset"
+import (
+	"sort"
+
+	"github.com/supply-chain-tools/go-sandbox/hashset"
+)
 
 type Result interface {
 	MatchedKeyword() KeywordMatch
@@ -130,3 +134,65 @@ func (km *keywordMatch) ExactCandidate() bool {
 func (km *keywordMatch) AltOriginals() hashset.Set[string] {
 	return km.altOriginals
 }
+
+// ResultData is the serializable form of a Result, e.g. to cache results on disk.
+type ResultData struct {
+	TypoVariation  string   `json:"typoVariation"`
+	Original       string   `json:"original"`
+	AltOriginals   []string `json:"altOriginals,omitempty"`
+	ExactCandidate bool     `json:"exactCandidate,omitempty"`
+	LineNumber     uint32   `json:"lineNumber"`
+	ContextBefore  string   `json:"contextBefore,omitempty"`
+	MatchedText    string   `json:"matchedText"`
+	ContextAfter   string   `json:"contextAfter,omitempty"`
+	StartOfWord    int      `json:"startOfWord"`
+	EndOfWord      int      `json:"endOfWord"`
+	StartOfMatch   int      `json:"startOfMatch"`
+	EndOfMatch     int      `json:"endOfMatch"`
+	StartOfLine    int      `json:"startOfLine"`
+	TrimmedLeft    bool     `json:"trimmedLeft,omitempty"`
+	TrimmedRight   bool     `json:"trimmedRight,omitempty"`
+}
+
+func NewResultData(r Result) ResultData {
+	altOriginals := make([]string, 0)
+	if r.MatchedKeyword().AltOriginals() != nil {
+		altOriginals = r.MatchedKeyword().AltOriginals().Values()
+		sort.Strings(altOriginals)
+	}
+
+	return ResultData{
+		TypoVariation:  r.MatchedKeyword().TypoVariation(),
+		Original:       r.MatchedKeyword().Original(),
+		AltOriginals:   altOriginals,
+		ExactCandidate: r.MatchedKeyword().ExactCandidate(),
+		LineNumber:     r.LineNumber(),
+		ContextBefore:  r.ContextBefore(),
+		MatchedText:    r.MatchedText(),
+		ContextAfter:   r.ContextAfter(),
+		StartOfWord:    r.StartOfWord(),
+		EndOfWord:      r.EndOfWord(),
+		StartOfMatch:   r.StartOfMatch(),
+		EndOfMatch:     r.EndOfMatch(),
+		StartOfLine:    r.StartOfLine(),
+		TrimmedLeft:    r.TrimmedLeft(),
+		TrimmedRight:   r.TrimmedRight(),
+	}
+}
+
+func (rd *ResultData) Result() Result {
+	return &result{
+		matchedKeyword: NewKeywordMatch(rd.TypoVariation, rd.Original, hashset.New(rd.AltOriginals...), rd.ExactCandidate),
+		lineNumber:     rd.LineNumber,
+		contextBefore:  rd.ContextBefore,
+		matchedText:    rd.MatchedText,
+		contextAfter:   rd.ContextAfter,
+		startOfWord:    rd.StartOfWord,
+		endOfWord:      rd.EndOfWord,
+		startOfMatch:   rd.StartOfMatch,
+		endOfMatch:     rd.EndOfMatch,
+		startOfLine:    rd.StartOfLine,
+		trimmedLeft:    rd.TrimmedLeft,
+		trimmedRight:   rd.TrimmedRight,
+	}
+}
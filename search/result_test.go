@@ -0,0 +1,47 @@
+package search
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestResultData(t *testing.T) {
+	search := New([]string{"testing"}, NewParameters(MatchNormalized, CharacterSetPackage))
+
+	results, err := search.Match([]byte("first line\nsome testing here\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(results))
+	}
+
+	data, err := json.Marshal(NewResultData(results[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resultData ResultData
+	err = json.Unmarshal(data, &resultData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := results[0]
+	actual := resultData.Result()
+
+	if actual.MatchId() != expected.MatchId() || actual.SearchTermId() != expected.SearchTermId() {
+		t.Errorf("expected '%s' for '%s', got '%s' for '%s'", expected.MatchId(), expected.SearchTermId(), actual.MatchId(), actual.SearchTermId())
+	}
+
+	if actual.LineNumber() != expected.LineNumber() || actual.StartOfWord() != expected.StartOfWord() ||
+		actual.EndOfMatch() != expected.EndOfMatch() || actual.StartOfLine() != expected.StartOfLine() {
+		t.Errorf("expected the same position, got line %d and %d", actual.LineNumber(), expected.LineNumber())
+	}
+
+	if actual.MatchedKeyword().TypoVariation() != expected.MatchedKeyword().TypoVariation() ||
+		actual.MatchedKeyword().ExactCandidate() != expected.MatchedKeyword().ExactCandidate() {
+		t.Errorf("expected keyword '%s', got '%s'", expected.MatchedKeyword().TypoVariation(), actual.MatchedKeyword().TypoVariation())
+	}
+}